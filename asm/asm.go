@@ -0,0 +1,227 @@
+// Package asm translates between the text assembly of the VM and its bytecode.
+//
+// Every line of the source holds at most one instruction written as its mnemonic
+// and an optional operand, a label definition "name:" may precede it and ";" starts a comment:
+//
+//	loop:   JUMPDEST        ; labels mark positions in the code
+//	        PUSH 1          ; PUSH picks the shortest push instruction
+//	        PUSH2 0x0100    ; PUSHn takes an operand of n bytes at most
+//	        PUSHBYTE 'a'
+//	        PUSH loop       ; labels are pushed with PUSH2
+//	        JUMP
+//	        DB 0xff         ; DB emits a raw byte
+package asm
+
+import (
+	"blockchain/core"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// mnemonics of pseudo instructions
+const (
+	mnemonicPush = "PUSH"
+	mnemonicByte = "DB"
+)
+
+// labelSize is the operand size of the push instruction of a label
+const labelSize = 2
+
+var (
+	ErrUnknownInstruction = errors.New("unknown instruction")
+	ErrInvalidOperand     = errors.New("invalid operand")
+	ErrInvalidLabel       = errors.New("invalid label")
+	ErrDuplicateLabel     = errors.New("duplicate label")
+	ErrUnknownLabel       = errors.New("unknown label")
+)
+
+// wordModulus is 2^256, negative integers are pushed in two's complement form
+var wordModulus = new(big.Int).Lsh(big.NewInt(1), 256)
+
+// instructions maps mnemonics to instructions
+var instructions = make(map[string]core.Instruction)
+
+func init() {
+	for b := 0; b < 256; b++ {
+		if instr := core.Instruction(b); instr.IsValid() {
+			instructions[instr.String()] = instr
+		}
+	}
+}
+
+// statement is a parsed line of the source
+type statement struct {
+	line     int
+	mnemonic string
+	operand  string
+	// value of the operand unless it is a label
+	value *big.Int
+	size  int
+}
+
+// Assemble compiles the source to bytecode
+func Assemble(src string) ([]byte, error) {
+	var (
+		statements []*statement
+		labels     = make(map[string]int)
+		offset     int
+	)
+
+	for i, line := range strings.Split(src, "\n") {
+		if pos := strings.IndexByte(line, ';'); pos >= 0 {
+			line = line[:pos]
+		}
+		fields := strings.Fields(line)
+
+		if len(fields) > 0 && strings.HasSuffix(fields[0], ":") {
+			label := strings.TrimSuffix(fields[0], ":")
+			if !isLabel(label) {
+				return nil, fmt.Errorf("line (%d): %w (%s)", i+1, ErrInvalidLabel, label)
+			}
+			if _, ok := labels[label]; ok {
+				return nil, fmt.Errorf("line (%d): %w (%s)", i+1, ErrDuplicateLabel, label)
+			}
+			labels[label] = offset
+			fields = fields[1:]
+		}
+		if len(fields) == 0 {
+			continue
+		}
+
+		s, err := parseStatement(fields)
+		if err != nil {
+			return nil, fmt.Errorf("line (%d): %w", i+1, err)
+		}
+		s.line = i + 1
+		statements = append(statements, s)
+		offset += s.size
+	}
+
+	code := make([]byte, 0, offset)
+	for _, s := range statements {
+		var err error
+		if code, err = s.encode(code, labels); err != nil {
+			return nil, fmt.Errorf("line (%d): %w", s.line, err)
+		}
+	}
+	return code, nil
+}
+
+// parseStatement parses the instruction and computes its size
+func parseStatement(fields []string) (*statement, error) {
+	if len(fields) > 2 {
+		return nil, fmt.Errorf("%w (%s)", ErrInvalidOperand, strings.Join(fields[1:], " "))
+	}
+
+	s := &statement{mnemonic: strings.ToUpper(fields[0])}
+	if len(fields) == 2 {
+		s.operand = fields[1]
+	}
+
+	var immediate int
+	switch s.mnemonic {
+	case mnemonicPush:
+		immediate = labelSize
+	case mnemonicByte:
+		immediate = 0
+	default:
+		instr, ok := instructions[s.mnemonic]
+		if !ok {
+			return nil, fmt.Errorf("%w (%s)", ErrUnknownInstruction, fields[0])
+		}
+		immediate = instr.ImmediateSize()
+		if immediate == 0 && s.operand != "" {
+			return nil, fmt.Errorf("%w: (%s) takes no operand", ErrInvalidOperand, s.mnemonic)
+		}
+	}
+	if s.operand == "" && (immediate > 0 || s.mnemonic == mnemonicByte) {
+		return nil, fmt.Errorf("%w: (%s) needs an operand", ErrInvalidOperand, s.mnemonic)
+	}
+
+	if s.operand != "" && !isLabel(s.operand) {
+		value, err := parseValue(s.operand)
+		if err != nil {
+			return nil, err
+		}
+		s.value = value
+		if s.mnemonic == mnemonicPush {
+			immediate = max(len(value.Bytes()), 1)
+		}
+	}
+
+	s.size = 1 + immediate
+	return s, nil
+}
+
+// encode appends the bytecode of the statement to the code
+func (s *statement) encode(code []byte, labels map[string]int) ([]byte, error) {
+	value := s.value
+	if value == nil && s.operand != "" {
+		offset, ok := labels[s.operand]
+		if !ok {
+			return nil, fmt.Errorf("%w (%s)", ErrUnknownLabel, s.operand)
+		}
+		value = big.NewInt(int64(offset))
+	}
+
+	var instr core.Instruction
+	switch s.mnemonic {
+	case mnemonicPush:
+		instr = core.InstrPush1 + core.Instruction(s.size-2)
+	case mnemonicByte:
+		if value.Cmp(big.NewInt(0xff)) > 0 {
+			return nil, fmt.Errorf("%w: (%s) doesn't fit a byte", ErrInvalidOperand, s.operand)
+		}
+		return append(code, byte(value.Uint64())), nil
+	default:
+		instr = instructions[s.mnemonic]
+	}
+
+	code = append(code, byte(instr))
+	size := instr.ImmediateSize()
+	if size == 0 {
+		return code, nil
+	}
+	if len(value.Bytes()) > size {
+		return nil, fmt.Errorf("%w: (%s) doesn't fit (%d) bytes", ErrInvalidOperand, s.operand, size)
+	}
+	return append(code, value.FillBytes(make([]byte, size))...), nil
+}
+
+// parseValue parses a decimal, hexadecimal or character operand,
+// negative integers are converted to two's complement form
+func parseValue(operand string) (*big.Int, error) {
+	if strings.HasPrefix(operand, "'") {
+		char, err := strconv.Unquote(operand)
+		if err != nil || len(char) != 1 {
+			return nil, fmt.Errorf("%w (%s)", ErrInvalidOperand, operand)
+		}
+		return big.NewInt(int64(char[0])), nil
+	}
+
+	value, ok := new(big.Int).SetString(operand, 0)
+	if !ok || value.Cmp(wordModulus) >= 0 || value.CmpAbs(wordModulus) > 0 {
+		return nil, fmt.Errorf("%w (%s)", ErrInvalidOperand, operand)
+	}
+	if value.Sign() < 0 {
+		value.Add(value, wordModulus)
+	}
+	return value, nil
+}
+
+// isLabel reports whether the name is a valid label, labels start with a letter or underscore
+func isLabel(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		letter := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		if !letter && (i == 0 || c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
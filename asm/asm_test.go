@@ -0,0 +1,139 @@
+package asm
+
+import (
+	"blockchain/core"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestAssemble(t *testing.T) {
+	code, err := Assemble(`
+		; comments and blank lines are skipped
+		push 300        ; mnemonics are case-insensitive
+		PUSH1 0x05
+		PUSH32 -1
+		PUSHBYTE 'a'
+		PUSH 1
+		PACK
+		ADD
+		DB 0xff
+	`)
+	assert.Nil(t, err)
+
+	expected := new(core.Instr).Int(300).Int(5).BigInt(big.NewInt(-1)).Bytes()
+	expected = append(expected, byte(core.InstrPushByte), 'a')
+	expected = append(expected, new(core.Instr).Int(1).Bytes()...)
+	expected = append(expected, byte(core.InstrPack), byte(core.InstrAdd), 0xff)
+	assert.Equal(t, expected, code)
+}
+
+func TestAssemble_Labels(t *testing.T) {
+	// sums numbers from 1 to 10
+	code, err := Assemble(`
+		        PUSH 0          ; sum
+		        PUSH 10         ; counter
+		loop:   JUMPDEST
+		        PUSH 1
+		        DUP
+		        PUSH 0
+		        EQ
+		        PUSH done
+		        JUMPI
+		        PUSH 1
+		        DUP
+		        PUSH 3
+		        DUP
+		        ADD             ; sum + counter
+		        PUSH 2
+		        SWAP
+		        POP
+		        PUSH 1
+		        SUB             ; counter - 1
+		        PUSH loop
+		        JUMP
+		done:   JUMPDEST
+		        POP
+		        RETURN
+	`)
+	assert.Nil(t, err)
+
+	vm := core.NewVM(&core.Context{}, code, core.NewState(), core.NewAccountsState(), core.DefaultGasLimit)
+	assert.Nil(t, vm.Run())
+	assert.Equal(t, big.NewInt(55).FillBytes(make([]byte, 32)), vm.ReturnData())
+}
+
+func TestAssemble_Errors(t *testing.T) {
+	tests := []struct {
+		src string
+		err error
+	}{
+		{"FOO", ErrUnknownInstruction},
+		{"ADD 1", ErrInvalidOperand},
+		{"PUSH1", ErrInvalidOperand},
+		{"PUSH1 256", ErrInvalidOperand},
+		{"PUSH 1 2", ErrInvalidOperand},
+		{"PUSHBYTE 'ab'", ErrInvalidOperand},
+		{"DB 0x100", ErrInvalidOperand},
+		{"PUSH 0x1" + strings.Repeat("0", 64), ErrInvalidOperand},
+		{"PUSH nowhere", ErrUnknownLabel},
+		{"a:\na:", ErrDuplicateLabel},
+		{"1a: ADD", ErrInvalidLabel},
+	}
+
+	for _, test := range tests {
+		_, err := Assemble(test.src)
+		assert.ErrorIs(t, err, test.err, test.src)
+	}
+
+	_, err := Assemble("ADD\nPOP\nFOO")
+	assert.ErrorContains(t, err, "line (3)")
+}
+
+func TestDisassemble(t *testing.T) {
+	code := new(core.Instr).Int(1).String("a").Jump(0).Bytes()
+	code = append(code, 0xff, byte(core.InstrPush1+1), 0x01)
+
+	assert.Equal(t, "PUSH1 0x01\n"+
+		"PUSHBYTE 0x61\n"+
+		"PUSH1 0x01\n"+
+		"PACK\n"+
+		"PUSH1 0x00\n"+
+		"JUMP\n"+
+		"DB 0xff\n"+
+		"DB 0x61\n"+
+		"ADD\n", Format(code))
+	assert.Empty(t, Format(nil))
+
+	instructions := Disassemble(code)
+	assert.Equal(t, 0, instructions[0].Offset)
+	assert.Equal(t, 2, instructions[1].Offset)
+	assert.False(t, instructions[6].IsValid())
+	assert.False(t, instructions[7].IsValid())
+}
+
+func TestFormat_RoundTrip(t *testing.T) {
+	programs := [][]byte{
+		new(core.Instr).Add(1, 2).String("key").Get("key").Store().Bytes(),
+		new(core.Instr).BigInt(new(big.Int).Lsh(big.NewInt(1), 200)).Log(0).Caller().Call().Bytes(),
+		{0x00, 0xfe, byte(core.InstrPush32), 0x01},
+	}
+
+	for _, code := range programs {
+		assembled, err := Assemble(Format(code))
+		assert.Nil(t, err)
+		assert.Equal(t, code, assembled)
+	}
+}
+
+func FuzzFormat_RoundTrip(f *testing.F) {
+	f.Add(new(core.Instr).Add(1, 2).Jump(0).Bytes())
+	f.Add([]byte{byte(core.InstrPush1 + 3), 0x01})
+
+	f.Fuzz(func(t *testing.T, code []byte) {
+		assembled, err := Assemble(Format(code))
+		assert.Nil(t, err)
+		assert.Equal(t, code, assembled)
+	})
+}
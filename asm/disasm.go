@@ -0,0 +1,62 @@
+package asm
+
+import (
+	"blockchain/core"
+	"fmt"
+	"strings"
+)
+
+// Instruction is a decoded instruction of the bytecode
+type Instruction struct {
+	Offset  int
+	Opcode  core.Instruction
+	Operand []byte
+}
+
+// IsValid reports whether the instruction can be executed,
+// invalid opcodes and truncated push instructions are rendered as raw bytes
+func (i Instruction) IsValid() bool {
+	return i.Opcode.IsValid() && len(i.Operand) == i.Opcode.ImmediateSize()
+}
+
+// String returns the instruction in the assembly format
+func (i Instruction) String() string {
+	switch {
+	case !i.IsValid():
+		return fmt.Sprintf("%s 0x%02x", mnemonicByte, byte(i.Opcode))
+	case len(i.Operand) > 0:
+		return fmt.Sprintf("%s 0x%x", i.Opcode, i.Operand)
+	default:
+		return i.Opcode.String()
+	}
+}
+
+// Disassemble decodes the bytecode, it never fails as bytes
+// which aren't valid instructions are decoded as raw bytes
+func Disassemble(code []byte) []Instruction {
+	var instructions []Instruction
+	for pos := 0; pos < len(code); {
+		instr := Instruction{
+			Offset: pos,
+			Opcode: core.Instruction(code[pos]),
+		}
+		pos++
+
+		if size := instr.Opcode.ImmediateSize(); size > 0 && pos+size <= len(code) {
+			instr.Operand = code[pos : pos+size]
+			pos += size
+		}
+		instructions = append(instructions, instr)
+	}
+	return instructions
+}
+
+// Format disassembles the bytecode into the source which assembles back to the same bytecode
+func Format(code []byte) string {
+	var b strings.Builder
+	for _, instr := range Disassemble(code) {
+		b.WriteString(instr.String())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
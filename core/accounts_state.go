@@ -2,6 +2,7 @@ package core
 
 import (
 	"blockchain/types"
+	"bytes"
 	"fmt"
 	"math/big"
 	"sync"
@@ -10,28 +11,96 @@ import (
 type Account struct {
 	Address types.Address
 	Balance *big.Int
+	// Nonce is the number of transactions sent from the account
+	Nonce uint64
 }
 
+// Bytes returns the canonical encoding of the account stored in the accounts trie
+func (a *Account) Bytes() []byte {
+	w := NewBinaryWriter()
+	w.WriteVersion()
+	w.WriteBigInt(a.Balance)
+	w.WriteUint64(a.Nonce)
+	return w.Bytes()
+}
+
+func decodeAccount(addr types.Address, b []byte) (*Account, error) {
+	buf := bytes.NewReader(b)
+	r := NewBinaryReader(buf)
+	r.ReadVersion()
+	balance := r.ReadBigInt()
+	nonce := r.ReadUint64()
+	if err := r.Err(); err != nil {
+		return nil, fmt.Errorf("account (%s) has invalid encoding: %w", addr, err)
+	}
+	if buf.Len() != 0 {
+		return nil, fmt.Errorf("account (%s) has trailing bytes", addr)
+	}
+	if balance == nil {
+		balance = new(big.Int)
+	}
+	return &Account{
+		Address: addr,
+		Balance: balance,
+		Nonce:   nonce,
+	}, nil
+}
+
+// AccountsState keeps accounts in a Merkle Patricia trie keyed by address
 type AccountsState struct {
-	mu       sync.RWMutex
-	accounts map[types.Address]*Account
+	mu   sync.RWMutex
+	trie *Trie
 }
 
 func NewAccountsState() *AccountsState {
 	return &AccountsState{
-		accounts: make(map[types.Address]*Account),
+		trie: NewTrie(),
 	}
 }
 
-func (s *AccountsState) CreateAccount(addr types.Address, balance *big.Int) *Account {
+// Copy returns accounts state which can be modified independently
+func (s *AccountsState) Copy() *AccountsState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return &AccountsState{
+		trie: s.trie.Copy(),
+	}
+}
+
+// Snapshot returns identifier of the current revision of the state
+func (s *AccountsState) Snapshot() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.trie.Snapshot()
+}
+
+// RevertToSnapshot discards changes made after the snapshot was taken
+func (s *AccountsState) RevertToSnapshot(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.trie.RevertToSnapshot(id)
+}
+
+// Root returns root hash of the accounts trie
+func (s *AccountsState) Root() types.Hash {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	return s.trie.Hash()
+}
+
+func (s *AccountsState) CreateAccount(addr types.Address, balance *big.Int) *Account {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	account := &Account{
 		Address: addr,
-		Balance: balance,
+		Balance: new(big.Int).Set(balance),
 	}
-	s.accounts[addr] = account
+	s.putAccount(account)
 
 	return account
 }
@@ -44,11 +113,15 @@ func (s *AccountsState) GetAccount(addr types.Address) (*Account, error) {
 }
 
 func (s *AccountsState) getAccount(addr types.Address) (*Account, error) {
-	account, ok := s.accounts[addr]
+	b, ok := s.trie.Get(addr[:])
 	if !ok {
 		return nil, fmt.Errorf("account (%s) not found", addr)
 	}
-	return account, nil
+	return decodeAccount(addr, b)
+}
+
+func (s *AccountsState) putAccount(account *Account) {
+	s.trie.Put(account.Address[:], account.Bytes())
 }
 
 func (s *AccountsState) GetBalance(addr types.Address) (*big.Int, error) {
@@ -66,15 +139,43 @@ func (s *AccountsState) getBalance(addr types.Address) (*big.Int, error) {
 	return account.Balance, nil
 }
 
+// GetNonce returns nonce of the account, non-existent account has zero nonce
+func (s *AccountsState) GetNonce(addr types.Address) uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	account, err := s.getAccount(addr)
+	if err != nil {
+		return 0
+	}
+	return account.Nonce
+}
+
+// IncrementNonce increments nonce of the account creating it if needed
+func (s *AccountsState) IncrementNonce(addr types.Address) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account, err := s.getAccount(addr)
+	if err != nil {
+		account = &Account{
+			Address: addr,
+			Balance: new(big.Int),
+		}
+	}
+	account.Nonce++
+	s.putAccount(account)
+}
+
 func (s *AccountsState) Transfer(from, to types.Address, amount *big.Int) error {
-	//s.mu.Lock()
-	//defer s.mu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	if err := s.SubBalance(from, amount); err != nil {
+	if err := s.subBalance(from, amount); err != nil {
 		return err
 	}
 
-	s.AddBalance(to, amount)
+	s.addBalance(to, amount)
 
 	fmt.Printf("transferred (%s) from (%s) to (%s)", amount, from, to)
 
@@ -82,28 +183,45 @@ func (s *AccountsState) Transfer(from, to types.Address, amount *big.Int) error
 }
 
 func (s *AccountsState) AddBalance(addr types.Address, amount *big.Int) {
-	if s.accounts[addr] == nil {
-		s.accounts[addr] = &Account{
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.addBalance(addr, amount)
+}
+
+func (s *AccountsState) addBalance(addr types.Address, amount *big.Int) {
+	account, err := s.getAccount(addr)
+	if err != nil {
+		account = &Account{
 			Address: addr,
 			Balance: new(big.Int),
 		}
 	}
-	s.accounts[addr].Balance.Add(s.accounts[addr].Balance, amount)
+	account.Balance.Add(account.Balance, amount)
+	s.putAccount(account)
 }
 
 func (s *AccountsState) SubBalance(addr types.Address, amount *big.Int) error {
-	balance, err := s.getBalance(addr)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.subBalance(addr, amount)
+}
+
+func (s *AccountsState) subBalance(addr types.Address, amount *big.Int) error {
+	account, err := s.getAccount(addr)
 	if err != nil {
 		return err
 	}
 
-	if balance.Cmp(amount) == -1 {
+	if account.Balance.Cmp(amount) == -1 {
 		return fmt.Errorf(
 			"account (%s) doesn't have enough balance (balance = %d, required amount = %d)",
-			addr, balance, amount)
+			addr, account.Balance, amount)
 	}
 
-	s.accounts[addr].Balance.Sub(s.accounts[addr].Balance, amount)
+	account.Balance.Sub(account.Balance, amount)
+	s.putAccount(account)
 
 	return nil
 }
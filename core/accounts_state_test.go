@@ -53,3 +53,45 @@ func TestAccountsStateTransferFail(t *testing.T) {
 	err = accountState.Transfer(coinBase, to, amount)
 	assert.NotNil(t, err)
 }
+
+func TestAccountsStateRoot(t *testing.T) {
+	alice := crypto.GeneratePrivateKey().PublicKey().Address()
+	bob := crypto.GeneratePrivateKey().PublicKey().Address()
+
+	a := NewAccountsState()
+	a.CreateAccount(alice, big.NewInt(100))
+	a.CreateAccount(bob, big.NewInt(200))
+
+	b := NewAccountsState()
+	b.CreateAccount(bob, big.NewInt(200))
+	b.CreateAccount(alice, big.NewInt(100))
+	assert.Equal(t, a.Root(), b.Root())
+
+	c := a.Copy()
+	assert.Nil(t, c.Transfer(alice, bob, big.NewInt(50)))
+	assert.NotEqual(t, a.Root(), c.Root())
+
+	balance, err := a.GetBalance(alice)
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(100), balance)
+	balance, err = c.GetBalance(alice)
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(50), balance)
+}
+
+func TestAccountsStateNonce(t *testing.T) {
+	accountState := NewAccountsState()
+	addr := crypto.GeneratePrivateKey().PublicKey().Address()
+	assert.Equal(t, uint64(0), accountState.GetNonce(addr))
+
+	accountState.IncrementNonce(addr)
+	accountState.IncrementNonce(addr)
+	assert.Equal(t, uint64(2), accountState.GetNonce(addr))
+
+	// balance changes keep the nonce
+	accountState.AddBalance(addr, big.NewInt(10))
+	account, err := accountState.GetAccount(addr)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), account.Nonce)
+	assert.Equal(t, big.NewInt(10), account.Balance)
+}
@@ -3,9 +3,6 @@ package core
 import (
 	"blockchain/crypto"
 	"blockchain/types"
-	"bytes"
-	"crypto/sha256"
-	"encoding/gob"
 	"fmt"
 	"math/big"
 	"time"
@@ -13,17 +10,21 @@ import (
 
 type Header struct {
 	Version          uint32
+	ChainID          uint64 // set in the genesis configuration
 	TransactionsHash types.Hash
+	StateRoot        types.Hash
+	ReceiptsHash     types.Hash
+	LogsBloom        Bloom
 	PrevHeaderHash   types.Hash
 	Height           uint32
 	Timestamp        int64
 }
 
+// Bytes returns the canonical encoding of the header
 func (h Header) Bytes() []byte {
-	buf := new(bytes.Buffer)
-	enc := gob.NewEncoder(buf)
-	enc.Encode(h)
-	return buf.Bytes()
+	w := NewBinaryWriter()
+	writeHeader(w, &h)
+	return w.Bytes()
 }
 
 type Block struct {
@@ -48,6 +49,7 @@ func NewBlockFromPrevHeader(prevHeader *Header, txs []*Transaction) (*Block, err
 
 	header := &Header{
 		Version:          1,
+		ChainID:          prevHeader.ChainID,
 		TransactionsHash: transactionsHash,
 		PrevHeaderHash:   HeaderHasher{}.Hash(prevHeader),
 		Height:           prevHeader.Height + 1,
@@ -84,6 +86,12 @@ func (b *Block) Verify() error {
 	if b.Signature == nil {
 		return fmt.Errorf("block has no signature")
 	}
+	if !b.Signature.Valid() {
+		return fmt.Errorf("block signature has zero component")
+	}
+	if !b.Validator.Valid() {
+		return fmt.Errorf("block has invalid validator public key (%s)", b.Validator)
+	}
 
 	hash := b.HeaderHash(HeaderHasher{})
 	if !b.Signature.Verify(b.Validator, hash.Bytes()) {
@@ -91,6 +99,10 @@ func (b *Block) Verify() error {
 	}
 
 	for _, tx := range b.Transactions {
+		if tx.ChainID != b.ChainID {
+			return fmt.Errorf("%w: transaction (%s) has chain id (%d), block has (%d)",
+				ErrInvalidChainID, tx.Hash(TransactionHasher{}), tx.ChainID, b.ChainID)
+		}
 		if err := tx.Verify(); err != nil {
 			return err
 		}
@@ -108,34 +120,87 @@ func (b *Block) Verify() error {
 	return nil
 }
 
-func HashTransactions(txs []*Transaction) (types.Hash, error) {
-	buf := new(bytes.Buffer)
-	for _, tx := range txs {
-		if err := tx.Encode(NewGobTransactionEncoder(buf)); err != nil {
-			return types.Hash{}, err
+// TransactionProof returns proof of inclusion of the transaction with the given hash
+func (b *Block) TransactionProof(hash types.Hash) (*MerkleProof, error) {
+	leaves := transactionLeaves(b.Transactions)
+	for i, leaf := range leaves {
+		if leaf == hash {
+			return NewMerkleProof(leaves, i)
 		}
 	}
-	hash := sha256.Sum256(buf.Bytes())
-	return hash, nil
+	return nil, fmt.Errorf("transaction (%s) isn't included in block (%s)", hash, b.HeaderHash(HeaderHasher{}))
 }
 
-func CreateGenesisBlock() *Block {
-	// transactions hash shouldn't be saved in header because of the random nonce
-	// which makes genesis blocks differ on every server
+// HashTransactions returns root of the Merkle tree of transaction hashes
+func HashTransactions(txs []*Transaction) (types.Hash, error) {
+	return MerkleRoot(transactionLeaves(txs)), nil
+}
+
+func transactionLeaves(txs []*Transaction) []types.Hash {
+	leaves := make([]types.Hash, len(txs))
+	for i, tx := range txs {
+		leaves[i] = tx.Hash(TransactionHasher{})
+	}
+	return leaves
+}
+
+// DefaultChainID is the chain id of the development network
+const DefaultChainID = 1
+
+// GenesisConfig describes the first block of the chain
+type GenesisConfig struct {
+	// ChainID is included in the signed hashes of transactions and headers,
+	// so signatures are valid only on the chain they were made for
+	ChainID uint64
+	// MinGasPrice is the minimum gas price of transactions included in blocks, any price is allowed if nil
+	MinGasPrice *big.Int
+	// Alloc lists accounts funded by the coinbase in the genesis block
+	Alloc []GenesisAccount
+}
+
+// GenesisAccount is an account funded in the genesis block
+type GenesisAccount struct {
+	Address types.Address
+	Balance *big.Int
+}
+
+func DefaultGenesisConfig() *GenesisConfig {
+	return &GenesisConfig{
+		ChainID:     DefaultChainID,
+		MinGasPrice: big.NewInt(DefaultMinGasPrice),
+	}
+}
+
+// CreateGenesisBlock returns the genesis block of the chain, the block is deterministic,
+// so all nodes with the same config derive the same genesis block
+func CreateGenesisBlock(cfg *GenesisConfig) *Block {
 	h := &Header{
 		Version: 1,
+		ChainID: cfg.ChainID,
 	}
 
 	coinBase := crypto.PublicKey{}
-	tx := NewTransaction(nil)
-	tx.From = coinBase
-	tx.To = coinBase
 	value, _ := new(big.Int).SetString("1000000000000000000", 10)
-	tx.Value = value
+	tx := NewTransaction(&Transfer{
+		To:    coinBase.Address(),
+		Value: value,
+	})
+	tx.ChainID = cfg.ChainID
+	tx.From = coinBase
+	txs := []*Transaction{tx}
 
-	b := NewBlock(h, []*Transaction{tx})
+	for _, account := range cfg.Alloc {
+		tx := NewTransaction(&Transfer{
+			To:    account.Address,
+			Value: account.Balance,
+		})
+		tx.ChainID = cfg.ChainID
+		tx.From = coinBase
+		tx.Nonce = uint64(len(txs))
+		txs = append(txs, tx)
+	}
 
-	return b
+	return NewBlock(h, txs)
 }
 
 //func (h *Header) EncodeBinary(w io.Writer) error {
@@ -20,13 +20,25 @@ func TestBlock_SignAndVerify(t *testing.T) {
 	assert.NotNil(t, block.Verify())
 }
 
+func TestBlock_VerifyInvalidSignature(t *testing.T) {
+	b := randomBlock(t, types.Hash{}, 0, nil)
+	sig := b.Signature
+
+	b.Signature = &crypto.Signature{R: sig.R}
+	assert.ErrorContains(t, b.Verify(), "zero component")
+
+	b.Signature = sig
+	b.Validator = crypto.PublicKey{0x03}
+	assert.ErrorContains(t, b.Verify(), "invalid validator public key")
+}
+
 func TestBlock_EncodeAndDecode(t *testing.T) {
 	block := randomBlock(t, types.Hash{}, 0, nil)
 	buf := new(bytes.Buffer)
-	assert.Nil(t, block.Encode(NewGobBlockEncoder(buf)))
+	assert.Nil(t, block.Encode(NewBinaryBlockEncoder(buf)))
 
 	decodedBlock := new(Block)
-	err := decodedBlock.Decode(NewGobBlockDecoder(buf))
+	err := decodedBlock.Decode(NewBinaryBlockDecoder(buf))
 	assert.Nil(t, err)
 	assert.Equal(t, block, decodedBlock)
 }
@@ -62,3 +74,18 @@ func randomBlock(t *testing.T, prevHeaderHash types.Hash, height uint32, txs []*
 
 	return block
 }
+
+func TestBlock_TransactionProof(t *testing.T) {
+	txs := []*Transaction{randomTxWithSignature(), randomTxWithSignature(), randomTxWithSignature()}
+	block := randomBlock(t, types.Hash{}, 0, txs)
+
+	for _, tx := range txs {
+		hash := tx.Hash(TransactionHasher{})
+		proof, err := block.TransactionProof(hash)
+		assert.Nil(t, err)
+		assert.True(t, proof.Verify(block.TransactionsHash, hash))
+	}
+
+	_, err := block.TransactionProof(randomTxWithSignature().Hash(TransactionHasher{}))
+	assert.NotNil(t, err)
+}
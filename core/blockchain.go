@@ -7,81 +7,103 @@ import (
 	"log/slog"
 	"math/big"
 	"sync"
+	"time"
 )
 
+// stateCheckpointInterval is the number of blocks between kept states,
+// a state keeps the trie nodes which later blocks replaced, so keeping every state grows memory with every block
+const stateCheckpointInterval = 128
+
 type Blockchain struct {
-	blocksMu        sync.RWMutex
-	blocks          []*Block
-	blocksMap       map[types.Hash]*Block
-	transactionsMap map[types.Hash]*Transaction
-	collectionsMap  map[types.Hash]*Collection
-	mintsMap        map[types.Hash]*Mint
-	validator       Validator
-	accountsState   *AccountsState
-	contractState   *State
-	store           Storage
-}
-
-func NewBlockchain(genesisBlock *Block) (*Blockchain, error) {
+	// current state, replaced after every applied block
+	*chainState
+	stateMu            sync.RWMutex
+	blocksMu           sync.RWMutex
+	blocks             []*Block
+	blocksMap          map[types.Hash]*Block
+	transactionsMap    map[types.Hash]*Transaction
+	transactionHeights map[types.Hash]uint32
+	receiptsMap        map[types.Hash]*Receipt
+	blockReceipts      [][]*Receipt
+	validator          Validator
+	store              Storage
+	chainID            uint64
+	// checkpoints hold states blocks at multiples of stateCheckpointInterval were applied to,
+	// other states are rebuilt by replaying blocks from the preceding checkpoint
+	checkpoints []*chainState
+	// minGasPrice of transactions included in new blocks, any price is allowed if nil
+	minGasPrice *big.Int
+	// addMu is held from the validation of a block until it's committed,
+	// so the block is committed on top of the state it was validated against
+	addMu sync.Mutex
+}
+
+// NewBlockchain restores the blockchain from the given storage.
+// If the storage is empty the genesis block is saved as the first block.
+func NewBlockchain(store Storage, genesisBlock *Block) (*Blockchain, error) {
 	accountState := NewAccountsState()
 	coinBase := crypto.PublicKey{}
 	balance, _ := new(big.Int).SetString("1000000000000000000", 10)
 	accountState.CreateAccount(coinBase.Address(), balance)
 
 	bc := &Blockchain{
-		blocksMap:       make(map[types.Hash]*Block),
-		transactionsMap: make(map[types.Hash]*Transaction),
-		collectionsMap:  make(map[types.Hash]*Collection),
-		mintsMap:        make(map[types.Hash]*Mint),
-		// read from some DB on startup
-		accountsState: accountState,
-		contractState: NewState(),
-		store:         NewMemoryStore(),
+		blocksMap:          make(map[types.Hash]*Block),
+		transactionsMap:    make(map[types.Hash]*Transaction),
+		transactionHeights: make(map[types.Hash]uint32),
+		receiptsMap:        make(map[types.Hash]*Receipt),
+		chainState:         newChainState(accountState),
+		store:              store,
+		chainID:            genesisBlock.ChainID,
 	}
 
 	bc.validator = NewBlockValidator(bc)
 
-	err := bc.saveBlock(genesisBlock)
+	// replay stored blocks to rebuild transactions and state
+	err := store.Iterate(bc.applyBlock)
 	if err != nil {
 		return nil, err
 	}
+
+	if len(bc.blocks) == 0 {
+		if err = bc.saveBlock(genesisBlock); err != nil {
+			return nil, err
+		}
+	} else if bc.blocks[0].ChainID != bc.chainID {
+		return nil, fmt.Errorf("%w: stored chain has chain id (%d), genesis has (%d)",
+			ErrInvalidChainID, bc.blocks[0].ChainID, bc.chainID)
+	}
+
 	return bc, nil
 }
 
+// ChainID returns id of the chain set in the genesis block
+func (bc *Blockchain) ChainID() uint64 {
+	return bc.chainID
+}
+
 func (bc *Blockchain) SetValidator(v Validator) {
 	bc.validator = v
 }
 
-func (bc *Blockchain) AddBlock(b *Block) error {
-	if err := bc.validator.ValidateBlock(b); err != nil {
-		return err
-	}
-	return bc.saveBlock(b)
+// SetMinGasPrice sets the minimum gas price of transactions included in new blocks,
+// stored blocks aren't checked on reload
+func (bc *Blockchain) SetMinGasPrice(price *big.Int) {
+	bc.minGasPrice = price
 }
 
-func (bc *Blockchain) handleTransfer(tx *Transaction) error {
-	return bc.accountsState.Transfer(tx.From.Address(), tx.To.Address(), tx.Value)
+func (bc *Blockchain) MinGasPrice() *big.Int {
+	return bc.minGasPrice
 }
 
-func (bc *Blockchain) handleNFT(tx *Transaction) error {
-	hash := tx.Hash(TransactionHasher{})
+func (bc *Blockchain) AddBlock(b *Block) error {
+	bc.addMu.Lock()
+	defer bc.addMu.Unlock()
 
-	switch v := tx.Inner.(type) {
-	case *Collection:
-		bc.collectionsMap[hash] = v
-		fmt.Println("created new NFT collection:", hash)
-	case *Mint:
-		_, ok := bc.collectionsMap[v.Collection]
-		if !ok {
-			return fmt.Errorf("collection (%s) doesn't exist on the blockchain", v.Collection)
-		}
-		bc.mintsMap[hash] = v
-		fmt.Printf("created new NFT (%s), collection (%s)\n", v.NFT, v.Collection)
-	default:
-		return fmt.Errorf("unsupported transaction type: (%s)", v)
+	execution, err := bc.validator.ValidateBlock(b)
+	if err != nil {
+		return err
 	}
-
-	return nil
+	return bc.storeBlock(b, execution)
 }
 
 func (bc *Blockchain) GetBlock(height uint32) (*Block, error) {
@@ -108,6 +130,9 @@ func (bc *Blockchain) GetBlockByHeaderHash(hash types.Hash) (*Block, error) {
 }
 
 func (bc *Blockchain) GetTransaction(hash types.Hash) (*Transaction, error) {
+	bc.stateMu.RLock()
+	defer bc.stateMu.RUnlock()
+
 	transaction, ok := bc.transactionsMap[hash]
 	if !ok {
 		return nil, fmt.Errorf("transaction with hash (%s) couldn't be found", hash)
@@ -115,6 +140,230 @@ func (bc *Blockchain) GetTransaction(hash types.Hash) (*Transaction, error) {
 	return transaction, nil
 }
 
+// GetReceipt returns receipt of the transaction with the given hash
+func (bc *Blockchain) GetReceipt(hash types.Hash) (*Receipt, error) {
+	bc.stateMu.RLock()
+	defer bc.stateMu.RUnlock()
+
+	receipt, ok := bc.receiptsMap[hash]
+	if !ok {
+		return nil, fmt.Errorf("receipt of transaction with hash (%s) couldn't be found", hash)
+	}
+	return receipt, nil
+}
+
+// GetBlockReceipts returns receipts of transactions of the block with the given height
+func (bc *Blockchain) GetBlockReceipts(height uint32) ([]*Receipt, error) {
+	bc.stateMu.RLock()
+	defer bc.stateMu.RUnlock()
+
+	if int(height) >= len(bc.blockReceipts) {
+		return nil, fmt.Errorf("height (%d) is too high", height)
+	}
+	return bc.blockReceipts[height], nil
+}
+
+// FilterLogs returns logs matching the filter emitted in blocks with heights from the given range,
+// blocks whose logs bloom doesn't match the filter are skipped
+func (bc *Blockchain) FilterLogs(from, to uint32, filter *LogFilter) ([]*Log, error) {
+	if from > to {
+		return nil, fmt.Errorf("invalid block range from (%d) to (%d)", from, to)
+	}
+	if to > bc.Height() {
+		return nil, fmt.Errorf("height (%d) is too high", to)
+	}
+
+	var logs []*Log
+	for height := from; height <= to; height++ {
+		block, err := bc.GetBlock(height)
+		if err != nil {
+			return nil, err
+		}
+		if !filter.MayMatch(&block.LogsBloom) {
+			continue
+		}
+		receipts, err := bc.GetBlockReceipts(height)
+		if err != nil {
+			return nil, err
+		}
+		for _, receipt := range receipts {
+			for _, log := range receipt.Logs {
+				if filter.Match(log) {
+					logs = append(logs, log)
+				}
+			}
+		}
+	}
+	return logs, nil
+}
+
+// GetTransactionProof returns the block which includes the transaction
+// and proof of inclusion against its transactions hash
+func (bc *Blockchain) GetTransactionProof(hash types.Hash) (*Block, *MerkleProof, error) {
+	bc.stateMu.RLock()
+	height, ok := bc.transactionHeights[hash]
+	bc.stateMu.RUnlock()
+	if !ok {
+		return nil, nil, fmt.Errorf("transaction with hash (%s) couldn't be found", hash)
+	}
+
+	block, err := bc.GetBlock(height)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	proof, err := block.TransactionProof(hash)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return block, proof, nil
+}
+
+// TraceTransaction replays the transaction on the state it was executed on
+// and returns the trace of its execution
+func (bc *Blockchain) TraceTransaction(hash types.Hash) (*Trace, error) {
+	bc.stateMu.RLock()
+	height, ok := bc.transactionHeights[hash]
+	var state *chainState
+	if ok {
+		state = bc.checkpoints[height/stateCheckpointInterval].copy()
+	}
+	bc.stateMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("transaction with hash (%s) couldn't be found", hash)
+	}
+
+	// blocks following the checkpoint are replayed up to the block of the transaction
+	for h := height - height%stateCheckpointInterval; h < height; h++ {
+		block, err := bc.GetBlock(h)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = state.applyTransactions(block); err != nil {
+			return nil, err
+		}
+	}
+
+	block, err := bc.GetBlock(height)
+	if err != nil {
+		return nil, err
+	}
+
+	// transactions preceding the traced one are applied without tracing
+	for i, tx := range block.Transactions {
+		if tx.Hash(TransactionHasher{}) != hash {
+			if _, err := state.applyTransaction(tx, block, i, nil); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		tracer := NewStructTracer()
+		receipt, err := state.applyTransaction(tx, block, i, tracer)
+		if err != nil {
+			return nil, err
+		}
+		trace := tracer.Trace()
+		trace.GasUsed = receipt.GasUsed
+		trace.Error = receipt.Error
+		return trace, nil
+	}
+	return nil, fmt.Errorf("transaction with hash (%s) couldn't be found in block (%d)", hash, height)
+}
+
+// SimulationResult is the outcome of a transaction executed without persisting its effects
+type SimulationResult struct {
+	ReturnData      []byte
+	Logs            []*Log
+	GasUsed         uint64
+	ContractAddress types.Address
+	Error           string
+}
+
+// Simulate executes the transaction on a copy of the current state as if it was included in the next block.
+// The nonce and signature of the transaction aren't checked, the gas limit is clamped to MaxGasLimit,
+// an error is returned if the sender can't pay for the gas.
+func (bc *Blockchain) Simulate(tx *Transaction) (*SimulationResult, error) {
+	if tx.GasLimit > MaxGasLimit {
+		clamped := *tx
+		clamped.GasLimit = MaxGasLimit
+		tx = &clamped
+	}
+	if err := tx.Validate(); err != nil {
+		return nil, err
+	}
+
+	bc.stateMu.RLock()
+	state := bc.chainState.copy()
+	bc.stateMu.RUnlock()
+
+	if err := state.buyGas(tx); err != nil {
+		return nil, err
+	}
+
+	header := &Header{
+		ChainID:   bc.chainID,
+		Height:    bc.Height() + 1,
+		Timestamp: time.Now().UnixNano(),
+	}
+	intrinsicGas := IntrinsicGas(tx)
+	res, err := state.handleTransaction(tx, header, tx.GasLimit-intrinsicGas, nil)
+
+	result := &SimulationResult{
+		ReturnData: res.returnData,
+		Logs:       res.logs,
+		GasUsed:    intrinsicGas + res.gasUsed,
+	}
+	if err != nil {
+		result.Error = err.Error()
+	} else if tx.IsDeployment() {
+		result.ContractAddress = ContractAddress(tx.From.Address(), tx.Nonce)
+	}
+	return result, nil
+}
+
+// StorageQuery holds entries of the contract storage proven against the contract root,
+// which together with the accounts and NFTs roots makes the state root
+type StorageQuery struct {
+	Entries []*StorageEntry
+	// Next is the key to continue the range query from, nil if there are no more entries
+	Next         []byte
+	StateRoot    types.Hash
+	AccountsRoot types.Hash
+	ContractRoot types.Hash
+	NFTsRoot     types.Hash
+}
+
+// GetStorage returns the entry of the contract storage by the key from the current state
+func (bc *Blockchain) GetStorage(addr types.Address, key []byte) *StorageQuery {
+	bc.stateMu.RLock()
+	defer bc.stateMu.RUnlock()
+
+	query := bc.storageQuery()
+	query.Entries = []*StorageEntry{bc.contractState.StorageEntry(addr, key)}
+	return query
+}
+
+// GetStorageRange returns entries of the contract storage from the current state, see State.StorageRange
+func (bc *Blockchain) GetStorageRange(addr types.Address, prefix, start []byte, limit int) *StorageQuery {
+	bc.stateMu.RLock()
+	defer bc.stateMu.RUnlock()
+
+	query := bc.storageQuery()
+	query.Entries, query.Next = bc.contractState.StorageRange(addr, prefix, start, limit)
+	return query
+}
+
+func (bc *Blockchain) storageQuery() *StorageQuery {
+	return &StorageQuery{
+		StateRoot:    bc.root(),
+		AccountsRoot: bc.accountsState.Root(),
+		ContractRoot: bc.contractState.Root(),
+		NFTsRoot:     bc.nfts.Root(),
+	}
+}
+
 // Height returns number of blocks in the blockchain.
 // First block is the genesis block which is not included
 func (bc *Blockchain) Height() uint32 {
@@ -128,41 +377,170 @@ func (bc *Blockchain) HasBlock(height uint32) bool {
 	return height <= bc.Height()
 }
 
-func (bc *Blockchain) handleTransaction(tx *Transaction) error {
-	if tx.Data != nil {
-		vm := NewVM(tx.Data, bc.contractState)
-		if err := vm.Run(); err != nil {
-			return err
-		}
-		slog.Info("Contract state:", "result", vm.contractState)
-		//slog.Info("VM:", "result", deserializeInt64(vm.stack.Pop().([]byte)))
+// GetNonce returns the nonce the next transaction of the account must have
+func (bc *Blockchain) GetNonce(addr types.Address) uint64 {
+	bc.stateMu.RLock()
+	defer bc.stateMu.RUnlock()
+
+	return bc.accountsState.GetNonce(addr)
+}
+
+// GetBalance returns balance of the account in the current state, non-existent account has zero balance
+func (bc *Blockchain) GetBalance(addr types.Address) *big.Int {
+	bc.stateMu.RLock()
+	defer bc.stateMu.RUnlock()
+
+	balance, _ := bc.accountsState.GetBalance(addr)
+	return balance
+}
+
+// GetNFT returns the NFT with its ownership history from the current state, burned NFTs are returned as well
+func (bc *Blockchain) GetNFT(hash types.Hash) (*NFT, error) {
+	bc.stateMu.RLock()
+	defer bc.stateMu.RUnlock()
+
+	nft, ok := bc.nfts.NFT(hash)
+	if !ok {
+		return nil, fmt.Errorf("NFT (%s) doesn't exist on the blockchain", hash)
 	}
+	return nft, nil
+}
 
-	if tx.Inner != nil {
-		if err := bc.handleNFT(tx); err != nil {
-			return err
-		}
+// GetNFTsByOwner returns NFTs currently owned by the account
+func (bc *Blockchain) GetNFTsByOwner(owner types.Address) []*NFT {
+	bc.stateMu.RLock()
+	defer bc.stateMu.RUnlock()
+
+	return bc.nfts.ByOwner(owner)
+}
+
+// GetNFTsByCollection returns NFTs of the collection which aren't burned
+func (bc *Blockchain) GetNFTsByCollection(collection types.Hash) ([]*NFT, error) {
+	bc.stateMu.RLock()
+	defer bc.stateMu.RUnlock()
+
+	if _, ok := bc.nfts.Collection(collection); !ok {
+		return nil, fmt.Errorf("collection (%s) doesn't exist on the blockchain", collection)
 	}
+	return bc.nfts.ByCollection(collection), nil
+}
 
-	if tx.Value != nil {
-		if tx.Value.Cmp(new(big.Int)) == 1 {
-			if err := bc.handleTransfer(tx); err != nil {
-				return err
-			}
+// StateRoot returns root hash of the current state
+func (bc *Blockchain) StateRoot() types.Hash {
+	bc.stateMu.RLock()
+	defer bc.stateMu.RUnlock()
+
+	return bc.root()
+}
+
+// FinalizeBlock executes transactions of the block on top of the current state,
+// sets the resulting state root, receipts hash and logs bloom in the block header and signs the block.
+// Fees of the block transactions are paid to the signer.
+func (bc *Blockchain) FinalizeBlock(b *Block, priv *crypto.PrivateKey) error {
+	b.Validator = priv.PublicKey()
+	state, receipts, err := bc.executeBlock(b)
+	if err != nil {
+		return err
+	}
+	b.StateRoot = state.root()
+	b.ReceiptsHash = HashReceipts(receipts)
+	b.LogsBloom = LogsBloom(receipts)
+	return b.Sign(priv)
+}
+
+// SelectTransactions executes the transactions in order on top of the current state
+// as if they were included in the block following the previous header and proposed by the validator.
+// It returns the transactions which can be included in the block and the ones which would fail the block.
+func (bc *Blockchain) SelectTransactions(prevHeader *Header, validator crypto.PublicKey, txs []*Transaction) (included, rejected []*Transaction) {
+	b := NewBlock(&Header{
+		ChainID: prevHeader.ChainID,
+		Height:  prevHeader.Height + 1,
+	}, nil)
+	b.Validator = validator
+
+	bc.stateMu.RLock()
+	state := bc.chainState.copy()
+	bc.stateMu.RUnlock()
+
+	for _, tx := range txs {
+		snap := state.snapshot()
+		if _, err := state.applyTransaction(tx, b, len(included), nil); err != nil {
+			state.revertToSnapshot(snap)
+			rejected = append(rejected, tx)
+			continue
 		}
+		included = append(included, tx)
 	}
+	return included, rejected
+}
 
-	return nil
+// executeBlock applies transactions of the block to a copy of the current state
+// and returns the new state along with receipts of all transactions.
+// The current state is never modified, so a block failing validation leaves no trace.
+// Effects of a failed transaction are reverted, but the sender still pays for the used gas.
+// A transaction which can't be included in the block
+// (e.g. has invalid nonce or the sender can't pay for gas) fails the whole block.
+func (bc *Blockchain) executeBlock(b *Block) (*chainState, []*Receipt, error) {
+	if err := checkBlockGas(b.Transactions); err != nil {
+		return nil, nil, err
+	}
+
+	bc.stateMu.RLock()
+	state := bc.chainState.copy()
+	bc.stateMu.RUnlock()
+
+	receipts, err := state.applyTransactions(b)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return state, receipts, nil
 }
 
+// saveBlock executes the block and persists it before appending it to the chain,
+// so a block failing execution is never written to the store
 func (bc *Blockchain) saveBlock(b *Block) error {
-	for _, tx := range b.Transactions {
-		if err := bc.handleTransaction(tx); err != nil {
-			fmt.Println(err)
-			continue
-		}
-		bc.transactionsMap[tx.Hash(TransactionHasher{})] = tx
+	state, receipts, err := bc.executeBlock(b)
+	if err != nil {
+		return err
+	}
+	return bc.storeBlock(b, &BlockExecution{state: state, receipts: receipts})
+}
+
+// storeBlock persists the executed block and appends it to the chain along with the result of its execution
+func (bc *Blockchain) storeBlock(b *Block, execution *BlockExecution) error {
+	if err := bc.store.Put(b); err != nil {
+		return err
 	}
+	bc.commitBlock(b, execution.state, execution.receipts)
+	return nil
+}
+
+// applyBlock executes transactions of the block and appends it to the chain
+func (bc *Blockchain) applyBlock(b *Block) error {
+	state, receipts, err := bc.executeBlock(b)
+	if err != nil {
+		return err
+	}
+	bc.commitBlock(b, state, receipts)
+	return nil
+}
+
+// commitBlock appends the executed block to the chain and replaces the current state
+func (bc *Blockchain) commitBlock(b *Block, state *chainState, receipts []*Receipt) {
+	bc.stateMu.Lock()
+	if b.Height%stateCheckpointInterval == 0 {
+		bc.checkpoints = append(bc.checkpoints, bc.chainState)
+	}
+	bc.chainState = state
+	for i, tx := range b.Transactions {
+		hash := tx.Hash(TransactionHasher{})
+		bc.transactionsMap[hash] = tx
+		bc.transactionHeights[hash] = b.Height
+		bc.receiptsMap[hash] = receipts[i]
+	}
+	bc.blockReceipts = append(bc.blockReceipts, receipts)
+	bc.stateMu.Unlock()
 
 	slog.Info(
 		"adding new block",
@@ -175,6 +553,4 @@ func (bc *Blockchain) saveBlock(b *Block) error {
 
 	bc.blocks = append(bc.blocks, b)
 	bc.blocksMap[b.HeaderHash(HeaderHasher{})] = b
-
-	return bc.store.Put(b)
 }
@@ -5,28 +5,32 @@ import (
 	"blockchain/types"
 	"fmt"
 	"github.com/stretchr/testify/assert"
+	"math"
 	"math/big"
 	"testing"
 )
 
+// testGenesis has no chain id since test transactions are signed without it
+var testGenesis = &GenesisConfig{}
+
 func TestBlockchain(t *testing.T) {
-	bc, err := NewBlockchain(randomBlock(t, types.Hash{}, 0, nil))
+	bc, err := NewBlockchain(NewMemoryStore(), randomBlock(t, types.Hash{}, 0, nil))
 	assert.Nil(t, err)
 	assert.NotNil(t, bc.validator)
 	assert.Equal(t, bc.Height(), uint32(0))
 }
 
 func TestHasBlock(t *testing.T) {
-	bc, _ := NewBlockchain(randomBlock(t, types.Hash{}, 0, nil))
+	bc, _ := NewBlockchain(NewMemoryStore(), randomBlock(t, types.Hash{}, 0, nil))
 	assert.True(t, bc.HasBlock(uint32(0)))
 	assert.False(t, bc.HasBlock(uint32(120)))
 }
 
 func TestAddBlock(t *testing.T) {
-	bc, _ := NewBlockchain(randomBlock(t, types.Hash{}, 0, nil))
+	bc, _ := NewBlockchain(NewMemoryStore(), randomBlock(t, types.Hash{}, 0, nil))
 	lenBlocks := 10
 	for i := 0; i < lenBlocks; i++ {
-		b := randomBlock(t, getPrevBlockHash(t, bc, uint32(i+1)), uint32(i+1), nil)
+		b := nextBlock(t, bc, nil)
 		assert.Nil(t, bc.AddBlock(b))
 	}
 	assert.Equal(t, bc.Height(), uint32(lenBlocks))
@@ -36,10 +40,10 @@ func TestAddBlock(t *testing.T) {
 }
 
 func TestGetBlock(t *testing.T) {
-	bc, _ := NewBlockchain(randomBlock(t, types.Hash{}, 0, nil))
+	bc, _ := NewBlockchain(NewMemoryStore(), randomBlock(t, types.Hash{}, 0, nil))
 	lenBlocks := 10
 	for i := 0; i < lenBlocks; i++ {
-		b := randomBlock(t, getPrevBlockHash(t, bc, uint32(i+1)), uint32(i+1), nil)
+		b := nextBlock(t, bc, nil)
 		assert.Nil(t, bc.AddBlock(b))
 		block, err := bc.GetBlock(uint32(i + 1))
 		assert.Nil(t, err)
@@ -53,20 +57,74 @@ func getPrevBlockHash(t *testing.T, bc *Blockchain, height uint32) types.Hash {
 	return HeaderHasher{}.Hash(prevBlock.Header)
 }
 
+// nextBlock returns a valid block on top of the blockchain
+func nextBlock(t *testing.T, bc *Blockchain, txs []*Transaction) *Block {
+	height := bc.Height() + 1
+	block := randomBlock(t, getPrevBlockHash(t, bc, height), height, txs)
+	block.ChainID = bc.ChainID()
+	assert.Nil(t, bc.FinalizeBlock(block, crypto.GeneratePrivateKey()))
+	return block
+}
+
+// deployContract adds a block deploying the contract and returns the contract address
+func deployContract(t *testing.T, bc *Blockchain, priv *crypto.PrivateKey, code []byte) types.Address {
+	tx := NewTransaction(&Deploy{Code: code})
+	tx.Nonce = bc.GetNonce(priv.PublicKey().Address())
+	assert.Nil(t, tx.Sign(priv))
+	assert.Nil(t, bc.AddBlock(nextBlock(t, bc, []*Transaction{tx})))
+
+	receipt, err := bc.GetReceipt(tx.Hash(TransactionHasher{}))
+	assert.Nil(t, err)
+	assert.Equal(t, ReceiptStatusSuccess, receipt.Status)
+	return receipt.ContractAddress
+}
+
+// newContractCall returns unsigned transaction calling the contract
+func newContractCall(contract types.Address, nonce uint64) *Transaction {
+	tx := NewTransaction(&Call{Contract: contract})
+	tx.Nonce = nonce
+	return tx
+}
+
+// contractValue returns value of the key in the contract storage
+func contractValue(bc *Blockchain, contract types.Address, key string) ([]byte, error) {
+	return bc.contractState.ContractStorage(contract).Get([]byte(key))
+}
+
+func TestAddBlockInvalidStateRoot(t *testing.T) {
+	bc, _ := NewBlockchain(NewMemoryStore(), randomBlock(t, types.Hash{}, 0, nil))
+	stateRoot := bc.StateRoot()
+
+	ins := new(Instr)
+	ins.Add(2, 3).String("hey").Store()
+	tx := NewTransaction(&Deploy{Code: ins.Bytes()})
+	assert.Nil(t, tx.Sign(crypto.GeneratePrivateKey()))
+
+	block := nextBlock(t, bc, []*Transaction{tx})
+	block.StateRoot = types.Hash{}
+	assert.Nil(t, block.Sign(crypto.GeneratePrivateKey()))
+
+	assert.NotNil(t, bc.AddBlock(block))
+	assert.Equal(t, uint32(0), bc.Height())
+	assert.Equal(t, stateRoot, bc.StateRoot())
+	assert.False(t, bc.contractState.HasCode(ContractAddress(tx.From.Address(), 0)))
+}
+
 func TestTransferSuccess(t *testing.T) {
-	bc, _ := NewBlockchain(CreateGenesisBlock())
+	bc, _ := NewBlockchain(NewMemoryStore(), CreateGenesisBlock(testGenesis))
 
 	bob := crypto.GeneratePrivateKey()
 	alice := crypto.GeneratePrivateKey()
 
 	bc.accountsState.CreateAccount(bob.PublicKey().Address(), new(big.Int).SetUint64(100_000_000_000))
 
-	tx := NewTransaction(nil)
-	tx.To = alice.PublicKey()
-	tx.Value = new(big.Int).SetUint64(3_000_000_000)
+	tx := NewTransaction(&Transfer{
+		To:    alice.PublicKey().Address(),
+		Value: new(big.Int).SetUint64(3_000_000_000),
+	})
 	tx.Sign(bob)
 
-	block := randomBlock(t, getPrevBlockHash(t, bc, uint32(1)), uint32(1), []*Transaction{tx})
+	block := nextBlock(t, bc, []*Transaction{tx})
 	assert.Nil(t, bc.AddBlock(block))
 
 	bobBalance, _ := bc.accountsState.getBalance(bob.PublicKey().Address())
@@ -75,23 +133,49 @@ func TestTransferSuccess(t *testing.T) {
 	assert.Equal(t, new(big.Int).SetUint64(3_000_000_000), aliceBalance)
 }
 
+func TestTransferToContract(t *testing.T) {
+	bc, _ := NewBlockchain(NewMemoryStore(), CreateGenesisBlock(testGenesis))
+	bob := crypto.GeneratePrivateKey()
+	bc.accountsState.CreateAccount(bob.PublicKey().Address(), big.NewInt(1_000))
+
+	contract := deployContract(t, bc, bob, new(Instr).Add(2, 3).Bytes())
+
+	// value is sent to contracts by calls, so the transfer fails
+	tx := NewTransaction(&Transfer{To: contract, Value: big.NewInt(100)})
+	tx.Nonce = 1
+	assert.Nil(t, tx.Sign(bob))
+	assert.Nil(t, bc.AddBlock(nextBlock(t, bc, []*Transaction{tx})))
+	receipt, err := bc.GetReceipt(tx.Hash(TransactionHasher{}))
+	assert.Nil(t, err)
+	assert.Equal(t, ReceiptStatusFailed, receipt.Status)
+	assert.Contains(t, receipt.Error, ErrInvalidTransaction.Error())
+
+	call := newContractCall(contract, 2)
+	call.Payload.(*Call).Value = big.NewInt(100)
+	assert.Nil(t, call.Sign(bob))
+	assert.Nil(t, bc.AddBlock(nextBlock(t, bc, []*Transaction{call})))
+	balance, _ := bc.accountsState.getBalance(contract)
+	assert.Equal(t, big.NewInt(100), balance)
+}
+
 func TestTransferHacked(t *testing.T) {
-	bc, _ := NewBlockchain(CreateGenesisBlock())
+	bc, _ := NewBlockchain(NewMemoryStore(), CreateGenesisBlock(testGenesis))
 
 	bob := crypto.GeneratePrivateKey()
 	alice := crypto.GeneratePrivateKey()
 
 	bc.accountsState.CreateAccount(bob.PublicKey().Address(), new(big.Int).SetUint64(100_000_000_000))
 
-	tx := NewTransaction(nil)
-	tx.To = alice.PublicKey()
-	tx.Value = new(big.Int).SetUint64(3_000_000_000)
+	tx := NewTransaction(&Transfer{
+		To:    alice.PublicKey().Address(),
+		Value: new(big.Int).SetUint64(3_000_000_000),
+	})
 	assert.Nil(t, tx.Sign(bob))
 
 	hacker := crypto.GeneratePrivateKey()
-	tx.To = hacker.PublicKey()
+	tx.Payload.(*Transfer).To = hacker.PublicKey().Address()
 
-	block := randomBlock(t, getPrevBlockHash(t, bc, uint32(1)), uint32(1), []*Transaction{tx})
+	block := nextBlock(t, bc, []*Transaction{tx})
 	assert.NotNil(t, bc.AddBlock(block))
 
 	hackerBalance, err := bc.accountsState.getBalance(hacker.PublicKey().Address())
@@ -104,3 +188,952 @@ func TestTransferHacked(t *testing.T) {
 	assert.NotNil(t, err)
 	assert.Equal(t, new(big.Int), aliceBalance)
 }
+
+func TestBlockchain_FailedBlockNotStored(t *testing.T) {
+	store := NewMemoryStore()
+	bc, err := NewBlockchain(store, CreateGenesisBlock(testGenesis))
+	assert.Nil(t, err)
+
+	// the nonce is too high, so the block fails execution
+	tx := NewTransaction(&Transfer{To: types.Address{1}, Value: big.NewInt(1)})
+	tx.Nonce = 5
+	assert.Nil(t, tx.Sign(crypto.GeneratePrivateKey()))
+	block := randomBlock(t, getPrevBlockHash(t, bc, 1), 1, []*Transaction{tx})
+	assert.NotNil(t, bc.saveBlock(block))
+	assert.Equal(t, uint32(0), bc.Height())
+	_, err = store.Get(1)
+	assert.NotNil(t, err)
+
+	// the store stays in sync with the chain
+	assert.Nil(t, bc.AddBlock(nextBlock(t, bc, nil)))
+	_, err = store.Get(1)
+	assert.Nil(t, err)
+}
+
+func TestBlockchain_ConcurrentAddBlock(t *testing.T) {
+	bc, _ := NewBlockchain(NewMemoryStore(), CreateGenesisBlock(testGenesis))
+	bob := crypto.GeneratePrivateKey()
+	bc.accountsState.CreateAccount(bob.PublicKey().Address(), big.NewInt(1_000_000))
+
+	// competing blocks at the same height spend the same nonce
+	blocks := make([]*Block, 8)
+	for i := range blocks {
+		tx := NewTransaction(&Transfer{To: types.Address{byte(i + 1)}, Value: big.NewInt(1)})
+		assert.Nil(t, tx.Sign(bob))
+		blocks[i] = nextBlock(t, bc, []*Transaction{tx})
+	}
+
+	errs := make(chan error, len(blocks))
+	for _, b := range blocks {
+		go func() {
+			errs <- bc.AddBlock(b)
+		}()
+	}
+	added := 0
+	for range blocks {
+		if <-errs == nil {
+			added++
+		}
+	}
+	assert.Equal(t, 1, added)
+	assert.Equal(t, uint32(1), bc.Height())
+	block, err := bc.GetBlock(1)
+	assert.Nil(t, err)
+	assert.Equal(t, block.StateRoot, bc.StateRoot())
+	assert.Equal(t, uint64(1), bc.GetNonce(bob.PublicKey().Address()))
+}
+
+func TestBlockchainReload(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	assert.Nil(t, err)
+
+	bc, err := NewBlockchain(store, CreateGenesisBlock(testGenesis))
+	assert.Nil(t, err)
+
+	bob := crypto.GeneratePrivateKey()
+	ins := new(Instr)
+	ins.Add(2, 3).String("hey").Store()
+	contract := deployContract(t, bc, bob, ins.Bytes())
+
+	tx := newContractCall(contract, 1)
+	assert.Nil(t, tx.Sign(bob))
+	block := nextBlock(t, bc, []*Transaction{tx})
+	assert.Nil(t, bc.AddBlock(block))
+	assert.Nil(t, store.Close())
+
+	store, err = NewFileStore(dir)
+	assert.Nil(t, err)
+	defer store.Close()
+
+	bc, err = NewBlockchain(store, CreateGenesisBlock(testGenesis))
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(2), bc.Height())
+
+	reloaded, err := bc.GetBlock(2)
+	assert.Nil(t, err)
+	assert.Equal(t, block, reloaded)
+
+	_, err = bc.GetTransaction(tx.Hash(TransactionHasher{}))
+	assert.Nil(t, err)
+
+	value, err := contractValue(bc, contract, "hey")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(5), new(big.Int).SetBytes(value).Int64())
+
+	coinBaseBalance, err := bc.accountsState.getBalance(crypto.PublicKey{}.Address())
+	assert.Nil(t, err)
+	assert.Equal(t, "1000000000000000000", coinBaseBalance.String())
+}
+
+func TestGetTransactionProof(t *testing.T) {
+	bc, _ := NewBlockchain(NewMemoryStore(), randomBlock(t, types.Hash{}, 0, nil))
+	txs := []*Transaction{randomTxWithSignature(), randomTxWithSignature()}
+	block := nextBlock(t, bc, txs)
+	assert.Nil(t, bc.AddBlock(block))
+
+	hash := txs[1].Hash(TransactionHasher{})
+	b, proof, err := bc.GetTransactionProof(hash)
+	assert.Nil(t, err)
+	assert.Equal(t, block, b)
+	assert.Equal(t, 1, proof.Index)
+	assert.True(t, proof.Verify(b.TransactionsHash, hash))
+
+	_, _, err = bc.GetTransactionProof(types.Hash{})
+	assert.NotNil(t, err)
+}
+
+func TestFailedTransactionIsReverted(t *testing.T) {
+	bc, _ := NewBlockchain(NewMemoryStore(), CreateGenesisBlock(testGenesis))
+	bob := crypto.GeneratePrivateKey()
+
+	ins := new(Instr)
+	ins.Add(2, 3).String("hey").Store()
+	contract := deployContract(t, bc, bob, ins.Bytes())
+
+	// contract fails after writing to the state
+	ins = new(Instr)
+	ins.Add(1, 1).String("foo").Store().Get("bar")
+	failingContract := deployContract(t, bc, bob, ins.Bytes())
+
+	// contract writes to the state, but the sender can't pay the value
+	tx := newContractCall(contract, 2)
+	tx.Payload.(*Call).Value = big.NewInt(1)
+	assert.Nil(t, tx.Sign(bob))
+
+	tx2 := newContractCall(failingContract, 3)
+	assert.Nil(t, tx2.Sign(bob))
+
+	contractRoot := bc.contractState.Root()
+	assert.Nil(t, bc.AddBlock(nextBlock(t, bc, []*Transaction{tx, tx2})))
+	assert.Equal(t, contractRoot, bc.contractState.Root())
+	// nonce is used even by a failed transaction
+	assert.Equal(t, uint64(4), bc.GetNonce(bob.PublicKey().Address()))
+
+	_, err := contractValue(bc, contract, "hey")
+	assert.NotNil(t, err)
+	_, err = contractValue(bc, failingContract, "foo")
+	assert.NotNil(t, err)
+}
+
+func TestMintAuthorization(t *testing.T) {
+	bc, _ := NewBlockchain(NewMemoryStore(), CreateGenesisBlock(testGenesis))
+	alice := crypto.GeneratePrivateKey()
+	bob := crypto.GeneratePrivateKey()
+
+	collTx := NewTransaction(&CreateCollection{MetaData: []byte("coll")})
+	assert.Nil(t, collTx.Sign(alice))
+	assert.Nil(t, bc.AddBlock(nextBlock(t, bc, []*Transaction{collTx})))
+	coll := collTx.Hash(TransactionHasher{})
+	collection, ok := bc.nfts.Collection(coll)
+	assert.True(t, ok)
+	assert.Equal(t, alice.PublicKey(), collection.Owner)
+
+	// mint returns the transaction of bob minting the NFT signed by the signer
+	mint := func(nft types.Hash, nonce uint64, signer *crypto.PrivateKey, minter types.Address) *Transaction {
+		m := &Mint{NFT: nft, Collection: coll}
+		assert.Nil(t, m.Sign(signer, testGenesis.ChainID, minter))
+		tx := NewTransaction(m)
+		tx.Nonce = nonce
+		assert.Nil(t, tx.Sign(bob))
+		return tx
+	}
+
+	txs := []*Transaction{
+		mint(types.Hash{1}, 0, alice, bob.PublicKey().Address()),
+		// bob doesn't own the collection
+		mint(types.Hash{2}, 1, bob, bob.PublicKey().Address()),
+		// alice allowed someone else to mint
+		mint(types.Hash{3}, 2, alice, alice.PublicKey().Address()),
+		// NFT is already minted
+		mint(types.Hash{1}, 3, alice, bob.PublicKey().Address()),
+	}
+	// the owner is right, but the signature isn't
+	forged := mint(types.Hash{4}, 4, bob, bob.PublicKey().Address())
+	forged.Payload.(*Mint).CollectionOwner = alice.PublicKey()
+	assert.Nil(t, forged.Sign(bob))
+	// the authorization was signed for another chain
+	replayed := mint(types.Hash{5}, 5, alice, bob.PublicKey().Address())
+	assert.Nil(t, replayed.Payload.(*Mint).Sign(alice, testGenesis.ChainID+1, bob.PublicKey().Address()))
+	assert.Nil(t, replayed.Sign(bob))
+	txs = append(txs, forged, replayed)
+
+	assert.Nil(t, bc.AddBlock(nextBlock(t, bc, txs)))
+
+	errs := []error{nil, ErrMintNotAuthorized, ErrMintNotAuthorized, ErrNFTExists, ErrMintNotAuthorized, ErrMintNotAuthorized}
+	for i, tx := range txs {
+		receipt, err := bc.GetReceipt(tx.Hash(TransactionHasher{}))
+		assert.Nil(t, err)
+		if errs[i] == nil {
+			assert.Equal(t, ReceiptStatusSuccess, receipt.Status)
+		} else {
+			assert.Equal(t, ReceiptStatusFailed, receipt.Status)
+			assert.Contains(t, receipt.Error, errs[i].Error())
+		}
+	}
+	nfts, err := bc.GetNFTsByCollection(coll)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(nfts))
+	assert.Equal(t, types.Hash{1}, nfts[0].Hash)
+	assert.Equal(t, bob.PublicKey().Address(), nfts[0].Owner)
+}
+
+func TestNFTOwnership(t *testing.T) {
+	bc, _ := NewBlockchain(NewMemoryStore(), CreateGenesisBlock(testGenesis))
+	alice := crypto.GeneratePrivateKey()
+	bob := crypto.GeneratePrivateKey()
+	aliceAddr, bobAddr := alice.PublicKey().Address(), bob.PublicKey().Address()
+
+	collTx := NewTransaction(&CreateCollection{MetaData: []byte("coll")})
+	assert.Nil(t, collTx.Sign(alice))
+	coll := collTx.Hash(TransactionHasher{})
+	mint := &Mint{NFT: types.Hash{1}, Collection: coll, MetaData: []byte("nft")}
+	assert.Nil(t, mint.Sign(alice, testGenesis.ChainID, aliceAddr))
+	mintTx := NewTransaction(mint)
+	mintTx.Nonce = 1
+	assert.Nil(t, mintTx.Sign(alice))
+	assert.Nil(t, bc.AddBlock(nextBlock(t, bc, []*Transaction{collTx, mintTx})))
+	assert.Equal(t, 1, len(bc.GetNFTsByOwner(aliceAddr)))
+
+	transferTx := NewTransaction(&TransferNFT{NFT: types.Hash{1}, To: bobAddr})
+	transferTx.Nonce = 2
+	assert.Nil(t, transferTx.Sign(alice))
+	// alice doesn't own the NFT after the transfer
+	stolenTx := NewTransaction(&TransferNFT{NFT: types.Hash{1}, To: aliceAddr})
+	stolenTx.Nonce = 3
+	assert.Nil(t, stolenTx.Sign(alice))
+	assert.Nil(t, bc.AddBlock(nextBlock(t, bc, []*Transaction{transferTx, stolenTx})))
+
+	receipt, err := bc.GetReceipt(stolenTx.Hash(TransactionHasher{}))
+	assert.Nil(t, err)
+	assert.Equal(t, ReceiptStatusFailed, receipt.Status)
+	assert.Contains(t, receipt.Error, ErrNotNFTOwner.Error())
+	assert.Empty(t, bc.GetNFTsByOwner(aliceAddr))
+	owned := bc.GetNFTsByOwner(bobAddr)
+	assert.Equal(t, 1, len(owned))
+	assert.Equal(t, []byte("nft"), owned[0].MetaData)
+
+	burnTx := NewTransaction(&BurnNFT{NFT: types.Hash{1}})
+	assert.Nil(t, burnTx.Sign(bob))
+	assert.Nil(t, bc.AddBlock(nextBlock(t, bc, []*Transaction{burnTx})))
+	assert.Empty(t, bc.GetNFTsByOwner(bobAddr))
+	nfts, err := bc.GetNFTsByCollection(coll)
+	assert.Nil(t, err)
+	assert.Empty(t, nfts)
+
+	nft, err := bc.GetNFT(types.Hash{1})
+	assert.Nil(t, err)
+	assert.True(t, nft.Burned)
+	assert.Equal(t, []*NFTOwnership{
+		{Owner: aliceAddr, TransactionHash: mintTx.Hash(TransactionHasher{}), BlockHeight: 1},
+		{Owner: bobAddr, TransactionHash: transferTx.Hash(TransactionHasher{}), BlockHeight: 2},
+		{TransactionHash: burnTx.Hash(TransactionHasher{}), BlockHeight: 3},
+	}, nft.History)
+
+	// burned NFT can't be minted again
+	mintTx = NewTransaction(mint)
+	mintTx.Nonce = 4
+	assert.Nil(t, mintTx.Sign(alice))
+	assert.Nil(t, bc.AddBlock(nextBlock(t, bc, []*Transaction{mintTx})))
+	receipt, err = bc.GetReceipt(mintTx.Hash(TransactionHasher{}))
+	assert.Nil(t, err)
+	assert.Contains(t, receipt.Error, ErrNFTExists.Error())
+
+	_, err = bc.GetNFTsByCollection(types.Hash{2})
+	assert.NotNil(t, err)
+}
+
+func TestContractStorageIsolation(t *testing.T) {
+	bc, _ := NewBlockchain(NewMemoryStore(), CreateGenesisBlock(testGenesis))
+	bob := crypto.GeneratePrivateKey()
+
+	ins := new(Instr)
+	ins.Add(2, 3).String("hey").Store()
+	first := deployContract(t, bc, bob, ins.Bytes())
+
+	// the same key in another contract
+	ins = new(Instr)
+	ins.Add(4, 4).String("hey").Store()
+	second := deployContract(t, bc, bob, ins.Bytes())
+	assert.NotEqual(t, first, second)
+
+	// reads the key written by another contract
+	ins = new(Instr)
+	ins.Get("hey").Return()
+	reader := deployContract(t, bc, bob, ins.Bytes())
+
+	code, err := bc.contractState.Code(first)
+	assert.Nil(t, err)
+	assert.Equal(t, new(Instr).Add(2, 3).String("hey").Store().Bytes(), code)
+	// deployment doesn't execute the code
+	_, err = contractValue(bc, first, "hey")
+	assert.NotNil(t, err)
+
+	calls := []*Transaction{
+		newContractCall(first, 3),
+		newContractCall(second, 4),
+		newContractCall(reader, 5),
+	}
+	for _, tx := range calls {
+		assert.Nil(t, tx.Sign(bob))
+	}
+	assert.Nil(t, bc.AddBlock(nextBlock(t, bc, calls)))
+
+	value, err := contractValue(bc, first, "hey")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(5), new(big.Int).SetBytes(value).Int64())
+	value, err = contractValue(bc, second, "hey")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(8), new(big.Int).SetBytes(value).Int64())
+
+	receipt, err := bc.GetReceipt(calls[2].Hash(TransactionHasher{}))
+	assert.Nil(t, err)
+	assert.Equal(t, ReceiptStatusFailed, receipt.Status)
+
+	// input sent to an account without code
+	tx := newContractCall(crypto.GeneratePrivateKey().PublicKey().Address(), 6)
+	tx.Payload.(*Call).Input = []byte{byte(InstrPush1), 1}
+	assert.Nil(t, tx.Sign(bob))
+	assert.Nil(t, bc.AddBlock(nextBlock(t, bc, []*Transaction{tx})))
+	receipt, err = bc.GetReceipt(tx.Hash(TransactionHasher{}))
+	assert.Nil(t, err)
+	assert.Equal(t, ReceiptStatusFailed, receipt.Status)
+}
+
+func TestReceipts(t *testing.T) {
+	bc, _ := NewBlockchain(NewMemoryStore(), CreateGenesisBlock(testGenesis))
+	bob := crypto.GeneratePrivateKey()
+
+	ins := new(Instr)
+	ins.Get("missing")
+	contract := deployContract(t, bc, bob, ins.Bytes())
+
+	ins = new(Instr)
+	ins.Add(2, 3).String("hey").Store()
+	tx := NewTransaction(&Deploy{Code: ins.Bytes()})
+	tx.Nonce = 1
+	assert.Nil(t, tx.Sign(bob))
+
+	failedTx := newContractCall(contract, 2)
+	assert.Nil(t, failedTx.Sign(bob))
+
+	block := nextBlock(t, bc, []*Transaction{tx, failedTx})
+	assert.Nil(t, bc.AddBlock(block))
+
+	receipt, err := bc.GetReceipt(tx.Hash(TransactionHasher{}))
+	assert.Nil(t, err)
+	assert.Equal(t, ReceiptStatusSuccess, receipt.Status)
+	assert.Equal(t, ContractAddress(bob.PublicKey().Address(), 1), receipt.ContractAddress)
+	assert.Equal(t, uint32(2), receipt.BlockHeight)
+	assert.Equal(t, uint32(0), receipt.Index)
+
+	// failed transaction is still included in the blockchain
+	_, err = bc.GetTransaction(failedTx.Hash(TransactionHasher{}))
+	assert.Nil(t, err)
+	receipt, err = bc.GetReceipt(failedTx.Hash(TransactionHasher{}))
+	assert.Nil(t, err)
+	assert.Equal(t, ReceiptStatusFailed, receipt.Status)
+	assert.NotEmpty(t, receipt.Error)
+	assert.True(t, receipt.ContractAddress.IsZero())
+	assert.Equal(t, uint32(1), receipt.Index)
+
+	receipts, err := bc.GetBlockReceipts(2)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(receipts))
+	assert.Equal(t, block.ReceiptsHash, HashReceipts(receipts))
+
+	// block with a forged receipts hash is rejected
+	failedTx = newContractCall(contract, 3)
+	assert.Nil(t, failedTx.Sign(bob))
+	forged := nextBlock(t, bc, []*Transaction{failedTx})
+	forged.ReceiptsHash = HashReceipts([]*Receipt{{
+		TransactionHash: failedTx.Hash(TransactionHasher{}),
+		Status:          ReceiptStatusSuccess,
+		BlockHeight:     forged.Height,
+	}})
+	assert.Nil(t, forged.Sign(crypto.GeneratePrivateKey()))
+	assert.NotNil(t, bc.AddBlock(forged))
+}
+
+func TestNonces(t *testing.T) {
+	bc, _ := NewBlockchain(NewMemoryStore(), CreateGenesisBlock(testGenesis))
+	bob := crypto.GeneratePrivateKey()
+	alice := crypto.GeneratePrivateKey()
+	bc.accountsState.CreateAccount(bob.PublicKey().Address(), big.NewInt(1_000))
+
+	newTransfer := func(nonce uint64) *Transaction {
+		tx := NewTransaction(&Transfer{
+			To:    alice.PublicKey().Address(),
+			Value: big.NewInt(100),
+		})
+		tx.Nonce = nonce
+		assert.Nil(t, tx.Sign(bob))
+		return tx
+	}
+
+	assert.Nil(t, bc.AddBlock(nextBlock(t, bc, []*Transaction{newTransfer(0), newTransfer(1)})))
+	assert.Equal(t, uint64(2), bc.GetNonce(bob.PublicKey().Address()))
+
+	height := bc.Height() + 1
+	prevHeaderHash := getPrevBlockHash(t, bc, height)
+
+	// replayed transaction
+	block := randomBlock(t, prevHeaderHash, height, []*Transaction{newTransfer(1)})
+	assert.ErrorIs(t, bc.AddBlock(block), ErrNonceTooLow)
+	assert.ErrorIs(t, bc.FinalizeBlock(block, crypto.GeneratePrivateKey()), ErrNonceTooLow)
+
+	// gap in nonces
+	block = randomBlock(t, prevHeaderHash, height, []*Transaction{newTransfer(3)})
+	assert.ErrorIs(t, bc.AddBlock(block), ErrNonceTooHigh)
+
+	// the same nonce used twice in the block
+	block = randomBlock(t, prevHeaderHash, height, []*Transaction{newTransfer(2), newTransfer(2)})
+	assert.ErrorIs(t, bc.AddBlock(block), ErrNonceTooLow)
+
+	balance, _ := bc.accountsState.GetBalance(alice.PublicKey().Address())
+	assert.Equal(t, big.NewInt(200), balance)
+}
+
+func TestGasFees(t *testing.T) {
+	bc, _ := NewBlockchain(NewMemoryStore(), CreateGenesisBlock(testGenesis))
+	bob := crypto.GeneratePrivateKey()
+	validator := crypto.GeneratePrivateKey()
+	bc.accountsState.CreateAccount(bob.PublicKey().Address(), big.NewInt(1_000_000))
+
+	ins := new(Instr)
+	ins.Add(2, 3).String("hey").Store()
+	contract := deployContract(t, bc, bob, ins.Bytes())
+
+	tx := newContractCall(contract, 1)
+	tx.GasPrice = big.NewInt(2)
+	assert.Nil(t, tx.Sign(bob))
+
+	// out of gas, the whole gas limit is charged
+	failedTx := newContractCall(contract, 2)
+	failedTx.GasLimit = IntrinsicGas(failedTx) + 1
+	failedTx.GasPrice = big.NewInt(2)
+	assert.Nil(t, failedTx.Sign(bob))
+
+	block := randomBlock(t, getPrevBlockHash(t, bc, 2), 2, []*Transaction{tx, failedTx})
+	assert.Nil(t, bc.FinalizeBlock(block, validator))
+	assert.Nil(t, bc.AddBlock(block))
+
+	receipt, err := bc.GetReceipt(tx.Hash(TransactionHasher{}))
+	assert.Nil(t, err)
+	assert.Equal(t, ReceiptStatusSuccess, receipt.Status)
+	assert.Greater(t, receipt.GasUsed, IntrinsicGas(tx))
+	assert.Less(t, receipt.GasUsed, tx.GasLimit)
+
+	failedReceipt, err := bc.GetReceipt(failedTx.Hash(TransactionHasher{}))
+	assert.Nil(t, err)
+	assert.Equal(t, ReceiptStatusFailed, failedReceipt.Status)
+	assert.Equal(t, failedTx.GasLimit, failedReceipt.GasUsed)
+
+	fees := GasCost(receipt.GasUsed+failedReceipt.GasUsed, big.NewInt(2))
+	balance, _ := bc.accountsState.GetBalance(validator.PublicKey().Address())
+	assert.Equal(t, fees, balance)
+	balance, _ = bc.accountsState.GetBalance(bob.PublicKey().Address())
+	assert.Equal(t, new(big.Int).Sub(big.NewInt(1_000_000), fees), balance)
+}
+
+func TestGasInsufficientFunds(t *testing.T) {
+	bc, _ := NewBlockchain(NewMemoryStore(), CreateGenesisBlock(testGenesis))
+	bob := crypto.GeneratePrivateKey()
+	bc.accountsState.CreateAccount(bob.PublicKey().Address(), big.NewInt(1_000))
+
+	tx := NewTransaction(&Transfer{To: types.Address{1}, Value: big.NewInt(1)})
+	tx.GasPrice = big.NewInt(1)
+	assert.Nil(t, tx.Sign(bob))
+
+	block := randomBlock(t, getPrevBlockHash(t, bc, 1), 1, []*Transaction{tx})
+	assert.ErrorIs(t, bc.AddBlock(block), ErrInsufficientFunds)
+	assert.ErrorIs(t, bc.FinalizeBlock(block, crypto.GeneratePrivateKey()), ErrInsufficientFunds)
+
+	tx = NewTransaction(&Transfer{To: types.Address{1}, Value: big.NewInt(1)})
+	tx.GasLimit = IntrinsicGas(tx) - 1
+	assert.Nil(t, tx.Sign(bob))
+	block = randomBlock(t, getPrevBlockHash(t, bc, 1), 1, []*Transaction{tx})
+	assert.ErrorIs(t, bc.AddBlock(block), ErrIntrinsicGas)
+}
+
+func TestGasNegativePrice(t *testing.T) {
+	bc, _ := NewBlockchain(NewMemoryStore(), CreateGenesisBlock(testGenesis))
+	bob := crypto.GeneratePrivateKey()
+	bc.accountsState.CreateAccount(bob.PublicKey().Address(), big.NewInt(10))
+
+	// negative price would credit the sender with the gas cost
+	tx := NewTransaction(&Transfer{To: types.Address{1}, Value: big.NewInt(1)})
+	tx.GasPrice = big.NewInt(-1_000_000)
+	assert.Nil(t, tx.Sign(bob))
+	assert.ErrorIs(t, tx.Verify(), ErrInvalidTransaction)
+
+	block := randomBlock(t, getPrevBlockHash(t, bc, 1), 1, []*Transaction{tx})
+	assert.ErrorIs(t, bc.AddBlock(block), ErrInvalidTransaction)
+	assert.ErrorIs(t, bc.FinalizeBlock(block, crypto.GeneratePrivateKey()), ErrInvalidTransaction)
+	assert.Equal(t, uint32(0), bc.Height())
+	balance, _ := bc.accountsState.getBalance(bob.PublicKey().Address())
+	assert.Equal(t, big.NewInt(10), balance)
+}
+
+func TestGasLimits(t *testing.T) {
+	bc, _ := NewBlockchain(NewMemoryStore(), CreateGenesisBlock(testGenesis))
+	bob := crypto.GeneratePrivateKey()
+
+	// gas limit of a free transaction is capped, so its loop can't run forever
+	tx := NewTransaction(&Deploy{Code: []byte("hey")})
+	tx.GasLimit = MaxGasLimit + 1
+	assert.Nil(t, tx.Sign(bob))
+	assert.ErrorIs(t, tx.Verify(), ErrGasLimitTooHigh)
+	block := randomBlock(t, getPrevBlockHash(t, bc, 1), 1, []*Transaction{tx})
+	assert.ErrorIs(t, bc.AddBlock(block), ErrGasLimitTooHigh)
+
+	txs := make([]*Transaction, BlockGasLimit/MaxGasLimit+1)
+	for i := range txs {
+		txs[i] = NewTransaction(&Deploy{Code: []byte("hey")})
+		txs[i].Nonce = uint64(i)
+		txs[i].GasLimit = MaxGasLimit
+		assert.Nil(t, txs[i].Sign(bob))
+	}
+	block = randomBlock(t, getPrevBlockHash(t, bc, 1), 1, txs)
+	assert.ErrorIs(t, bc.AddBlock(block), ErrBlockGasLimit)
+	assert.ErrorIs(t, bc.FinalizeBlock(block, crypto.GeneratePrivateKey()), ErrBlockGasLimit)
+	assert.Nil(t, bc.AddBlock(nextBlock(t, bc, txs[:len(txs)-1])))
+}
+
+func TestGasMinPrice(t *testing.T) {
+	bc, _ := NewBlockchain(NewMemoryStore(), CreateGenesisBlock(testGenesis))
+	bc.SetMinGasPrice(big.NewInt(2))
+	bob := crypto.GeneratePrivateKey()
+	bc.accountsState.CreateAccount(bob.PublicKey().Address(), big.NewInt(1_000_000))
+
+	tx := NewTransaction(&Transfer{To: types.Address{1}, Value: big.NewInt(1)})
+	tx.GasPrice = big.NewInt(1)
+	assert.Nil(t, tx.Sign(bob))
+	assert.ErrorIs(t, bc.AddBlock(nextBlock(t, bc, []*Transaction{tx})), ErrGasPriceTooLow)
+
+	tx.GasPrice = nil
+	assert.Nil(t, tx.Sign(bob))
+	assert.ErrorIs(t, bc.AddBlock(nextBlock(t, bc, []*Transaction{tx})), ErrGasPriceTooLow)
+
+	tx.GasPrice = big.NewInt(2)
+	assert.Nil(t, tx.Sign(bob))
+	assert.Nil(t, bc.AddBlock(nextBlock(t, bc, []*Transaction{tx})))
+}
+
+func TestGenesisAlloc(t *testing.T) {
+	alice := crypto.GeneratePrivateKey()
+	bob := crypto.GeneratePrivateKey()
+	cfg := &GenesisConfig{
+		MinGasPrice: big.NewInt(1),
+		Alloc: []GenesisAccount{
+			{Address: alice.PublicKey().Address(), Balance: big.NewInt(1_000_000)},
+			{Address: bob.PublicKey().Address(), Balance: big.NewInt(2_000_000)},
+		},
+	}
+	assert.Equal(t, CreateGenesisBlock(cfg), CreateGenesisBlock(cfg))
+
+	bc, err := NewBlockchain(NewMemoryStore(), CreateGenesisBlock(cfg))
+	assert.Nil(t, err)
+	bc.SetMinGasPrice(cfg.MinGasPrice)
+	assert.Equal(t, big.NewInt(1_000_000), bc.GetBalance(alice.PublicKey().Address()))
+	assert.Equal(t, big.NewInt(2_000_000), bc.GetBalance(bob.PublicKey().Address()))
+
+	tx := NewTransaction(&Transfer{To: types.Address{1}, Value: big.NewInt(1)})
+	tx.GasPrice = big.NewInt(1)
+	assert.Nil(t, tx.Sign(alice))
+	assert.Nil(t, bc.AddBlock(nextBlock(t, bc, []*Transaction{tx})))
+}
+
+func TestSelectTransactions(t *testing.T) {
+	bc, _ := NewBlockchain(NewMemoryStore(), CreateGenesisBlock(testGenesis))
+	bob := crypto.GeneratePrivateKey()
+	bc.accountsState.CreateAccount(bob.PublicKey().Address(), big.NewInt(1_000_000))
+
+	newTx := func(priv *crypto.PrivateKey, nonce uint64) *Transaction {
+		tx := NewTransaction(&Transfer{To: types.Address{1}, Value: big.NewInt(1)})
+		tx.Nonce = nonce
+		tx.GasPrice = big.NewInt(1)
+		assert.Nil(t, tx.Sign(priv))
+		return tx
+	}
+	// the sender of the second transaction can't pay for gas
+	txs := []*Transaction{newTx(bob, 0), newTx(crypto.GeneratePrivateKey(), 0), newTx(bob, 1)}
+
+	prev, err := bc.GetBlock(bc.Height())
+	assert.Nil(t, err)
+	included, rejected := bc.SelectTransactions(prev.Header, crypto.GeneratePrivateKey().PublicKey(), txs)
+	assert.Equal(t, []*Transaction{txs[0], txs[2]}, included)
+	assert.Equal(t, []*Transaction{txs[1]}, rejected)
+	assert.Nil(t, bc.AddBlock(nextBlock(t, bc, included)))
+}
+
+func TestContractContext(t *testing.T) {
+	bc, _ := NewBlockchain(NewMemoryStore(), CreateGenesisBlock(testGenesis))
+	bob := crypto.GeneratePrivateKey()
+	bc.accountsState.CreateAccount(bob.PublicKey().Address(), big.NewInt(1_000))
+
+	// stores the caller and the block height, sends half of the value back to the caller
+	ins := new(Instr)
+	ins.Caller().String("caller").Store()
+	ins.BlockHeight().String("height").Store()
+	ins.Caller().CallValue().Int(2).op(InstrDiv).Transfer()
+	ins.SelfBalance().String("balance").Store()
+	contract := deployContract(t, bc, bob, ins.Bytes())
+
+	tx := newContractCall(contract, 1)
+	tx.Payload.(*Call).Value = big.NewInt(100)
+	assert.Nil(t, tx.Sign(bob))
+	assert.Nil(t, bc.AddBlock(nextBlock(t, bc, []*Transaction{tx})))
+
+	receipt, err := bc.GetReceipt(tx.Hash(TransactionHasher{}))
+	assert.Nil(t, err)
+	assert.Equal(t, ReceiptStatusSuccess, receipt.Status)
+
+	bobAddress := bob.PublicKey().Address()
+	value, err := contractValue(bc, contract, "caller")
+	assert.Nil(t, err)
+	assert.Equal(t, bobAddress[:], value[wordSize-len(bobAddress):])
+	value, err = contractValue(bc, contract, "height")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), new(big.Int).SetBytes(value).Int64())
+	value, err = contractValue(bc, contract, "balance")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(50), new(big.Int).SetBytes(value).Int64())
+
+	balance, _ := bc.accountsState.GetBalance(bobAddress)
+	assert.Equal(t, big.NewInt(950), balance)
+	balance, _ = bc.accountsState.GetBalance(contract)
+	assert.Equal(t, big.NewInt(50), balance)
+}
+
+func TestContractCall(t *testing.T) {
+	bc, _ := NewBlockchain(NewMemoryStore(), CreateGenesisBlock(testGenesis))
+	bob := crypto.GeneratePrivateKey()
+
+	ins := new(Instr)
+	ins.Caller().String("caller").Store()
+	ins.Int(42).Return()
+	callee := deployContract(t, bc, bob, ins.Bytes())
+
+	ins = new(Instr)
+	ins.Int(0).Address(callee).Int(10_000).Call().String("ok").Store()
+	ins.ReturnData().String("result").Store()
+	caller := deployContract(t, bc, bob, ins.Bytes())
+
+	tx := newContractCall(caller, 2)
+	assert.Nil(t, tx.Sign(bob))
+	assert.Nil(t, bc.AddBlock(nextBlock(t, bc, []*Transaction{tx})))
+
+	value, err := contractValue(bc, caller, "ok")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), new(big.Int).SetBytes(value).Int64())
+	value, err = contractValue(bc, caller, "result")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(42), new(big.Int).SetBytes(value).Int64())
+	value, err = contractValue(bc, callee, "caller")
+	assert.Nil(t, err)
+	assert.Equal(t, caller[:], value[wordSize-len(caller):])
+}
+
+func TestFilterLogs(t *testing.T) {
+	bc, _ := NewBlockchain(NewMemoryStore(), CreateGenesisBlock(testGenesis))
+	bob := crypto.GeneratePrivateKey()
+	topic := types.Hash{0xaa}
+
+	ins := new(Instr)
+	ins.BlockHeight().Topic(topic).Log(1)
+	contract := deployContract(t, bc, bob, ins.Bytes())
+
+	ins = new(Instr)
+	ins.String("failed").Topic(topic).Log(1).Get("missing")
+	failing := deployContract(t, bc, bob, ins.Bytes())
+
+	for nonce := uint64(2); nonce < 5; nonce++ {
+		tx := newContractCall(contract, nonce)
+		if nonce == 3 {
+			tx = newContractCall(failing, nonce)
+		}
+		assert.Nil(t, tx.Sign(bob))
+		assert.Nil(t, bc.AddBlock(nextBlock(t, bc, []*Transaction{tx})))
+	}
+
+	// failed transaction has no logs
+	block, err := bc.GetBlock(4)
+	assert.Nil(t, err)
+	assert.Equal(t, Bloom{}, block.LogsBloom)
+
+	logs, err := bc.FilterLogs(0, bc.Height(), &LogFilter{Topic: &topic})
+	assert.Nil(t, err)
+	assert.Len(t, logs, 2)
+	assert.Equal(t, uint32(3), logs[0].BlockHeight)
+	assert.Equal(t, uint32(5), logs[1].BlockHeight)
+	assert.Equal(t, int64(5), new(big.Int).SetBytes(logs[1].Data).Int64())
+	receipt, err := bc.GetReceipt(logs[1].TransactionHash)
+	assert.Nil(t, err)
+	assert.Equal(t, logs[1:], receipt.Logs)
+
+	logs, err = bc.FilterLogs(4, 5, &LogFilter{Address: &contract})
+	assert.Nil(t, err)
+	assert.Len(t, logs, 1)
+
+	otherTopic := types.Hash{0xbb}
+	logs, err = bc.FilterLogs(0, bc.Height(), &LogFilter{Topic: &otherTopic})
+	assert.Nil(t, err)
+	assert.Empty(t, logs)
+
+	_, err = bc.FilterLogs(3, 2, &LogFilter{})
+	assert.NotNil(t, err)
+	_, err = bc.FilterLogs(0, 100, &LogFilter{})
+	assert.NotNil(t, err)
+
+	// block with a forged bloom is rejected
+	tx := newContractCall(contract, 5)
+	assert.Nil(t, tx.Sign(bob))
+	forged := nextBlock(t, bc, []*Transaction{tx})
+	forged.LogsBloom = Bloom{}
+	assert.Nil(t, forged.Sign(crypto.GeneratePrivateKey()))
+	assert.NotNil(t, bc.AddBlock(forged))
+}
+
+func TestContractInput(t *testing.T) {
+	bc, _ := NewBlockchain(NewMemoryStore(), CreateGenesisBlock(testGenesis))
+	bob := crypto.GeneratePrivateKey()
+
+	// stores the first input word under the second one
+	contract := deployContract(t, bc, bob, new(Instr).Int(0).Input().Int(1).Input().SStore().Bytes())
+
+	tx := newContractCall(contract, 1)
+	tx.Payload.(*Call).Input = append(wordBytes(big.NewInt(42)), wordBytes(big.NewInt(7))...)
+	assert.Nil(t, tx.Sign(bob))
+	assert.Nil(t, bc.AddBlock(nextBlock(t, bc, []*Transaction{tx})))
+
+	value, err := bc.contractState.ContractStorage(contract).Get(wordBytes(big.NewInt(7)))
+	assert.Nil(t, err)
+	assert.Equal(t, wordBytes(big.NewInt(42)), value)
+}
+
+func TestTraceTransaction(t *testing.T) {
+	bc, _ := NewBlockchain(NewMemoryStore(), CreateGenesisBlock(testGenesis))
+	bob := crypto.GeneratePrivateKey()
+
+	// increments the counter and fails on the third call
+	ins := new(Instr).Int(0).SLoad().Int(1).op(InstrAdd).Dup(1).Int(0).SStore()
+	ins.Int(3).Eq().Not().JumpI(ins.Len() + 6).Int(0).Revert()
+	ins.JumpDest()
+	contract := deployContract(t, bc, bob, ins.Bytes())
+
+	var txs []*Transaction
+	for nonce := uint64(1); nonce <= 3; nonce++ {
+		tx := newContractCall(contract, nonce)
+		assert.Nil(t, tx.Sign(bob))
+		txs = append(txs, tx)
+	}
+	assert.Nil(t, bc.AddBlock(nextBlock(t, bc, txs[:1])))
+	assert.Nil(t, bc.AddBlock(nextBlock(t, bc, txs[1:])))
+
+	// the second call is replayed on the state after the first one
+	trace, err := bc.TraceTransaction(txs[1].Hash(TransactionHasher{}))
+	assert.Nil(t, err)
+	receipt, _ := bc.GetReceipt(txs[1].Hash(TransactionHasher{}))
+	assert.Equal(t, receipt.GasUsed, trace.GasUsed)
+	assert.Empty(t, trace.Error)
+	writes := trace.Steps[7].Writes
+	assert.Len(t, writes, 1)
+	assert.Equal(t, wordBytes(big.NewInt(2)), writes[0].Value)
+
+	// the third call is replayed after the second one in the same block
+	trace, err = bc.TraceTransaction(txs[2].Hash(TransactionHasher{}))
+	assert.Nil(t, err)
+	receipt, _ = bc.GetReceipt(txs[2].Hash(TransactionHasher{}))
+	assert.Equal(t, ReceiptStatusFailed, receipt.Status)
+	assert.Equal(t, receipt.Error, trace.Error)
+	assert.Equal(t, InstrRevert, trace.Steps[len(trace.Steps)-1].Op)
+	assert.Contains(t, trace.Steps[len(trace.Steps)-1].Error, ErrReverted.Error())
+
+	_, err = bc.TraceTransaction(types.Hash{})
+	assert.NotNil(t, err)
+
+	// states of blocks following the genesis are replayed from its checkpoint
+	assert.Len(t, bc.checkpoints, 1)
+}
+
+func TestSimulate(t *testing.T) {
+	bc, _ := NewBlockchain(NewMemoryStore(), CreateGenesisBlock(testGenesis))
+	bob := crypto.GeneratePrivateKey()
+	bc.accountsState.CreateAccount(bob.PublicKey().Address(), big.NewInt(1_000))
+
+	// increments the counter, logs and returns it
+	ins := new(Instr).Int(0).SLoad().Int(1).op(InstrAdd).Dup(1).Int(0).SStore()
+	ins.Dup(1).Log(0).Return()
+	contract := deployContract(t, bc, bob, ins.Bytes())
+	stateRoot := bc.StateRoot()
+
+	tx := newContractCall(contract, 0)
+	tx.From = bob.PublicKey()
+	tx.Payload.(*Call).Value = big.NewInt(10)
+	result, err := bc.Simulate(tx)
+	assert.Nil(t, err)
+	assert.Empty(t, result.Error)
+	assert.Equal(t, wordBytes(big.NewInt(1)), result.ReturnData)
+	assert.Len(t, result.Logs, 1)
+	assert.Greater(t, result.GasUsed, IntrinsicGas(tx))
+
+	// nothing is persisted
+	assert.Equal(t, stateRoot, bc.StateRoot())
+	result, err = bc.Simulate(tx)
+	assert.Nil(t, err)
+	assert.Equal(t, wordBytes(big.NewInt(1)), result.ReturnData)
+
+	// the gas used by the simulation is enough for the real transaction
+	call := newContractCall(contract, 1)
+	call.GasLimit = result.GasUsed
+	assert.Nil(t, call.Sign(bob))
+	assert.Nil(t, bc.AddBlock(nextBlock(t, bc, []*Transaction{call})))
+	receipt, _ := bc.GetReceipt(call.Hash(TransactionHasher{}))
+	assert.Equal(t, ReceiptStatusSuccess, receipt.Status)
+	assert.Equal(t, result.GasUsed, receipt.GasUsed)
+
+	tx.Payload.(*Call).Value = big.NewInt(2_000)
+	result, err = bc.Simulate(tx)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, result.Error)
+
+	deployment := NewTransaction(&Deploy{Code: ins.Bytes()})
+	deployment.From = bob.PublicKey()
+	deployment.Nonce = bc.GetNonce(bob.PublicKey().Address())
+	result, err = bc.Simulate(deployment)
+	assert.Nil(t, err)
+	assert.Equal(t, ContractAddress(bob.PublicKey().Address(), deployment.Nonce), result.ContractAddress)
+
+	tx.GasPrice = big.NewInt(1_000)
+	_, err = bc.Simulate(tx)
+	assert.ErrorIs(t, err, ErrInsufficientFunds)
+
+	// the endless loop runs out of the clamped gas
+	loopContract := deployContract(t, bc, bob, new(Instr).JumpDest().Jump(0).Bytes())
+	loop := newContractCall(loopContract, 0)
+	loop.From = bob.PublicKey()
+	loop.GasLimit = math.MaxUint64
+	result, err = bc.Simulate(loop)
+	assert.Nil(t, err)
+	assert.Contains(t, result.Error, ErrOutOfGas.Error())
+	assert.Equal(t, MaxGasLimit, result.GasUsed)
+	assert.Equal(t, uint64(math.MaxUint64), loop.GasLimit)
+}
+
+func TestContractStorageQuery(t *testing.T) {
+	bc, _ := NewBlockchain(NewMemoryStore(), CreateGenesisBlock(testGenesis))
+	bob := crypto.GeneratePrivateKey()
+
+	ins := new(Instr)
+	for _, key := range []string{"b1", "a2", "a1", "a3"} {
+		ins.String("value-" + key).String(key).Store()
+	}
+	contract := deployContract(t, bc, bob, ins.Bytes())
+	call := newContractCall(contract, 1)
+	assert.Nil(t, call.Sign(bob))
+	assert.Nil(t, bc.AddBlock(nextBlock(t, bc, []*Transaction{call})))
+
+	block, _ := bc.GetBlock(bc.Height())
+	verify := func(query *StorageQuery) {
+		assert.Equal(t, block.StateRoot, query.StateRoot)
+		for _, entry := range query.Entries {
+			assert.Nil(t, VerifyStorageEntry(query.StateRoot, query.AccountsRoot, query.ContractRoot, query.NFTsRoot, contract, entry))
+		}
+	}
+
+	query := bc.GetStorage(contract, []byte("a2"))
+	verify(query)
+	assert.Equal(t, []byte("value-a2"), query.Entries[0].Value)
+
+	// absence of the key is proven as well
+	query = bc.GetStorage(contract, []byte("a4"))
+	verify(query)
+	assert.Nil(t, query.Entries[0].Value)
+
+	query = bc.GetStorageRange(contract, []byte("a"), nil, 2)
+	verify(query)
+	assert.Len(t, query.Entries, 2)
+	assert.Equal(t, []byte("a1"), query.Entries[0].Key)
+	assert.Equal(t, []byte("a2"), query.Entries[1].Key)
+	assert.Equal(t, []byte("a3"), query.Next)
+
+	query = bc.GetStorageRange(contract, []byte("a"), query.Next, 2)
+	assert.Len(t, query.Entries, 1)
+	assert.Equal(t, []byte("value-a3"), query.Entries[0].Value)
+	assert.Nil(t, query.Next)
+
+	query = bc.GetStorageRange(contract, nil, nil, 10)
+	assert.Len(t, query.Entries, 4)
+	assert.Empty(t, bc.GetStorageRange(types.Address{1}, nil, nil, 10).Entries)
+
+	// forged value fails the verification
+	entry := query.Entries[0]
+	entry.Value = []byte("forged")
+	assert.NotNil(t, VerifyStorageEntry(query.StateRoot, query.AccountsRoot, query.ContractRoot, query.NFTsRoot, contract, entry))
+	assert.NotNil(t, VerifyStorageEntry(types.Hash{}, query.AccountsRoot, query.ContractRoot, query.NFTsRoot, contract, query.Entries[1]))
+}
+
+func TestChainID(t *testing.T) {
+	store := NewMemoryStore()
+	bc, err := NewBlockchain(store, CreateGenesisBlock(DefaultGenesisConfig()))
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(DefaultChainID), bc.ChainID())
+
+	bob := crypto.GeneratePrivateKey()
+	alice := crypto.GeneratePrivateKey()
+	bc.accountsState.CreateAccount(bob.PublicKey().Address(), big.NewInt(1_000_000))
+
+	newTransfer := func(chainID, nonce uint64) *Transaction {
+		tx := NewTransaction(&Transfer{
+			To:    alice.PublicKey().Address(),
+			Value: big.NewInt(100),
+		})
+		tx.ChainID = chainID
+		tx.Nonce = nonce
+		assert.Nil(t, tx.Sign(bob))
+		return tx
+	}
+
+	// a transaction signed for another chain can't be moved to this one
+	tx := newTransfer(2, 0)
+	block := nextBlock(t, bc, []*Transaction{tx})
+	assert.NotNil(t, bc.AddBlock(block))
+	tx.ChainID = DefaultChainID
+	assert.NotNil(t, tx.Verify())
+
+	block = nextBlock(t, bc, []*Transaction{newTransfer(DefaultChainID, 0)})
+	assert.Equal(t, uint64(DefaultChainID), block.ChainID)
+	assert.Nil(t, bc.AddBlock(block))
+
+	// the chain id is covered by the header signature
+	block = nextBlock(t, bc, []*Transaction{newTransfer(DefaultChainID, 1)})
+	block.ChainID = 2
+	assert.ErrorIs(t, bc.AddBlock(block), ErrInvalidChainID)
+	block.Transactions = nil
+	block.TransactionsHash, _ = HashTransactions(nil)
+	assert.ErrorContains(t, block.Verify(), "invalid block signature")
+
+	_, err = NewBlockchain(store, CreateGenesisBlock(&GenesisConfig{ChainID: 2}))
+	assert.ErrorIs(t, err, ErrInvalidChainID)
+}
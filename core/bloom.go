@@ -0,0 +1,95 @@
+package core
+
+import (
+	"blockchain/types"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+)
+
+// BloomSize is the size of the bloom filter in bytes
+const BloomSize = 256
+
+// bloomHashes is the number of bits set for every added value
+const bloomHashes = 3
+
+// Bloom is a bloom filter of log addresses and topics.
+// It may report that a value was added when it wasn't, but never the other way around.
+type Bloom [BloomSize]byte
+
+func (b *Bloom) Add(data []byte) {
+	for _, bit := range bloomBits(data) {
+		b[BloomSize-1-bit/8] |= 1 << (bit % 8)
+	}
+}
+
+// Test reports whether the data may have been added to the bloom filter
+func (b *Bloom) Test(data []byte) bool {
+	for _, bit := range bloomBits(data) {
+		if b[BloomSize-1-bit/8]&(1<<(bit%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (b Bloom) String() string {
+	return hex.EncodeToString(b[:])
+}
+
+// bloomBits returns positions of the bits set for the data
+func bloomBits(data []byte) [bloomHashes]uint {
+	hash := sha256.Sum256(data)
+	var bits [bloomHashes]uint
+	for i := range bits {
+		bits[i] = uint(binary.BigEndian.Uint16(hash[2*i:])) % (BloomSize * 8)
+	}
+	return bits
+}
+
+// LogsBloom returns bloom filter of addresses and topics of the receipts logs
+func LogsBloom(receipts []*Receipt) Bloom {
+	var bloom Bloom
+	for _, r := range receipts {
+		for _, log := range r.Logs {
+			bloom.Add(log.Address[:])
+			for _, topic := range log.Topics {
+				bloom.Add(topic[:])
+			}
+		}
+	}
+	return bloom
+}
+
+// LogFilter selects logs by address and topic, nil fields match any log
+type LogFilter struct {
+	Address *types.Address
+	Topic   *types.Hash
+}
+
+// MayMatch reports whether the bloom filter may contain logs matching the filter
+func (f *LogFilter) MayMatch(bloom *Bloom) bool {
+	if f.Address != nil && !bloom.Test(f.Address[:]) {
+		return false
+	}
+	if f.Topic != nil && !bloom.Test(f.Topic[:]) {
+		return false
+	}
+	return true
+}
+
+// Match reports whether the log matches the filter
+func (f *LogFilter) Match(log *Log) bool {
+	if f.Address != nil && *f.Address != log.Address {
+		return false
+	}
+	if f.Topic == nil {
+		return true
+	}
+	for _, topic := range log.Topics {
+		if topic == *f.Topic {
+			return true
+		}
+	}
+	return false
+}
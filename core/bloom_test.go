@@ -0,0 +1,52 @@
+package core
+
+import (
+	"blockchain/types"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestBloom(t *testing.T) {
+	var bloom Bloom
+	for i := 0; i < 10; i++ {
+		bloom.Add([]byte(fmt.Sprintf("value-%d", i)))
+	}
+	for i := 0; i < 10; i++ {
+		assert.True(t, bloom.Test([]byte(fmt.Sprintf("value-%d", i))))
+	}
+
+	falsePositives := 0
+	for i := 10; i < 1010; i++ {
+		if bloom.Test([]byte(fmt.Sprintf("value-%d", i))) {
+			falsePositives++
+		}
+	}
+	assert.Less(t, falsePositives, 10)
+}
+
+func TestLogFilter(t *testing.T) {
+	address := types.Address{1}
+	topic := types.Hash{2}
+	log := &Log{Address: address, Topics: []types.Hash{{3}, topic}}
+	bloom := LogsBloom([]*Receipt{{Logs: []*Log{log}}})
+
+	otherAddress := types.Address{4}
+	otherTopic := types.Hash{5}
+	tests := []struct {
+		filter *LogFilter
+		match  bool
+	}{
+		{&LogFilter{}, true},
+		{&LogFilter{Address: &address}, true},
+		{&LogFilter{Topic: &topic}, true},
+		{&LogFilter{Address: &address, Topic: &topic}, true},
+		{&LogFilter{Address: &otherAddress}, false},
+		{&LogFilter{Address: &address, Topic: &otherTopic}, false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.match, tt.filter.Match(log))
+		assert.Equal(t, tt.match, tt.filter.MayMatch(&bloom))
+	}
+}
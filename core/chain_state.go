@@ -0,0 +1,366 @@
+package core
+
+import (
+	"blockchain/types"
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"slices"
+)
+
+// chainState is the state transactions are applied to.
+// A block is executed on a copy of the current state
+// which replaces the current one only after the whole block is applied.
+type chainState struct {
+	accountsState *AccountsState
+	contractState *State
+	nfts          *NFTRegistry
+}
+
+// stateSnapshot identifies a revision of chainState
+type stateSnapshot struct {
+	accountsState int
+	contractState int
+	nfts          int
+}
+
+func newChainState(accountsState *AccountsState) *chainState {
+	return &chainState{
+		accountsState: accountsState,
+		contractState: NewState(),
+		nfts:          NewNFTRegistry(),
+	}
+}
+
+func (s *chainState) copy() *chainState {
+	return &chainState{
+		accountsState: s.accountsState.Copy(),
+		contractState: s.contractState.Copy(),
+		nfts:          s.nfts.Copy(),
+	}
+}
+
+func (s *chainState) snapshot() stateSnapshot {
+	return stateSnapshot{
+		accountsState: s.accountsState.Snapshot(),
+		contractState: s.contractState.Snapshot(),
+		nfts:          s.nfts.Snapshot(),
+	}
+}
+
+// revertToSnapshot discards all changes made after the snapshot was taken
+func (s *chainState) revertToSnapshot(snap stateSnapshot) {
+	s.accountsState.RevertToSnapshot(snap.accountsState)
+	s.contractState.RevertToSnapshot(snap.contractState)
+	s.nfts.RevertToSnapshot(snap.nfts)
+}
+
+// root commits to accounts, contract state and the NFT registry
+func (s *chainState) root() types.Hash {
+	return stateRoot(s.accountsState.Root(), s.contractState.Root(), s.nfts.Root())
+}
+
+func stateRoot(accountsRoot, contractRoot, nftsRoot types.Hash) types.Hash {
+	return sha256.Sum256(slices.Concat(accountsRoot[:], contractRoot[:], nftsRoot[:]))
+}
+
+// VerifyStorageEntry checks the entry of the contract storage against the state root
+// made of the given accounts, contract and NFT registry roots
+func VerifyStorageEntry(root, accountsRoot, contractRoot, nftsRoot types.Hash, addr types.Address, entry *StorageEntry) error {
+	if stateRoot(accountsRoot, contractRoot, nftsRoot) != root {
+		return fmt.Errorf("accounts root (%s), contract root (%s) and NFTs root (%s) don't match state root (%s)",
+			accountsRoot, contractRoot, nftsRoot, root)
+	}
+	value, err := VerifyProof(contractRoot, storageKey(addr, entry.Key), entry.Proof)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(value, entry.Value) {
+		return fmt.Errorf("proof of key (%x) doesn't match its value", entry.Key)
+	}
+	return nil
+}
+
+// useNonce checks that the transaction has the next nonce of the sender and increments it
+func (s *chainState) useNonce(tx *Transaction) error {
+	from := tx.From.Address()
+	nonce := s.accountsState.GetNonce(from)
+	if tx.Nonce < nonce {
+		return fmt.Errorf("%w: transaction (%s) has nonce (%d), account (%s) has nonce (%d)",
+			ErrNonceTooLow, tx.Hash(TransactionHasher{}), tx.Nonce, from, nonce)
+	}
+	if tx.Nonce > nonce {
+		return fmt.Errorf("%w: transaction (%s) has nonce (%d), account (%s) has nonce (%d)",
+			ErrNonceTooHigh, tx.Hash(TransactionHasher{}), tx.Nonce, from, nonce)
+	}
+	s.accountsState.IncrementNonce(from)
+	return nil
+}
+
+// buyGas checks the gas limit of the transaction
+// and charges the sender for all the gas up front
+func (s *chainState) buyGas(tx *Transaction) error {
+	if intrinsicGas := IntrinsicGas(tx); tx.GasLimit < intrinsicGas {
+		return fmt.Errorf("%w: transaction (%s) has gas limit (%d), intrinsic gas (%d)",
+			ErrIntrinsicGas, tx.Hash(TransactionHasher{}), tx.GasLimit, intrinsicGas)
+	}
+
+	cost := GasCost(tx.GasLimit, tx.GasPrice)
+	if cost.Sign() == 0 {
+		return nil
+	}
+	if err := s.accountsState.SubBalance(tx.From.Address(), cost); err != nil {
+		return fmt.Errorf("%w: transaction (%s): %s", ErrInsufficientFunds, tx.Hash(TransactionHasher{}), err)
+	}
+	return nil
+}
+
+// settleGas refunds unused gas to the sender and pays for the used gas to the validator
+func (s *chainState) settleGas(tx *Transaction, gasUsed uint64, validator types.Address) {
+	if refund := GasCost(tx.GasLimit-gasUsed, tx.GasPrice); refund.Sign() > 0 {
+		s.accountsState.AddBalance(tx.From.Address(), refund)
+	}
+	if fee := GasCost(gasUsed, tx.GasPrice); fee.Sign() > 0 {
+		s.accountsState.AddBalance(validator, fee)
+	}
+}
+
+// applyTransaction executes the transaction with the given index in the block and returns its receipt,
+// an error is returned if the transaction can't be included in the block
+func (s *chainState) applyTransaction(tx *Transaction, b *Block, index int, tracer Tracer) (*Receipt, error) {
+	if err := tx.Validate(); err != nil {
+		return nil, err
+	}
+	if err := s.useNonce(tx); err != nil {
+		return nil, err
+	}
+	if err := s.buyGas(tx); err != nil {
+		return nil, err
+	}
+
+	receipt := &Receipt{
+		TransactionHash: tx.Hash(TransactionHasher{}),
+		Status:          ReceiptStatusSuccess,
+		BlockHeight:     b.Height,
+		Index:           uint32(index),
+	}
+
+	intrinsicGas := IntrinsicGas(tx)
+	snap := s.snapshot()
+	res, err := s.handleTransaction(tx, b.Header, tx.GasLimit-intrinsicGas, tracer)
+	if err != nil {
+		s.revertToSnapshot(snap)
+		receipt.Status = ReceiptStatusFailed
+		receipt.Error = err.Error()
+	} else {
+		for _, log := range res.logs {
+			log.BlockHeight = b.Height
+			log.TransactionHash = receipt.TransactionHash
+		}
+		receipt.Logs = res.logs
+		if tx.IsDeployment() {
+			receipt.ContractAddress = ContractAddress(tx.From.Address(), tx.Nonce)
+		}
+	}
+	receipt.GasUsed = intrinsicGas + res.gasUsed
+	s.settleGas(tx, receipt.GasUsed, b.Validator.Address())
+
+	return receipt, nil
+}
+
+// applyTransactions applies all transactions of the block and returns their receipts
+func (s *chainState) applyTransactions(b *Block) ([]*Receipt, error) {
+	receipts := make([]*Receipt, len(b.Transactions))
+	for i, tx := range b.Transactions {
+		receipt, err := s.applyTransaction(tx, b, i, nil)
+		if err != nil {
+			return nil, err
+		}
+		receipts[i] = receipt
+	}
+	return receipts, nil
+}
+
+// executionResult is the outcome of the transaction execution
+type executionResult struct {
+	gasUsed uint64
+	// logs are set only if the execution succeeded
+	logs       []*Log
+	returnData []byte
+}
+
+// txHandler applies the payload of the transaction included in the block with the given header
+// with the given amount of gas
+type txHandler func(s *chainState, tx *Transaction, header *Header, gas uint64, tracer Tracer) (*executionResult, error)
+
+// txHandlers holds handlers of the registered transaction types
+var txHandlers = map[TxType]txHandler{
+	TxTypeTransfer:         (*chainState).handleTransfer,
+	TxTypeDeploy:           (*chainState).handleDeploy,
+	TxTypeCall:             (*chainState).handleCall,
+	TxTypeCreateCollection: (*chainState).handleCreateCollection,
+	TxTypeMint:             (*chainState).handleMint,
+	TxTypeTransferNFT:      (*chainState).handleTransferNFT,
+	TxTypeBurnNFT:          (*chainState).handleBurnNFT,
+}
+
+// handleTransaction executes the transaction included in the block with the given header
+// with the given amount of gas, the result is returned even if the execution failed
+func (s *chainState) handleTransaction(tx *Transaction, header *Header, gas uint64, tracer Tracer) (*executionResult, error) {
+	handler, ok := txHandlers[tx.Type()]
+	if !ok {
+		return &executionResult{}, fmt.Errorf("%w: unknown transaction type (%s)", ErrInvalidTransaction, tx.Type())
+	}
+	return handler(s, tx, header, gas, tracer)
+}
+
+func (s *chainState) handleTransfer(tx *Transaction, _ *Header, _ uint64, _ Tracer) (*executionResult, error) {
+	p := tx.Payload.(*Transfer)
+	if s.contractState.HasCode(p.To) {
+		return &executionResult{}, fmt.Errorf("%w: transfer to contract (%s), value is sent to contracts by calls",
+			ErrInvalidTransaction, p.To)
+	}
+	return &executionResult{}, s.accountsState.Transfer(tx.From.Address(), p.To, p.Value)
+}
+
+func (s *chainState) handleDeploy(tx *Transaction, _ *Header, _ uint64, _ Tracer) (*executionResult, error) {
+	p := tx.Payload.(*Deploy)
+	to := ContractAddress(tx.From.Address(), tx.Nonce)
+	if err := s.sendValue(tx, to, p.Value); err != nil {
+		return &executionResult{}, err
+	}
+	if err := s.contractState.SetCode(to, p.Code); err != nil {
+		return &executionResult{}, err
+	}
+	slog.Info("Deployed contract:", "address", to)
+	return &executionResult{}, nil
+}
+
+func (s *chainState) handleCall(tx *Transaction, header *Header, gas uint64, tracer Tracer) (*executionResult, error) {
+	res := &executionResult{}
+	p := tx.Payload.(*Call)
+	if !s.contractState.HasCode(p.Contract) {
+		return res, fmt.Errorf("account (%s) has no contract code to execute", p.Contract)
+	}
+
+	// value is transferred before the contract execution, so the contract can use it
+	if err := s.sendValue(tx, p.Contract, p.Value); err != nil {
+		return res, err
+	}
+
+	code, err := s.contractState.Code(p.Contract)
+	if err != nil {
+		return res, err
+	}
+	ctx := &Context{
+		Caller:      tx.From.Address(),
+		Address:     p.Contract,
+		Value:       p.Value,
+		BlockHeight: header.Height,
+		Timestamp:   header.Timestamp,
+		Input:       p.Input,
+	}
+	vm := NewVM(ctx, code, s.contractState, s.accountsState, gas)
+	vm.SetTracer(tracer)
+	err = vm.Run()
+	res.gasUsed = vm.GasUsed()
+	res.returnData = vm.ReturnData()
+	if err != nil {
+		return res, err
+	}
+	res.logs = vm.Logs()
+	slog.Info("Contract state:", "address", p.Contract, "result", s.contractState.Root())
+	return res, nil
+}
+
+// sendValue transfers the value of the transaction if it's positive
+func (s *chainState) sendValue(tx *Transaction, to types.Address, value *big.Int) error {
+	if value == nil || value.Sign() <= 0 {
+		return nil
+	}
+	return s.accountsState.Transfer(tx.From.Address(), to, value)
+}
+
+func (s *chainState) handleCreateCollection(tx *Transaction, _ *Header, _ uint64, _ Tracer) (*executionResult, error) {
+	p := tx.Payload.(*CreateCollection)
+	hash := tx.Hash(TransactionHasher{})
+	s.nfts.putCollection(hash, &Collection{
+		Owner:    tx.From,
+		MetaData: p.MetaData,
+		Fee:      p.Fee,
+	})
+	return &executionResult{}, nil
+}
+
+func (s *chainState) handleMint(tx *Transaction, header *Header, _ uint64, _ Tracer) (*executionResult, error) {
+	p := tx.Payload.(*Mint)
+	coll, ok := s.nfts.Collection(p.Collection)
+	if !ok {
+		return &executionResult{}, fmt.Errorf("collection (%s) doesn't exist on the blockchain", p.Collection)
+	}
+	if !bytes.Equal(coll.Owner, p.CollectionOwner) {
+		return &executionResult{}, fmt.Errorf("%w: (%s) isn't the owner of collection (%s)",
+			ErrMintNotAuthorized, p.CollectionOwner, p.Collection)
+	}
+	if err := p.Verify(tx.ChainID, tx.From.Address()); err != nil {
+		return &executionResult{}, err
+	}
+	if _, ok := s.nfts.NFT(p.NFT); ok {
+		return &executionResult{}, fmt.Errorf("%w (%s)", ErrNFTExists, p.NFT)
+	}
+	nft := &NFT{
+		Hash:       p.NFT,
+		Collection: p.Collection,
+		MetaData:   p.MetaData,
+	}
+	s.putNFT(nft.withOwner(newOwnership(tx, header, tx.From.Address())))
+	return &executionResult{}, nil
+}
+
+func (s *chainState) handleTransferNFT(tx *Transaction, header *Header, _ uint64, _ Tracer) (*executionResult, error) {
+	p := tx.Payload.(*TransferNFT)
+	nft, err := s.ownedNFT(tx, p.NFT)
+	if err != nil {
+		return &executionResult{}, err
+	}
+	s.putNFT(nft.withOwner(newOwnership(tx, header, p.To)))
+	return &executionResult{}, nil
+}
+
+func (s *chainState) handleBurnNFT(tx *Transaction, header *Header, _ uint64, _ Tracer) (*executionResult, error) {
+	p := tx.Payload.(*BurnNFT)
+	nft, err := s.ownedNFT(tx, p.NFT)
+	if err != nil {
+		return &executionResult{}, err
+	}
+	burned := nft.withOwner(newOwnership(tx, header, types.Address{}))
+	burned.Burned = true
+	s.putNFT(burned)
+	return &executionResult{}, nil
+}
+
+// ownedNFT returns the NFT if it's owned by the sender of the transaction
+func (s *chainState) ownedNFT(tx *Transaction, hash types.Hash) (*NFT, error) {
+	nft, ok := s.nfts.NFT(hash)
+	if !ok || nft.Burned {
+		return nil, fmt.Errorf("NFT (%s) doesn't exist on the blockchain", hash)
+	}
+	if from := tx.From.Address(); nft.Owner != from {
+		return nil, fmt.Errorf("%w: NFT (%s) is owned by (%s), not (%s)", ErrNotNFTOwner, hash, nft.Owner, from)
+	}
+	return nft, nil
+}
+
+func newOwnership(tx *Transaction, header *Header, owner types.Address) *NFTOwnership {
+	return &NFTOwnership{
+		Owner:           owner,
+		TransactionHash: tx.Hash(TransactionHasher{}),
+		BlockHeight:     header.Height,
+	}
+}
+
+func (s *chainState) putNFT(nft *NFT) {
+	s.nfts.put(nft)
+}
@@ -0,0 +1,402 @@
+package core
+
+import (
+	"blockchain/crypto"
+	"blockchain/types"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// The canonical binary encoding is used for hashing, signing, storage and the wire.
+// Every encoded message starts with the codec version byte, fields follow in the declared order
+// without names or padding:
+//   - integers are fixed size big endian, int64 is in two's complement
+//   - byte slices and strings are prefixed with their uint32 length, nil and empty are the same
+//   - hashes, addresses and blooms are written as is
+//   - big integers are a sign byte (0 for zero or nil, 1 positive, 2 negative) followed by
+//     the length prefixed magnitude without leading zeros if the integer isn't zero
+//   - optional values are a presence byte (0 or 1) followed by the value if present
+//
+// Decoding rejects everything the encoder doesn't produce, so every value has exactly one encoding.
+
+// hash domains precede the hashed encodings, so a signature is valid only for a single kind of value
+const (
+	transactionDomain = "blockchain/transaction"
+	headerDomain      = "blockchain/header"
+	mintDomain        = "blockchain/mint"
+	receiptDomain     = "blockchain/receipt"
+	trieNodeDomain    = "blockchain/trie-node"
+)
+
+// CodecVersion is the version of the binary encoding
+const CodecVersion byte = 1
+
+// MaxBytesLength is the maximum length of a decoded byte slice or string
+const MaxBytesLength = 1 << 24
+
+// signs of big integers
+const (
+	signZero byte = iota
+	signPositive
+	signNegative
+)
+
+var (
+	ErrCodecVersion = errors.New("unsupported codec version")
+	ErrNonCanonical = errors.New("non-canonical encoding")
+)
+
+// BinaryWriter appends values in the canonical binary encoding
+type BinaryWriter struct {
+	buf []byte
+}
+
+func NewBinaryWriter() *BinaryWriter {
+	return &BinaryWriter{}
+}
+
+// Bytes returns the encoded values
+func (w *BinaryWriter) Bytes() []byte {
+	return w.buf
+}
+
+func (w *BinaryWriter) WriteVersion() {
+	w.WriteUint8(CodecVersion)
+}
+
+func (w *BinaryWriter) WriteUint8(v byte) {
+	w.buf = append(w.buf, v)
+}
+
+func (w *BinaryWriter) WriteBool(v bool) {
+	if v {
+		w.WriteUint8(1)
+	} else {
+		w.WriteUint8(0)
+	}
+}
+
+func (w *BinaryWriter) WriteUint32(v uint32) {
+	w.buf = binary.BigEndian.AppendUint32(w.buf, v)
+}
+
+func (w *BinaryWriter) WriteUint64(v uint64) {
+	w.buf = binary.BigEndian.AppendUint64(w.buf, v)
+}
+
+func (w *BinaryWriter) WriteInt64(v int64) {
+	w.WriteUint64(uint64(v))
+}
+
+// WriteFixed writes the bytes without the length prefix
+func (w *BinaryWriter) WriteFixed(b []byte) {
+	w.buf = append(w.buf, b...)
+}
+
+func (w *BinaryWriter) WriteBytes(b []byte) {
+	w.WriteUint32(uint32(len(b)))
+	w.WriteFixed(b)
+}
+
+func (w *BinaryWriter) WriteString(s string) {
+	w.WriteBytes([]byte(s))
+}
+
+func (w *BinaryWriter) WriteBigInt(v *big.Int) {
+	switch {
+	case v == nil || v.Sign() == 0:
+		w.WriteUint8(signZero)
+		return
+	case v.Sign() > 0:
+		w.WriteUint8(signPositive)
+	default:
+		w.WriteUint8(signNegative)
+	}
+	w.WriteBytes(v.Bytes())
+}
+
+// BinaryReader reads values in the canonical binary encoding, the first error is kept
+// and the following reads return zero values
+type BinaryReader struct {
+	r   io.Reader
+	err error
+}
+
+func NewBinaryReader(r io.Reader) *BinaryReader {
+	return &BinaryReader{
+		r: r,
+	}
+}
+
+// Err returns the first error occurred while reading
+func (r *BinaryReader) Err() error {
+	return r.err
+}
+
+func (r *BinaryReader) fail(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+func (r *BinaryReader) read(n int) []byte {
+	if r.err != nil {
+		return make([]byte, n)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r.r, b); err != nil {
+		r.fail(err)
+	}
+	return b
+}
+
+// ReadVersion reads the codec version and fails if it isn't supported
+func (r *BinaryReader) ReadVersion() {
+	if v := r.ReadUint8(); r.err == nil && v != CodecVersion {
+		r.fail(fmt.Errorf("%w (%d)", ErrCodecVersion, v))
+	}
+}
+
+// ReadEOF fails if the input has bytes left after the decoded value
+func (r *BinaryReader) ReadEOF() {
+	if r.err != nil {
+		return
+	}
+	var b [1]byte
+	switch _, err := io.ReadFull(r.r, b[:]); err {
+	case nil:
+		r.fail(fmt.Errorf("%w: trailing bytes", ErrNonCanonical))
+	case io.EOF:
+	default:
+		r.fail(err)
+	}
+}
+
+func (r *BinaryReader) ReadUint8() byte {
+	return r.read(1)[0]
+}
+
+func (r *BinaryReader) ReadBool() bool {
+	v := r.ReadUint8()
+	if v > 1 {
+		r.fail(fmt.Errorf("%w: invalid bool (%d)", ErrNonCanonical, v))
+	}
+	return v == 1
+}
+
+func (r *BinaryReader) ReadUint32() uint32 {
+	return binary.BigEndian.Uint32(r.read(4))
+}
+
+func (r *BinaryReader) ReadUint64() uint64 {
+	return binary.BigEndian.Uint64(r.read(8))
+}
+
+func (r *BinaryReader) ReadInt64() int64 {
+	return int64(r.ReadUint64())
+}
+
+// ReadFixed fills b with the bytes written without the length prefix
+func (r *BinaryReader) ReadFixed(b []byte) {
+	copy(b, r.read(len(b)))
+}
+
+// ReadBytes reads the length prefixed bytes, empty bytes are returned as nil
+func (r *BinaryReader) ReadBytes() []byte {
+	size := r.ReadUint32()
+	if r.err != nil || size == 0 {
+		return nil
+	}
+	if size > MaxBytesLength {
+		r.fail(fmt.Errorf("bytes length (%d) exceeds maximum (%d)", size, MaxBytesLength))
+		return nil
+	}
+	return r.read(int(size))
+}
+
+func (r *BinaryReader) ReadString() string {
+	return string(r.ReadBytes())
+}
+
+// ReadBigInt reads the big integer, zero is returned as nil
+func (r *BinaryReader) ReadBigInt() *big.Int {
+	sign := r.ReadUint8()
+	if r.err != nil || sign == signZero {
+		return nil
+	}
+	if sign != signPositive && sign != signNegative {
+		r.fail(fmt.Errorf("%w: invalid integer sign (%d)", ErrNonCanonical, sign))
+		return nil
+	}
+	b := r.ReadBytes()
+	if r.err != nil {
+		return nil
+	}
+	if len(b) == 0 || b[0] == 0 {
+		r.fail(fmt.Errorf("%w: integer magnitude has leading zeros", ErrNonCanonical))
+		return nil
+	}
+	v := new(big.Int).SetBytes(b)
+	if sign == signNegative {
+		v.Neg(v)
+	}
+	return v
+}
+
+func (r *BinaryReader) readHash() types.Hash {
+	var h types.Hash
+	r.ReadFixed(h[:])
+	return h
+}
+
+func writeSignature(w *BinaryWriter, sig *crypto.Signature) {
+	w.WriteBool(sig != nil)
+	if sig != nil {
+		w.WriteBigInt(sig.R)
+		w.WriteBigInt(sig.S)
+	}
+}
+
+// readSignature reads the optional signature, R and S of a present signature can't be zero
+func readSignature(r *BinaryReader) *crypto.Signature {
+	if !r.ReadBool() {
+		return nil
+	}
+	sig := &crypto.Signature{
+		R: r.ReadBigInt(),
+		S: r.ReadBigInt(),
+	}
+	if r.err == nil && (sig.R == nil || sig.S == nil) {
+		r.fail(fmt.Errorf("%w: signature has zero component", ErrNonCanonical))
+		return nil
+	}
+	return sig
+}
+
+func writeHeader(w *BinaryWriter, h *Header) {
+	w.WriteUint32(h.Version)
+	w.WriteUint64(h.ChainID)
+	w.WriteFixed(h.TransactionsHash[:])
+	w.WriteFixed(h.StateRoot[:])
+	w.WriteFixed(h.ReceiptsHash[:])
+	w.WriteFixed(h.LogsBloom[:])
+	w.WriteFixed(h.PrevHeaderHash[:])
+	w.WriteUint32(h.Height)
+	w.WriteInt64(h.Timestamp)
+}
+
+func readHeader(r *BinaryReader) *Header {
+	h := &Header{
+		Version:          r.ReadUint32(),
+		ChainID:          r.ReadUint64(),
+		TransactionsHash: r.readHash(),
+		StateRoot:        r.readHash(),
+		ReceiptsHash:     r.readHash(),
+	}
+	r.ReadFixed(h.LogsBloom[:])
+	h.PrevHeaderHash = r.readHash()
+	h.Height = r.ReadUint32()
+	h.Timestamp = r.ReadInt64()
+	return h
+}
+
+func writeReceipt(w *BinaryWriter, r *Receipt) {
+	w.WriteFixed(r.TransactionHash[:])
+	w.WriteUint8(byte(r.Status))
+	w.WriteUint64(r.GasUsed)
+	w.WriteFixed(r.ContractAddress[:])
+	w.WriteUint32(uint32(len(r.Logs)))
+	for _, log := range r.Logs {
+		w.WriteFixed(log.Address[:])
+		w.WriteUint32(uint32(len(log.Topics)))
+		for _, topic := range log.Topics {
+			w.WriteFixed(topic[:])
+		}
+		w.WriteBytes(log.Data)
+	}
+	w.WriteUint32(r.BlockHeight)
+	w.WriteUint32(r.Index)
+}
+
+// writeSignedFields writes the transaction fields covered by the signature,
+// the payload follows the common fields
+func writeSignedFields(w *BinaryWriter, tx *Transaction) error {
+	if tx.Payload == nil {
+		return fmt.Errorf("%w: transaction has no payload", ErrInvalidTransaction)
+	}
+	w.WriteUint8(byte(tx.Type()))
+	w.WriteUint64(tx.ChainID)
+	w.WriteBytes(tx.From)
+	w.WriteUint64(tx.Nonce)
+	w.WriteUint64(tx.GasLimit)
+	w.WriteBigInt(tx.GasPrice)
+	tx.Payload.encode(w)
+	return nil
+}
+
+func writeTransaction(w *BinaryWriter, tx *Transaction) error {
+	if err := writeSignedFields(w, tx); err != nil {
+		return err
+	}
+	writeSignature(w, tx.Signature)
+	return nil
+}
+
+func readTransaction(r *BinaryReader, tx *Transaction) {
+	txType := TxType(r.ReadUint8())
+	newPayload, ok := txPayloads[txType]
+	if r.err == nil && !ok {
+		r.fail(fmt.Errorf("%w: unknown transaction type (%d)", ErrInvalidTransaction, byte(txType)))
+	}
+	tx.ChainID = r.ReadUint64()
+	tx.From = r.ReadBytes()
+	tx.Nonce = r.ReadUint64()
+	tx.GasLimit = r.ReadUint64()
+	tx.GasPrice = r.ReadBigInt()
+	tx.Payload = nil
+	if r.err != nil {
+		return
+	}
+	tx.Payload = newPayload()
+	tx.Payload.decode(r)
+	tx.Signature = readSignature(r)
+}
+
+func writeBlock(w *BinaryWriter, b *Block) error {
+	if b.Header == nil {
+		return fmt.Errorf("block has no header")
+	}
+	writeHeader(w, b.Header)
+	w.WriteBytes(b.Validator)
+	writeSignature(w, b.Signature)
+	w.WriteUint32(uint32(len(b.Transactions)))
+	for _, tx := range b.Transactions {
+		if err := writeTransaction(w, tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readBlock(r *BinaryReader, b *Block) {
+	b.Header = readHeader(r)
+	b.Validator = r.ReadBytes()
+	b.Signature = readSignature(r)
+	count := r.ReadUint32()
+	b.Transactions = nil
+	for i := uint32(0); i < count && r.err == nil; i++ {
+		tx := new(Transaction)
+		readTransaction(r, tx)
+		b.Transactions = append(b.Transactions, tx)
+	}
+}
+
+// ReadBlock reads the versioned block, so blocks following each other in the input can be read
+// without checking for trailing bytes after every one of them
+func (r *BinaryReader) ReadBlock(b *Block) {
+	r.ReadVersion()
+	readBlock(r, b)
+}
@@ -0,0 +1,339 @@
+package core
+
+import (
+	"blockchain/crypto"
+	"blockchain/types"
+	"bytes"
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// golden vectors of the canonical encoding, every line is a single field
+
+func goldenTransaction() *Transaction {
+	return &Transaction{
+		ChainID:  3,
+		From:     crypto.PublicKey{0x02, 0xaa},
+		Nonce:    7,
+		GasLimit: 21_000,
+		GasPrice: big.NewInt(2),
+		Payload: &Call{
+			Contract: types.Address{0xcc},
+			Input:    []byte("hey"),
+			Value:    big.NewInt(1_000),
+		},
+		Signature: &crypto.Signature{R: big.NewInt(1), S: big.NewInt(2)},
+	}
+}
+
+// goldenSigningHex is the transaction encoded without the signature,
+// the transaction hash is sha256 of the length prefixed transaction domain followed by it
+var goldenSigningHex = "" +
+	"01" + // codec version
+	"03" + // transaction type
+	"0000000000000003" + // chain id
+	"0000000202aa" + // from
+	"0000000000000007" + // nonce
+	"0000000000005208" + // gas limit
+	"010000000102" + // gas price
+	"cc" + strings.Repeat("00", 19) + // contract
+	"00000003686579" + // input
+	"010000000203e8" // value
+
+var goldenTransactionHex = goldenSigningHex +
+	"01" + "010000000101" + "010000000102" // signature
+
+const goldenTransactionHash = "665a87ad75f872dd4cbe547ca707ce47a0406447495605ab539c0010a7adcaca"
+
+func goldenHeader() *Header {
+	h := &Header{
+		Version:          1,
+		ChainID:          9,
+		TransactionsHash: types.Hash{0x11},
+		StateRoot:        types.Hash{0x22},
+		ReceiptsHash:     types.Hash{0x33},
+		PrevHeaderHash:   types.Hash{31: 0x44},
+		Height:           5,
+		Timestamp:        -2,
+	}
+	h.LogsBloom[BloomSize-1] = 0x80
+	return h
+}
+
+var goldenHeaderHex = "" +
+	"00000001" + // version
+	"0000000000000009" + // chain id
+	"11" + strings.Repeat("00", 31) + // transactions hash
+	"22" + strings.Repeat("00", 31) + // state root
+	"33" + strings.Repeat("00", 31) + // receipts hash
+	strings.Repeat("00", BloomSize-1) + "80" + // logs bloom
+	strings.Repeat("00", 31) + "44" + // previous header hash
+	"00000005" + // height
+	"fffffffffffffffe" // timestamp
+
+// goldenHeaderHash is sha256 of the length prefixed header domain, the codec version and the encoded header
+const goldenHeaderHash = "24a634a27fc717a438a44bb2d5c22deececd7e082bbbb03722486772776dbacc"
+
+func TestCodec_GoldenTransaction(t *testing.T) {
+	tx := goldenTransaction()
+	buf := new(bytes.Buffer)
+	assert.Nil(t, tx.Encode(NewBinaryTransactionEncoder(buf)))
+	assert.Equal(t, goldenTransactionHex, hex.EncodeToString(buf.Bytes()))
+
+	hash := tx.Hash(TransactionHasher{})
+	assert.Equal(t, goldenTransactionHash, hash.String())
+
+	txDecoded := new(Transaction)
+	assert.Nil(t, txDecoded.Decode(NewBinaryTransactionDecoder(buf)))
+	assert.Equal(t, tx, txDecoded)
+}
+
+func TestCodec_GoldenPayloads(t *testing.T) {
+	tests := []struct {
+		payload TxPayload
+		hex     string
+	}{
+		{
+			&Transfer{To: types.Address{0xaa}, Value: big.NewInt(5)},
+			"01" + "aa" + strings.Repeat("00", 19) + "010000000105",
+		},
+		{
+			&Deploy{Code: []byte{0x0a, 0x0b}},
+			"02" + "000000020a0b" + "00",
+		},
+		{
+			&CreateCollection{MetaData: []byte("ok"), Fee: 200},
+			"04" + "000000026f6b" + "00000000000000c8",
+		},
+		{
+			&Mint{
+				MetaData:        []byte("ok"),
+				Fee:             1,
+				NFT:             types.Hash{0xaa},
+				Collection:      types.Hash{0xbb},
+				CollectionOwner: crypto.PublicKey{0x02},
+				Signature:       crypto.Signature{R: big.NewInt(3)},
+			},
+			"05" + "000000026f6b" + "0000000000000001" +
+				"aa" + strings.Repeat("00", 31) +
+				"bb" + strings.Repeat("00", 31) +
+				"0000000102" + "010000000103" + "00",
+		},
+		{
+			&TransferNFT{NFT: types.Hash{0xaa}, To: types.Address{0xbb}},
+			"06" + "aa" + strings.Repeat("00", 31) + "bb" + strings.Repeat("00", 19),
+		},
+		{
+			&BurnNFT{NFT: types.Hash{0xaa}},
+			"07" + "aa" + strings.Repeat("00", 31),
+		},
+	}
+
+	// chain id, from, nonce, gas limit and gas price
+	empty := "0000000000000000" + "00000000" + "0000000000000000" + "0000000000000000" + "00"
+	for _, test := range tests {
+		tx := &Transaction{Payload: test.payload}
+		buf := new(bytes.Buffer)
+		assert.Nil(t, tx.Encode(NewBinaryTransactionEncoder(buf)))
+
+		// the type goes first and the payload follows the common fields
+		expected := "01" + test.hex[:2] + empty + test.hex[2:] + "00"
+		assert.Equal(t, expected, hex.EncodeToString(buf.Bytes()))
+
+		txDecoded := new(Transaction)
+		assert.Nil(t, txDecoded.Decode(NewBinaryTransactionDecoder(buf)))
+		assert.Equal(t, tx, txDecoded)
+	}
+}
+
+func TestCodec_GoldenHeader(t *testing.T) {
+	h := goldenHeader()
+	assert.Equal(t, goldenHeaderHex, hex.EncodeToString(h.Bytes()))
+	assert.Equal(t, goldenHeaderHash, HeaderHasher{}.Hash(h).String())
+}
+
+func TestCodec_GoldenBlock(t *testing.T) {
+	tx := goldenTransaction()
+	b := NewBlock(goldenHeader(), []*Transaction{tx})
+	b.Validator = crypto.PublicKey{0x02, 0xcc}
+	b.Signature = &crypto.Signature{R: big.NewInt(4), S: big.NewInt(5)}
+
+	buf := new(bytes.Buffer)
+	assert.Nil(t, b.Encode(NewBinaryBlockEncoder(buf)))
+	expected := "01" + goldenHeaderHex +
+		"0000000202cc" + // validator
+		"01" + "010000000104" + "010000000105" + // signature
+		"00000001" + // number of transactions
+		strings.TrimPrefix(goldenTransactionHex, "01")
+	assert.Equal(t, expected, hex.EncodeToString(buf.Bytes()))
+
+	decoded := new(Block)
+	assert.Nil(t, decoded.Decode(NewBinaryBlockDecoder(bytes.NewReader(buf.Bytes()))))
+	assert.Equal(t, b, decoded)
+
+	trailing, _ := hex.DecodeString(expected + "00")
+	assert.ErrorIs(t, new(Block).Decode(NewBinaryBlockDecoder(bytes.NewReader(trailing))), ErrNonCanonical)
+}
+
+func TestCodec_BigInt(t *testing.T) {
+	tests := []struct {
+		value *big.Int
+		hex   string
+	}{
+		{nil, "00"},
+		{big.NewInt(0), "00"},
+		{big.NewInt(255), "0100000001ff"},
+		{big.NewInt(-256), "02000000020100"},
+	}
+
+	for _, test := range tests {
+		w := NewBinaryWriter()
+		w.WriteBigInt(test.value)
+		assert.Equal(t, test.hex, hex.EncodeToString(w.Bytes()))
+
+		r := NewBinaryReader(bytes.NewReader(w.Bytes()))
+		v := r.ReadBigInt()
+		assert.Nil(t, r.Err())
+		if test.value == nil || test.value.Sign() == 0 {
+			assert.Nil(t, v)
+		} else {
+			assert.Equal(t, test.value, v)
+		}
+	}
+}
+
+func TestCodec_Rejects(t *testing.T) {
+	tests := []struct {
+		name string
+		hex  string
+		err  error
+	}{
+		{"version", "02", ErrCodecVersion},
+		{"type", "01" + "09", ErrInvalidTransaction},
+		{"leading zeros", goldenTransactionHex[:64] + "01000000020001", ErrNonCanonical},
+		{"zero magnitude", goldenTransactionHex[:64] + "0100000000", ErrNonCanonical},
+		{"integer sign", goldenTransactionHex[:64] + "03", ErrNonCanonical},
+		{"bool", goldenTransactionHex[:len(goldenTransactionHex)-26] + "02", ErrNonCanonical},
+		{"zero signature r", goldenTransactionHex[:len(goldenTransactionHex)-26] + "01" + "00" + "010000000102", ErrNonCanonical},
+		{"zero signature s", goldenTransactionHex[:len(goldenTransactionHex)-26] + "01" + "010000000101" + "00", ErrNonCanonical},
+		{"trailing bytes", goldenTransactionHex + "00", ErrNonCanonical},
+	}
+
+	for _, test := range tests {
+		b, err := hex.DecodeString(test.hex)
+		assert.Nil(t, err, test.name)
+		err = new(Transaction).Decode(NewBinaryTransactionDecoder(bytes.NewReader(b)))
+		assert.ErrorIs(t, err, test.err, test.name)
+	}
+
+	b, _ := hex.DecodeString(goldenTransactionHex)
+	err := new(Transaction).Decode(NewBinaryTransactionDecoder(bytes.NewReader(b[:len(b)-1])))
+	assert.NotNil(t, err)
+
+	w := NewBinaryWriter()
+	w.WriteUint32(MaxBytesLength + 1)
+	r := NewBinaryReader(bytes.NewReader(w.Bytes()))
+	assert.Nil(t, r.ReadBytes())
+	assert.NotNil(t, r.Err())
+}
+
+func TestCodec_NoPayload(t *testing.T) {
+	tx := &Transaction{}
+	assert.ErrorIs(t, tx.Encode(NewBinaryTransactionEncoder(new(bytes.Buffer))), ErrInvalidTransaction)
+}
+
+func TestCodec_GoldenReceipt(t *testing.T) {
+	r := &Receipt{
+		TransactionHash: types.Hash{0x11},
+		Status:          ReceiptStatusSuccess,
+		Error:           "oops",
+		GasUsed:         21000,
+		ContractAddress: types.Address{19: 0x22},
+		Logs: []*Log{{
+			Address:     types.Address{0x33},
+			Topics:      []types.Hash{{0x44}},
+			Data:        []byte{0x55},
+			BlockHeight: 6,
+		}},
+		BlockHeight: 6,
+		Index:       2,
+	}
+	expected := "" +
+		"11" + strings.Repeat("00", 31) + // transaction hash
+		"01" + // status
+		"0000000000005208" + // gas used
+		strings.Repeat("00", 19) + "22" + // contract address
+		"00000001" + // logs count
+		"33" + strings.Repeat("00", 19) + // log address
+		"00000001" + "44" + strings.Repeat("00", 31) + // log topics
+		"00000001" + "55" + // log data
+		"00000006" + // block height
+		"00000002" // index
+	assert.Equal(t, expected, hex.EncodeToString(r.Bytes()))
+	// sha256 of the length prefixed receipt domain, the codec version and the encoded receipt
+	assert.Equal(t, "4e292a7e1eaa5ce6c4c50619cc85f30c892c9cb74f304f3571bb24944d358f91", ReceiptHasher{}.Hash(r).String())
+
+	// the error message isn't consensus data
+	r.Error = "another message"
+	assert.Equal(t, "4e292a7e1eaa5ce6c4c50619cc85f30c892c9cb74f304f3571bb24944d358f91", ReceiptHasher{}.Hash(r).String())
+}
+
+func TestCodec_GoldenAccount(t *testing.T) {
+	addr := types.Address{0x01}
+	acc := &Account{Address: addr, Balance: big.NewInt(1000), Nonce: 3}
+	expected := "" +
+		"01" + // version
+		"01" + "00000002" + "03e8" + // balance
+		"0000000000000003" // nonce
+	assert.Equal(t, expected, hex.EncodeToString(acc.Bytes()))
+
+	decoded, err := decodeAccount(addr, acc.Bytes())
+	assert.Nil(t, err)
+	assert.Equal(t, acc, decoded)
+
+	_, err = decodeAccount(addr, append(acc.Bytes(), 0x00))
+	assert.NotNil(t, err)
+	_, err = decodeAccount(addr, acc.Bytes()[:5])
+	assert.NotNil(t, err)
+}
+
+func TestCodec_GoldenTrieNodes(t *testing.T) {
+	trie := NewTrie()
+	trie.Put([]byte{0x12}, []byte("a"))
+	trie.Put([]byte{0x13}, []byte("b"))
+
+	leafA := "b8ae5678c9a5308918524667e7efb09038455c481771e908e2b1ce48a6b0b8bd"
+	leafB := "3755f9a955cdeecfaad1f805c8f94ceb1be47b51ef5f4b64dbfd92dee62bd091"
+	expected := []string{
+		"01" + // version
+			"01" + // extension
+			"00000001" + "01" + // path
+			"c03f140e1de9af7a21811981702a2828188113bfc92c20a9e0ba6e9991c59396", // child hash
+		"01" + // version
+			"02" + // branch
+			strings.Repeat("00", 2*32) + leafA + leafB + strings.Repeat("00", 12*32) + // children hashes
+			"00000000", // value
+		"01" + // version
+			"00" + // leaf
+			"00000000" + // path
+			"00000001" + "61", // value
+	}
+	proof := trie.Prove([]byte{0x12})
+	assert.Len(t, proof, len(expected))
+	for i, enc := range proof {
+		assert.Equal(t, expected[i], hex.EncodeToString(enc))
+	}
+	// node hashes are sha256 of the length prefixed trie node domain followed by the encoded node
+	assert.Equal(t, "e59b37fafd30159c7f61c72bde20d14a9a594a1fe681cc241d8d91d76ae5f74c", trie.Hash().String())
+
+	value, err := VerifyProof(trie.Hash(), []byte{0x12}, proof)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("a"), value)
+
+	proof[2] = append(proof[2], 0x00)
+	_, err = VerifyProof(trie.Hash(), []byte{0x12}, proof)
+	assert.NotNil(t, err)
+}
@@ -1,7 +1,6 @@
 package core
 
 import (
-	"encoding/gob"
 	"io"
 )
 
@@ -13,58 +12,78 @@ type Decoder[T any] interface {
 	Decode(T) error
 }
 
-type GobTransactionEncoder struct {
+type BinaryTransactionEncoder struct {
 	w io.Writer
 }
 
-func NewGobTransactionEncoder(w io.Writer) *GobTransactionEncoder {
-	return &GobTransactionEncoder{
+func NewBinaryTransactionEncoder(w io.Writer) *BinaryTransactionEncoder {
+	return &BinaryTransactionEncoder{
 		w: w,
 	}
 }
 
-func (e *GobTransactionEncoder) Encode(tx *Transaction) error {
-	return gob.NewEncoder(e.w).Encode(tx)
+func (e *BinaryTransactionEncoder) Encode(tx *Transaction) error {
+	w := NewBinaryWriter()
+	w.WriteVersion()
+	if err := writeTransaction(w, tx); err != nil {
+		return err
+	}
+	_, err := e.w.Write(w.Bytes())
+	return err
 }
 
-type GobTransactionDecoder struct {
+type BinaryTransactionDecoder struct {
 	r io.Reader
 }
 
-func NewGobTransactionDecoder(r io.Reader) *GobTransactionDecoder {
-	return &GobTransactionDecoder{
+func NewBinaryTransactionDecoder(r io.Reader) *BinaryTransactionDecoder {
+	return &BinaryTransactionDecoder{
 		r: r,
 	}
 }
 
-func (d *GobTransactionDecoder) Decode(tx *Transaction) error {
-	return gob.NewDecoder(d.r).Decode(tx)
+func (d *BinaryTransactionDecoder) Decode(tx *Transaction) error {
+	r := NewBinaryReader(d.r)
+	r.ReadVersion()
+	readTransaction(r, tx)
+	r.ReadEOF()
+	return r.Err()
 }
 
-type GobBlockEncoder struct {
+type BinaryBlockEncoder struct {
 	w io.Writer
 }
 
-func NewGobBlockEncoder(w io.Writer) *GobBlockEncoder {
-	return &GobBlockEncoder{
+func NewBinaryBlockEncoder(w io.Writer) *BinaryBlockEncoder {
+	return &BinaryBlockEncoder{
 		w: w,
 	}
 }
 
-func (e *GobBlockEncoder) Encode(b *Block) error {
-	return gob.NewEncoder(e.w).Encode(b)
+func (e *BinaryBlockEncoder) Encode(b *Block) error {
+	w := NewBinaryWriter()
+	w.WriteVersion()
+	if err := writeBlock(w, b); err != nil {
+		return err
+	}
+	_, err := e.w.Write(w.Bytes())
+	return err
 }
 
-type GobBlockDecoder struct {
+type BinaryBlockDecoder struct {
 	r io.Reader
 }
 
-func NewGobBlockDecoder(r io.Reader) *GobBlockDecoder {
-	return &GobBlockDecoder{
+func NewBinaryBlockDecoder(r io.Reader) *BinaryBlockDecoder {
+	return &BinaryBlockDecoder{
 		r: r,
 	}
 }
 
-func (d *GobBlockDecoder) Decode(b *Block) error {
-	return gob.NewDecoder(d.r).Decode(b)
+func (d *BinaryBlockDecoder) Decode(b *Block) error {
+	r := NewBinaryReader(d.r)
+	r.ReadVersion()
+	readBlock(r, b)
+	r.ReadEOF()
+	return r.Err()
 }
@@ -0,0 +1,301 @@
+package core
+
+import (
+	"blockchain/types"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+const (
+	defaultSegmentSize = 16 << 20 // 16 MiB
+	segmentExt         = ".seg"
+	// every record in a segment is prefixed with the length of the encoded block
+	recordHeaderSize = 4
+	// maxRecordSize is the maximum length of the encoded block,
+	// longer records are rejected instead of being allocated
+	maxRecordSize = 4 * MaxBytesLength
+)
+
+// blockLocation is the position of an encoded block inside a segment file
+type blockLocation struct {
+	segment int
+	offset  int64
+	size    uint32
+}
+
+// FileStore is an append-only block store.
+// Blocks are written to segment files in the store directory,
+// a new segment is started when the current one exceeds the segment size.
+// The index by height and header hash is kept in memory
+// and rebuilt from the segments when the store is opened.
+type FileStore struct {
+	mu          sync.RWMutex
+	dir         string
+	segmentSize int64
+	segments    []*os.File
+	// size of the last (writable) segment
+	tailSize  int64
+	locations []blockLocation
+	hashes    map[types.Hash]uint32
+}
+
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	s := &FileStore{
+		dir:         dir,
+		segmentSize: defaultSegmentSize,
+		hashes:      make(map[types.Hash]uint32),
+	}
+
+	if err := s.load(); err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// load opens existing segments and rebuilds the index.
+// A partially written record at the end of the last segment
+// (e.g. after a crash) is truncated.
+func (s *FileStore) load() error {
+	names, err := filepath.Glob(filepath.Join(s.dir, "*"+segmentExt))
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	for i, name := range names {
+		if name != s.segmentPath(i) {
+			return fmt.Errorf("FileStore.load: unexpected segment file (%s)", name)
+		}
+
+		f, err := os.OpenFile(name, os.O_RDWR, 0o644)
+		if err != nil {
+			return err
+		}
+		s.segments = append(s.segments, f)
+
+		size, err := s.scanSegment(i)
+		if err != nil {
+			return err
+		}
+
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		if size != info.Size() {
+			if i != len(names)-1 {
+				return fmt.Errorf("FileStore.load: segment (%s) is corrupted", name)
+			}
+			if err = f.Truncate(size); err != nil {
+				return err
+			}
+		}
+		s.tailSize = size
+	}
+
+	if len(s.segments) == 0 {
+		return s.newSegment()
+	}
+
+	return nil
+}
+
+// scanSegment indexes all complete records of the segment and returns their total size
+func (s *FileStore) scanSegment(segment int) (int64, error) {
+	r := io.NewSectionReader(s.segments[segment], 0, 1<<62)
+	var offset int64
+	for {
+		header := make([]byte, recordHeaderSize)
+		if _, err := io.ReadFull(r, header); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return offset, nil
+			}
+			return 0, err
+		}
+
+		size := binary.BigEndian.Uint32(header)
+		if size > maxRecordSize {
+			return 0, fmt.Errorf("FileStore.scanSegment: record at offset (%d) of segment (%d) has length (%d) => max (%d)",
+				offset, segment, size, maxRecordSize)
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(r, data); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return offset, nil
+			}
+			return 0, err
+		}
+
+		b := new(Block)
+		if err := b.Decode(NewBinaryBlockDecoder(bytes.NewReader(data))); err != nil {
+			return 0, fmt.Errorf("FileStore.scanSegment: couldn't decode block at offset (%d) of segment (%d): %s",
+				offset, segment, err)
+		}
+		if int(b.Height) != len(s.locations) {
+			return 0, fmt.Errorf("FileStore.scanSegment: expected block with height (%d), got (%d)",
+				len(s.locations), b.Height)
+		}
+
+		s.locations = append(s.locations, blockLocation{
+			segment: segment,
+			offset:  offset,
+			size:    size,
+		})
+		s.hashes[b.HeaderHash(HeaderHasher{})] = b.Height
+
+		offset += recordHeaderSize + int64(size)
+	}
+}
+
+func (s *FileStore) segmentPath(segment int) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%06d%s", segment, segmentExt))
+}
+
+func (s *FileStore) newSegment() error {
+	f, err := os.OpenFile(s.segmentPath(len(s.segments)), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	s.segments = append(s.segments, f)
+	s.tailSize = 0
+	return nil
+}
+
+func (s *FileStore) Put(b *Block) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if int(b.Height) != len(s.locations) {
+		return fmt.Errorf("FileStore.Put: expected block with height (%d), got (%d)", len(s.locations), b.Height)
+	}
+
+	buf := new(bytes.Buffer)
+	buf.Write(make([]byte, recordHeaderSize))
+	if err := b.Encode(NewBinaryBlockEncoder(buf)); err != nil {
+		return err
+	}
+	record := buf.Bytes()
+	if len(record)-recordHeaderSize > maxRecordSize {
+		return fmt.Errorf("FileStore.Put: block (%d) has length (%d) => max (%d)",
+			b.Height, len(record)-recordHeaderSize, maxRecordSize)
+	}
+	size := uint32(len(record) - recordHeaderSize)
+	binary.BigEndian.PutUint32(record, size)
+
+	if s.tailSize > 0 && s.tailSize+int64(len(record)) > s.segmentSize {
+		if err := s.newSegment(); err != nil {
+			return err
+		}
+	}
+
+	segment := len(s.segments) - 1
+	f := s.segments[segment]
+	if _, err := f.WriteAt(record, s.tailSize); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+
+	s.locations = append(s.locations, blockLocation{
+		segment: segment,
+		offset:  s.tailSize,
+		size:    size,
+	})
+	s.hashes[b.HeaderHash(HeaderHasher{})] = b.Height
+	s.tailSize += int64(len(record))
+
+	return nil
+}
+
+func (s *FileStore) Get(height uint32) (*Block, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.get(height)
+}
+
+func (s *FileStore) get(height uint32) (*Block, error) {
+	if int(height) >= len(s.locations) {
+		return nil, fmt.Errorf("FileStore.Get: block with height (%d) not found", height)
+	}
+
+	loc := s.locations[height]
+	data := make([]byte, loc.size)
+	if _, err := s.segments[loc.segment].ReadAt(data, loc.offset+recordHeaderSize); err != nil {
+		return nil, err
+	}
+
+	b := new(Block)
+	if err := b.Decode(NewBinaryBlockDecoder(bytes.NewReader(data))); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+func (s *FileStore) GetByHeaderHash(hash types.Hash) (*Block, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	height, ok := s.hashes[hash]
+	if !ok {
+		return nil, fmt.Errorf("FileStore.GetByHeaderHash: block with header hash (%s) not found", hash)
+	}
+
+	return s.get(height)
+}
+
+func (s *FileStore) Has(hash types.Hash) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.hashes[hash]
+
+	return ok
+}
+
+func (s *FileStore) Iterate(fn func(*Block) error) error {
+	s.mu.RLock()
+	count := len(s.locations)
+	s.mu.RUnlock()
+
+	for height := 0; height < count; height++ {
+		b, err := s.Get(uint32(height))
+		if err != nil {
+			return err
+		}
+		if err = fn(b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close closes all segment files
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var err error
+	for _, f := range s.segments {
+		err = errors.Join(err, f.Close())
+	}
+	s.segments = nil
+
+	return err
+}
@@ -0,0 +1,121 @@
+package core
+
+import (
+	"blockchain/types"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileStore_PutGet(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	assert.Nil(t, err)
+	defer store.Close()
+
+	var blocks []*Block
+	prevHeaderHash := types.Hash{}
+	for i := 0; i < 5; i++ {
+		b := randomBlock(t, prevHeaderHash, uint32(i), nil)
+		assert.Nil(t, store.Put(b))
+		blocks = append(blocks, b)
+		prevHeaderHash = b.HeaderHash(HeaderHasher{})
+	}
+
+	for i, b := range blocks {
+		hash := b.HeaderHash(HeaderHasher{})
+		assert.True(t, store.Has(hash))
+
+		block, err := store.Get(uint32(i))
+		assert.Nil(t, err)
+		assert.Equal(t, b, block)
+
+		block, err = store.GetByHeaderHash(hash)
+		assert.Nil(t, err)
+		assert.Equal(t, b, block)
+	}
+
+	_, err = store.Get(5)
+	assert.NotNil(t, err)
+	assert.False(t, store.Has(types.Hash{}))
+	// blocks must be put in height order
+	assert.NotNil(t, store.Put(randomBlock(t, prevHeaderHash, 10, nil)))
+}
+
+func TestFileStore_Reopen(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	assert.Nil(t, err)
+	// force a new segment for every block
+	store.segmentSize = 1
+
+	var blocks []*Block
+	for i := 0; i < 3; i++ {
+		b := randomBlock(t, types.Hash{}, uint32(i), nil)
+		assert.Nil(t, store.Put(b))
+		blocks = append(blocks, b)
+	}
+	assert.Equal(t, 3, len(store.segments))
+	assert.Nil(t, store.Close())
+
+	store, err = NewFileStore(dir)
+	assert.Nil(t, err)
+	defer store.Close()
+
+	var iterated []*Block
+	err = store.Iterate(func(b *Block) error {
+		iterated = append(iterated, b)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, blocks, iterated)
+	assert.Nil(t, store.Put(randomBlock(t, types.Hash{}, 3, nil)))
+}
+
+func TestFileStore_TruncatePartialRecord(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	assert.Nil(t, err)
+
+	b := randomBlock(t, types.Hash{}, 0, nil)
+	assert.Nil(t, store.Put(b))
+	assert.Nil(t, store.Close())
+
+	// simulate a crash in the middle of writing the next record
+	f, err := os.OpenFile(filepath.Join(dir, "000000"+segmentExt), os.O_WRONLY|os.O_APPEND, 0o644)
+	assert.Nil(t, err)
+	_, err = f.Write([]byte{0, 0, 1, 0, 1, 2, 3})
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+
+	store, err = NewFileStore(dir)
+	assert.Nil(t, err)
+	defer store.Close()
+
+	block, err := store.Get(0)
+	assert.Nil(t, err)
+	assert.Equal(t, b, block)
+	assert.Nil(t, store.Put(randomBlock(t, types.Hash{}, 1, nil)))
+
+	block, err = store.Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(1), block.Height)
+}
+
+func TestFileStore_RejectsOversizedRecord(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	assert.Nil(t, err)
+	assert.Nil(t, store.Put(randomBlock(t, types.Hash{}, 0, nil)))
+	assert.Nil(t, store.Close())
+
+	// corrupted length of the next record mustn't be allocated
+	f, err := os.OpenFile(filepath.Join(dir, "000000"+segmentExt), os.O_WRONLY|os.O_APPEND, 0o644)
+	assert.Nil(t, err)
+	_, err = f.Write([]byte{0xff, 0xff, 0xff, 0xff, 1, 2, 3})
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+
+	_, err = NewFileStore(dir)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,140 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+const (
+	// DefaultGasLimit is the gas limit of transactions created by NewTransaction
+	DefaultGasLimit uint64 = 100_000
+	// TransactionGas is charged for every transaction
+	TransactionGas uint64 = 1_000
+	// TransactionDataGas is charged for every byte of transaction data
+	TransactionDataGas uint64 = 10
+	// ContractCreationGas is charged for deploying a contract
+	ContractCreationGas uint64 = 10_000
+	// MaxGasLimit is the maximum gas limit of a transaction, it bounds the execution time of free transactions
+	MaxGasLimit uint64 = 1_000_000
+	// BlockGasLimit is the maximum sum of gas limits of the block transactions
+	BlockGasLimit uint64 = 10_000_000
+	// DefaultMinGasPrice is the minimum gas price of the default genesis configuration
+	DefaultMinGasPrice int64 = 1
+)
+
+// gas costs of VM instructions
+const (
+	GasQuick    uint64 = 1
+	GasFast     uint64 = 3
+	GasSlow     uint64 = 5
+	GasJump     uint64 = 8
+	GasGet      uint64 = 50
+	GasStore    uint64 = 200
+	GasTransfer uint64 = 500
+	GasCall     uint64 = 700
+	GasLog      uint64 = 375
+	GasHash     uint64 = 30
+)
+
+var (
+	ErrOutOfGas          = errors.New("out of gas")
+	ErrIntrinsicGas      = errors.New("gas limit is lower than intrinsic gas")
+	ErrInsufficientFunds = errors.New("insufficient funds for gas")
+	ErrGasLimitTooHigh   = errors.New("gas limit exceeds maximum")
+	ErrBlockGasLimit     = errors.New("block gas limit exceeded")
+	ErrGasPriceTooLow    = errors.New("gas price is lower than minimum")
+)
+
+// instructionGas is the gas schedule of the VM
+var instructionGas = map[Instruction]uint64{
+	InstrPushByte:    GasQuick,
+	InstrAdd:         GasFast,
+	InstrSub:         GasFast,
+	InstrMul:         GasSlow,
+	InstrDiv:         GasSlow,
+	InstrPack:        GasFast,
+	InstrStore:       GasStore,
+	InstrGet:         GasGet,
+	InstrEq:          GasFast,
+	InstrLt:          GasFast,
+	InstrGt:          GasFast,
+	InstrAnd:         GasFast,
+	InstrOr:          GasFast,
+	InstrNot:         GasFast,
+	InstrJump:        GasJump,
+	InstrJumpI:       GasJump,
+	InstrDup:         GasFast,
+	InstrSwap:        GasFast,
+	InstrPop:         GasQuick,
+	InstrBalance:     GasGet,
+	InstrSelfBalance: GasGet,
+	InstrTransfer:    GasTransfer,
+	InstrCall:        GasCall,
+	InstrLog:         GasLog,
+	InstrSLoad:       GasGet,
+	InstrSStore:      GasStore,
+	InstrHash:        GasHash,
+}
+
+// InstructionGas returns gas cost of the instruction
+func InstructionGas(instr Instruction) uint64 {
+	gas, ok := instructionGas[instr]
+	if !ok {
+		return GasQuick
+	}
+	return gas
+}
+
+// IntrinsicGas returns gas charged for the transaction before its execution
+func IntrinsicGas(tx *Transaction) uint64 {
+	gas := TransactionGas
+	switch p := tx.Payload.(type) {
+	case *Deploy:
+		gas += uint64(len(p.Code))*TransactionDataGas + ContractCreationGas
+	case *Call:
+		gas += uint64(len(p.Input)) * TransactionDataGas
+	}
+	return gas
+}
+
+// CheckGasPrice checks the gas price of the transaction isn't lower than the minimum, nil minimum allows any price
+func CheckGasPrice(tx *Transaction, minGasPrice *big.Int) error {
+	if minGasPrice == nil || minGasPrice.Sign() <= 0 {
+		return nil
+	}
+	// the price of a single unit of gas is zero if the gas price isn't set
+	if price := GasCost(1, tx.GasPrice); price.Cmp(minGasPrice) < 0 {
+		return fmt.Errorf("%w: transaction (%s) has gas price (%s), minimum (%s)",
+			ErrGasPriceTooLow, tx.Hash(TransactionHasher{}), price, minGasPrice)
+	}
+	return nil
+}
+
+// checkBlockGas checks the sum of gas limits of the transactions doesn't exceed BlockGasLimit
+func checkBlockGas(txs []*Transaction) error {
+	var gas uint64
+	for _, tx := range txs {
+		if tx.GasLimit > BlockGasLimit-gas {
+			return fmt.Errorf("%w: gas limit of transaction (%s) exceeds remaining block gas (%d)",
+				ErrBlockGasLimit, tx.Hash(TransactionHasher{}), BlockGasLimit-gas)
+		}
+		gas += tx.GasLimit
+	}
+	return nil
+}
+
+// MaxCost returns the maximum amount the sender of the transaction pays,
+// it's the price of the gas limit and the sent value
+func MaxCost(tx *Transaction) *big.Int {
+	cost := GasCost(tx.GasLimit, tx.GasPrice)
+	return cost.Add(cost, tx.Value())
+}
+
+// GasCost returns price of the given amount of gas
+func GasCost(gas uint64, gasPrice *big.Int) *big.Int {
+	if gasPrice == nil {
+		return new(big.Int)
+	}
+	return new(big.Int).Mul(new(big.Int).SetUint64(gas), gasPrice)
+}
@@ -2,9 +2,7 @@ package core
 
 import (
 	"blockchain/types"
-	"bytes"
 	"crypto/sha256"
-	"encoding/binary"
 )
 
 type Hasher[T any] interface {
@@ -13,18 +11,31 @@ type Hasher[T any] interface {
 
 type HeaderHasher struct{}
 
+// Hash returns hash of the header domain and the codec version followed by the canonical encoding of the header
 func (HeaderHasher) Hash(h *Header) types.Hash {
-	return sha256.Sum256(h.Bytes())
+	w := NewBinaryWriter()
+	w.WriteString(headerDomain)
+	w.WriteVersion()
+	w.WriteFixed(h.Bytes())
+	return sha256.Sum256(w.Bytes())
 }
 
 type TransactionHasher struct{}
 
+// Hash returns the hash covered by the transaction signature,
+// transactions without a payload have the zero hash
 func (TransactionHasher) Hash(tx *Transaction) types.Hash {
-	buf := new(bytes.Buffer)
-	binary.Write(buf, binary.LittleEndian, tx.Data)
-	binary.Write(buf, binary.LittleEndian, tx.From)
-	binary.Write(buf, binary.LittleEndian, tx.To)
-	binary.Write(buf, binary.LittleEndian, tx.Value)
-	binary.Write(buf, binary.LittleEndian, tx.Nonce)
-	return sha256.Sum256(buf.Bytes())
+	hash, _ := tx.signingHash()
+	return hash
+}
+
+type ReceiptHasher struct{}
+
+// Hash returns hash of the receipt domain and the codec version followed by the encoded receipt
+func (ReceiptHasher) Hash(r *Receipt) types.Hash {
+	w := NewBinaryWriter()
+	w.WriteString(receiptDomain)
+	w.WriteVersion()
+	w.WriteFixed(r.Bytes())
+	return sha256.Sum256(w.Bytes())
 }
@@ -0,0 +1,119 @@
+package core
+
+import (
+	"blockchain/types"
+	"crypto/sha256"
+	"fmt"
+)
+
+// prefixes separate leaves from inner nodes so a leaf can't be presented as a subtree
+const (
+	merkleLeafPrefix byte = 0x00
+	merkleNodePrefix byte = 0x01
+)
+
+func merkleLeafHash(leaf types.Hash) types.Hash {
+	return sha256.Sum256(append([]byte{merkleLeafPrefix}, leaf[:]...))
+}
+
+func merkleNodeHash(left, right types.Hash) types.Hash {
+	buf := make([]byte, 0, 1+2*len(types.Hash{}))
+	buf = append(buf, merkleNodePrefix)
+	buf = append(buf, left[:]...)
+	buf = append(buf, right[:]...)
+	return sha256.Sum256(buf)
+}
+
+// merkleLevels returns all levels of the binary Merkle tree starting with hashed leaves.
+// A node without a pair is promoted to the next level unchanged.
+func merkleLevels(leaves []types.Hash) [][]types.Hash {
+	level := make([]types.Hash, len(leaves))
+	for i, leaf := range leaves {
+		level[i] = merkleLeafHash(leaf)
+	}
+
+	levels := [][]types.Hash{level}
+	for len(level) > 1 {
+		next := make([]types.Hash, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			next = append(next, merkleNodeHash(level[i], level[i+1]))
+		}
+		levels = append(levels, next)
+		level = next
+	}
+
+	return levels
+}
+
+// MerkleRoot returns root of the binary Merkle tree of the leaves, zero hash for no leaves
+func MerkleRoot(leaves []types.Hash) types.Hash {
+	if len(leaves) == 0 {
+		return types.Hash{}
+	}
+	levels := merkleLevels(leaves)
+	return levels[len(levels)-1][0]
+}
+
+// MerkleProof proves that a leaf is included in the Merkle tree
+type MerkleProof struct {
+	// Index is the position of the leaf
+	Index int
+	// Total is the number of leaves in the tree
+	Total int
+	// Hashes are sibling hashes from the leaf level up to the root
+	Hashes []types.Hash
+}
+
+func NewMerkleProof(leaves []types.Hash, index int) (*MerkleProof, error) {
+	if index < 0 || index >= len(leaves) {
+		return nil, fmt.Errorf("NewMerkleProof: index (%d) is out of range, number of leaves (%d)", index, len(leaves))
+	}
+
+	proof := &MerkleProof{
+		Index: index,
+		Total: len(leaves),
+	}
+
+	levels := merkleLevels(leaves)
+	for _, level := range levels[:len(levels)-1] {
+		sibling := index ^ 1
+		if sibling < len(level) {
+			proof.Hashes = append(proof.Hashes, level[sibling])
+		}
+		index /= 2
+	}
+
+	return proof, nil
+}
+
+// Verify checks that the leaf is included in the tree with the given root
+func (p *MerkleProof) Verify(root, leaf types.Hash) bool {
+	if p.Index < 0 || p.Index >= p.Total {
+		return false
+	}
+
+	hash := merkleLeafHash(leaf)
+	index, count, used := p.Index, p.Total, 0
+	for count > 1 {
+		// the last node of an odd level has no sibling
+		if index != count-1 || count%2 == 0 {
+			if used == len(p.Hashes) {
+				return false
+			}
+			if index%2 == 0 {
+				hash = merkleNodeHash(hash, p.Hashes[used])
+			} else {
+				hash = merkleNodeHash(p.Hashes[used], hash)
+			}
+			used++
+		}
+		index /= 2
+		count = (count + 1) / 2
+	}
+
+	return used == len(p.Hashes) && hash == root
+}
@@ -0,0 +1,72 @@
+package core
+
+import (
+	"blockchain/types"
+	"crypto/sha256"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func randomLeaves(n int) []types.Hash {
+	leaves := make([]types.Hash, n)
+	for i := range leaves {
+		leaves[i] = sha256.Sum256([]byte(fmt.Sprintf("leaf-%d", i)))
+	}
+	return leaves
+}
+
+func TestMerkleRoot(t *testing.T) {
+	assert.True(t, MerkleRoot(nil).IsZero())
+
+	leaves := randomLeaves(3)
+	assert.Equal(t, merkleLeafHash(leaves[0]), MerkleRoot(leaves[:1]))
+
+	expected := merkleNodeHash(
+		merkleNodeHash(merkleLeafHash(leaves[0]), merkleLeafHash(leaves[1])),
+		merkleLeafHash(leaves[2]),
+	)
+	assert.Equal(t, expected, MerkleRoot(leaves))
+
+	// changing order of leaves changes the root
+	assert.NotEqual(t, MerkleRoot(leaves), MerkleRoot([]types.Hash{leaves[1], leaves[0], leaves[2]}))
+}
+
+func TestMerkleProof(t *testing.T) {
+	for n := 1; n <= 17; n++ {
+		leaves := randomLeaves(n)
+		root := MerkleRoot(leaves)
+		for i, leaf := range leaves {
+			proof, err := NewMerkleProof(leaves, i)
+			assert.Nil(t, err)
+			assert.True(t, proof.Verify(root, leaf), "n = %d, i = %d", n, i)
+
+			// proof doesn't work for other leaves
+			assert.False(t, proof.Verify(root, types.Hash{}))
+			if n > 1 {
+				assert.False(t, proof.Verify(root, leaves[(i+1)%n]))
+			}
+		}
+	}
+
+	_, err := NewMerkleProof(randomLeaves(2), 2)
+	assert.NotNil(t, err)
+}
+
+func TestMerkleProof_Tampered(t *testing.T) {
+	leaves := randomLeaves(8)
+	root := MerkleRoot(leaves)
+
+	proof, err := NewMerkleProof(leaves, 5)
+	assert.Nil(t, err)
+	proof.Hashes[1][0] ^= 0xff
+	assert.False(t, proof.Verify(root, leaves[5]))
+
+	proof, _ = NewMerkleProof(leaves, 5)
+	proof.Index = 4
+	assert.False(t, proof.Verify(root, leaves[5]))
+
+	proof, _ = NewMerkleProof(leaves, 5)
+	proof.Hashes = proof.Hashes[:2]
+	assert.False(t, proof.Verify(root, leaves[5]))
+}
@@ -0,0 +1,272 @@
+package core
+
+import (
+	"blockchain/crypto"
+	"blockchain/types"
+	"bytes"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// prefixes of keys in the NFT registry trie
+const (
+	collectionPrefix      byte = 'c'
+	nftPrefix             byte = 'n'
+	ownerIndexPrefix      byte = 'o'
+	collectionIndexPrefix byte = 'm'
+)
+
+// indexValue is the value of index entries, the trie doesn't keep empty values
+var indexValue = []byte{1}
+
+// Collection is the NFT collection created by the owner, only the owner allows minting into it
+type Collection struct {
+	Owner    crypto.PublicKey
+	MetaData []byte
+	Fee      int64
+}
+
+// NFTOwnership is a change of the NFT owner, the owner is zero if the NFT is burned
+type NFTOwnership struct {
+	Owner           types.Address
+	TransactionHash types.Hash
+	BlockHeight     uint32
+}
+
+// NFT is the minted NFT with its current owner
+type NFT struct {
+	Hash       types.Hash
+	Collection types.Hash
+	MetaData   []byte
+	Owner      types.Address
+	Burned     bool
+	// History holds owners from the mint to the current one
+	History []*NFTOwnership
+}
+
+// withOwner returns copy of the NFT owned by the new owner
+func (nft *NFT) withOwner(ownership *NFTOwnership) *NFT {
+	next := *nft
+	next.Owner = ownership.Owner
+	next.History = append(slices.Clip(nft.History), ownership)
+	return &next
+}
+
+// Bytes returns the canonical encoding of the NFT stored in the registry trie
+func (nft *NFT) Bytes() []byte {
+	w := NewBinaryWriter()
+	w.WriteVersion()
+	w.WriteFixed(nft.Collection[:])
+	w.WriteBytes(nft.MetaData)
+	w.WriteFixed(nft.Owner[:])
+	w.WriteBool(nft.Burned)
+	w.WriteUint32(uint32(len(nft.History)))
+	for _, ownership := range nft.History {
+		w.WriteFixed(ownership.Owner[:])
+		w.WriteFixed(ownership.TransactionHash[:])
+		w.WriteUint32(ownership.BlockHeight)
+	}
+	return w.Bytes()
+}
+
+func decodeNFT(hash types.Hash, b []byte) (*NFT, error) {
+	buf := bytes.NewReader(b)
+	r := NewBinaryReader(buf)
+	r.ReadVersion()
+	nft := &NFT{Hash: hash}
+	r.ReadFixed(nft.Collection[:])
+	nft.MetaData = r.ReadBytes()
+	r.ReadFixed(nft.Owner[:])
+	nft.Burned = r.ReadBool()
+	n := r.ReadUint32()
+	if r.Err() == nil && int64(n) > int64(buf.Len()) {
+		return nil, fmt.Errorf("NFT (%s) has invalid history length (%d)", hash, n)
+	}
+	for range n {
+		ownership := &NFTOwnership{}
+		r.ReadFixed(ownership.Owner[:])
+		r.ReadFixed(ownership.TransactionHash[:])
+		ownership.BlockHeight = r.ReadUint32()
+		nft.History = append(nft.History, ownership)
+	}
+	if err := r.Err(); err != nil {
+		return nil, fmt.Errorf("NFT (%s) has invalid encoding: %w", hash, err)
+	}
+	if buf.Len() != 0 {
+		return nil, fmt.Errorf("NFT (%s) has trailing bytes", hash)
+	}
+	return nft, nil
+}
+
+// Bytes returns the canonical encoding of the collection stored in the registry trie
+func (c *Collection) Bytes() []byte {
+	w := NewBinaryWriter()
+	w.WriteVersion()
+	w.WriteBytes(c.Owner)
+	w.WriteBytes(c.MetaData)
+	w.WriteInt64(c.Fee)
+	return w.Bytes()
+}
+
+func decodeCollection(hash types.Hash, b []byte) (*Collection, error) {
+	buf := bytes.NewReader(b)
+	r := NewBinaryReader(buf)
+	r.ReadVersion()
+	c := &Collection{
+		Owner:    crypto.PublicKey(r.ReadBytes()),
+		MetaData: r.ReadBytes(),
+		Fee:      r.ReadInt64(),
+	}
+	if err := r.Err(); err != nil {
+		return nil, fmt.Errorf("collection (%s) has invalid encoding: %w", hash, err)
+	}
+	if buf.Len() != 0 {
+		return nil, fmt.Errorf("collection (%s) has trailing bytes", hash)
+	}
+	return c, nil
+}
+
+// NFTRegistry holds collections, NFTs with their current owners and indexes of NFTs by owner and collection
+// in a Merkle Patricia trie, so its root commits to who owns every NFT
+type NFTRegistry struct {
+	mu   sync.RWMutex
+	trie *Trie
+}
+
+func NewNFTRegistry() *NFTRegistry {
+	return &NFTRegistry{
+		trie: NewTrie(),
+	}
+}
+
+// Copy returns registry which can be modified independently
+func (r *NFTRegistry) Copy() *NFTRegistry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return &NFTRegistry{
+		trie: r.trie.Copy(),
+	}
+}
+
+// Snapshot returns identifier of the current revision of the registry
+func (r *NFTRegistry) Snapshot() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.trie.Snapshot()
+}
+
+// RevertToSnapshot discards changes made after the snapshot was taken
+func (r *NFTRegistry) RevertToSnapshot(id int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.trie.RevertToSnapshot(id)
+}
+
+// Root returns root hash of the registry trie
+func (r *NFTRegistry) Root() types.Hash {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.trie.Hash()
+}
+
+func registryKey(prefix byte, parts ...[]byte) []byte {
+	return slices.Concat(append([][]byte{{prefix}}, parts...)...)
+}
+
+// Collection returns the collection by its hash
+func (r *NFTRegistry) Collection(hash types.Hash) (*Collection, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	value, ok := r.trie.Get(registryKey(collectionPrefix, hash[:]))
+	if !ok {
+		return nil, false
+	}
+	c, err := decodeCollection(hash, value)
+	if err != nil {
+		return nil, false
+	}
+	return c, true
+}
+
+func (r *NFTRegistry) putCollection(hash types.Hash, c *Collection) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.trie.Put(registryKey(collectionPrefix, hash[:]), c.Bytes())
+}
+
+// NFT returns the NFT by its hash, burned NFTs are returned as well
+func (r *NFTRegistry) NFT(hash types.Hash) (*NFT, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.nft(hash)
+}
+
+func (r *NFTRegistry) nft(hash types.Hash) (*NFT, bool) {
+	value, ok := r.trie.Get(registryKey(nftPrefix, hash[:]))
+	if !ok {
+		return nil, false
+	}
+	nft, err := decodeNFT(hash, value)
+	if err != nil {
+		return nil, false
+	}
+	return nft, true
+}
+
+// ByOwner returns NFTs of the owner in ascending order of their hashes
+func (r *NFTRegistry) ByOwner(owner types.Address) []*NFT {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.lookup(registryKey(ownerIndexPrefix, owner[:]))
+}
+
+// ByCollection returns NFTs of the collection which aren't burned in ascending order of their hashes
+func (r *NFTRegistry) ByCollection(collection types.Hash) []*NFT {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var nfts []*NFT
+	for _, nft := range r.lookup(registryKey(collectionIndexPrefix, collection[:])) {
+		if !nft.Burned {
+			nfts = append(nfts, nft)
+		}
+	}
+	return nfts
+}
+
+// lookup returns NFTs which hashes follow the index prefix in the keys
+func (r *NFTRegistry) lookup(prefix []byte) []*NFT {
+	var nfts []*NFT
+	r.trie.Iterate(prefix, nil, func(key, _ []byte) bool {
+		if nft, ok := r.nft(types.Hash(key[len(prefix):])); ok {
+			nfts = append(nfts, nft)
+		}
+		return true
+	})
+	return nfts
+}
+
+// put replaces the record of the NFT and updates the indexes
+func (r *NFTRegistry) put(nft *NFT) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if prev, ok := r.nft(nft.Hash); ok {
+		r.trie.Delete(registryKey(ownerIndexPrefix, prev.Owner[:], nft.Hash[:]))
+	} else {
+		r.trie.Put(registryKey(collectionIndexPrefix, nft.Collection[:], nft.Hash[:]), indexValue)
+	}
+	if !nft.Burned {
+		r.trie.Put(registryKey(ownerIndexPrefix, nft.Owner[:], nft.Hash[:]), indexValue)
+	}
+	r.trie.Put(registryKey(nftPrefix, nft.Hash[:]), nft.Bytes())
+}
@@ -0,0 +1,50 @@
+package core
+
+import (
+	"blockchain/types"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNFTRegistry_Snapshot(t *testing.T) {
+	r := NewNFTRegistry()
+	alice, bob := types.Address{1}, types.Address{2}
+	nft := &NFT{Hash: types.Hash{1}, Collection: types.Hash{9}, MetaData: []byte("nft")}
+	r.put(nft.withOwner(&NFTOwnership{Owner: alice}))
+	copied := r.Copy()
+	root := r.Root()
+
+	snap := r.Snapshot()
+	minted, _ := r.NFT(nft.Hash)
+	r.put(minted.withOwner(&NFTOwnership{Owner: bob}))
+	assert.Empty(t, r.ByOwner(alice))
+	assert.Equal(t, bob, r.ByOwner(bob)[0].Owner)
+	assert.Equal(t, 2, len(r.ByCollection(types.Hash{9})[0].History))
+	assert.NotEqual(t, root, r.Root())
+
+	// copies don't see the changes
+	assert.Equal(t, 1, len(copied.ByOwner(alice)))
+	assert.Empty(t, copied.ByOwner(bob))
+
+	r.RevertToSnapshot(snap)
+	assert.Equal(t, root, r.Root())
+	assert.Equal(t, []*NFT{minted}, r.ByOwner(alice))
+	assert.Empty(t, r.ByOwner(bob))
+}
+
+func TestNFTRegistry_Burned(t *testing.T) {
+	r := NewNFTRegistry()
+	alice := types.Address{1}
+	nft := (&NFT{Hash: types.Hash{1}, Collection: types.Hash{9}}).withOwner(&NFTOwnership{Owner: alice})
+	r.put(nft)
+	r.put((&NFT{Hash: types.Hash{2}, Collection: types.Hash{9}}).withOwner(&NFTOwnership{Owner: alice}))
+
+	burned := nft.withOwner(&NFTOwnership{})
+	burned.Burned = true
+	r.put(burned)
+	assert.Equal(t, 1, len(r.ByOwner(alice)))
+	assert.Equal(t, 1, len(r.ByCollection(types.Hash{9})))
+	stored, ok := r.NFT(nft.Hash)
+	assert.True(t, ok)
+	assert.Equal(t, burned, stored)
+}
@@ -0,0 +1,69 @@
+package core
+
+import (
+	"blockchain/types"
+)
+
+type ReceiptStatus byte
+
+const (
+	ReceiptStatusFailed ReceiptStatus = iota
+	ReceiptStatusSuccess
+)
+
+func (s ReceiptStatus) String() string {
+	switch s {
+	case ReceiptStatusFailed:
+		return "failed"
+	case ReceiptStatusSuccess:
+		return "success"
+	default:
+		return "unknown"
+	}
+}
+
+// Log is an event emitted during transaction execution
+type Log struct {
+	// Address is the address of the contract which emitted the log
+	Address types.Address
+	Topics  []types.Hash
+	Data    []byte
+	// derived fields, they aren't part of the receipt hash
+	BlockHeight     uint32
+	TransactionHash types.Hash
+}
+
+// Receipt is the result of the transaction execution
+type Receipt struct {
+	TransactionHash types.Hash
+	Status          ReceiptStatus
+	// Error describes why the transaction failed, it isn't part of the receipt hash
+	Error   string
+	GasUsed uint64
+	// ContractAddress is the address of the contract deployed by the transaction
+	ContractAddress types.Address
+	Logs            []*Log
+	BlockHeight     uint32
+	// Index is the position of the transaction in the block
+	Index uint32
+}
+
+// Bytes returns the canonical encoding of the receipt used for hashing
+func (r *Receipt) Bytes() []byte {
+	w := NewBinaryWriter()
+	writeReceipt(w, r)
+	return w.Bytes()
+}
+
+func (r *Receipt) Hash(hasher Hasher[*Receipt]) types.Hash {
+	return hasher.Hash(r)
+}
+
+// HashReceipts returns root of the Merkle tree of receipt hashes
+func HashReceipts(receipts []*Receipt) types.Hash {
+	leaves := make([]types.Hash, len(receipts))
+	for i, r := range receipts {
+		leaves[i] = r.Hash(ReceiptHasher{})
+	}
+	return MerkleRoot(leaves)
+}
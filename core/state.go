@@ -1,25 +1,75 @@
 package core
 
 import (
+	"blockchain/types"
 	"fmt"
+	"sync"
 )
 
+// prefixes of contract code and storage keys in the state trie
+const (
+	codePrefix    byte = 'c'
+	storagePrefix byte = 's'
+)
+
+// State holds code and storage of contracts in a Merkle Patricia trie
 type State struct {
-	data map[string][]byte
+	mu   sync.RWMutex
+	trie *Trie
 }
 
 func NewState() *State {
 	return &State{
-		data: make(map[string][]byte),
+		trie: NewTrie(),
+	}
+}
+
+// Copy returns state which can be modified independently
+func (s *State) Copy() *State {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return &State{
+		trie: s.trie.Copy(),
 	}
 }
 
+// Snapshot returns identifier of the current revision of the state
+func (s *State) Snapshot() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.trie.Snapshot()
+}
+
+// RevertToSnapshot discards changes made after the snapshot was taken
+func (s *State) RevertToSnapshot(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.trie.RevertToSnapshot(id)
+}
+
+// Root returns root hash of the state trie
+func (s *State) Root() types.Hash {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.trie.Hash()
+}
+
 func (s *State) Add(k, v []byte) {
-	s.data[string(k)] = v
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.trie.Put(k, v)
 }
 
 func (s *State) Get(k []byte) ([]byte, error) {
-	value, ok := s.data[string(k)]
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	value, ok := s.trie.Get(k)
 	if !ok {
 		return nil, fmt.Errorf("State.Get: given key (%s) not found", k)
 	}
@@ -27,5 +77,137 @@ func (s *State) Get(k []byte) ([]byte, error) {
 }
 
 func (s *State) Delete(k []byte) {
-	delete(s.data, string(k))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.trie.Delete(k)
+}
+
+func codeKey(addr types.Address) []byte {
+	return append([]byte{codePrefix}, addr[:]...)
+}
+
+// Code returns code of the contract
+func (s *State) Code(addr types.Address) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	code, ok := s.trie.Get(codeKey(addr))
+	if !ok {
+		return nil, fmt.Errorf("contract (%s) not found", addr)
+	}
+	return code, nil
+}
+
+// HasCode reports whether a contract is deployed at the address
+func (s *State) HasCode(addr types.Address) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.trie.Get(codeKey(addr))
+	return ok
+}
+
+// SetCode deploys the contract code at the address
+func (s *State) SetCode(addr types.Address, code []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := codeKey(addr)
+	if _, ok := s.trie.Get(key); ok {
+		return fmt.Errorf("contract (%s) already exists", addr)
+	}
+	if len(code) == 0 {
+		return fmt.Errorf("contract (%s) has no code", addr)
+	}
+	s.trie.Put(key, code)
+	return nil
+}
+
+// StorageEntry is a key-value pair of the contract storage
+// along with the proof of the value against the root of the state trie
+type StorageEntry struct {
+	Key   []byte
+	Value []byte
+	Proof [][]byte
+}
+
+func storageKey(addr types.Address, key []byte) []byte {
+	return append(append([]byte{storagePrefix}, addr[:]...), key...)
+}
+
+// StorageEntry returns the entry of the contract storage, the value is nil if the key is absent
+func (s *State) StorageEntry(addr types.Address, key []byte) *StorageEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	k := storageKey(addr, key)
+	value, _ := s.trie.Get(k)
+	return &StorageEntry{
+		Key:   key,
+		Value: value,
+		Proof: s.trie.Prove(k),
+	}
+}
+
+// StorageRange returns at most limit entries of the contract storage in ascending order of keys.
+// Only keys having the prefix and not less than the start key are returned.
+// The key of the entry following the last returned one is returned if there are more entries.
+func (s *State) StorageRange(addr types.Address, prefix, start []byte, limit int) ([]*StorageEntry, []byte) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var (
+		entries []*StorageEntry
+		next    []byte
+	)
+	contractPrefix := storageKey(addr, nil)
+	s.trie.Iterate(storageKey(addr, prefix), storageKey(addr, start), func(key, value []byte) bool {
+		if len(entries) == limit {
+			next = key[len(contractPrefix):]
+			return false
+		}
+		entries = append(entries, &StorageEntry{
+			Key:   key[len(contractPrefix):],
+			Value: value,
+			Proof: s.trie.Prove(key),
+		})
+		return true
+	})
+	return entries, next
+}
+
+// ContractStorage returns storage of the contract,
+// keys of different contracts never collide
+func (s *State) ContractStorage(addr types.Address) ContractStorage {
+	return &contractStorage{
+		state:  s,
+		prefix: storageKey(addr, nil),
+	}
+}
+
+// contractStorage prefixes keys with the contract address
+type contractStorage struct {
+	state  *State
+	prefix []byte
+}
+
+func (c *contractStorage) key(k []byte) []byte {
+	return append(append([]byte{}, c.prefix...), k...)
+}
+
+func (c *contractStorage) Add(k, v []byte) {
+	c.state.Add(c.key(k), v)
+}
+
+func (c *contractStorage) Get(k []byte) ([]byte, error) {
+	value, err := c.state.Get(c.key(k))
+	if err != nil {
+		return nil, fmt.Errorf("contract storage: given key (%s) not found", k)
+	}
+	return value, nil
+}
+
+func (c *contractStorage) Delete(k []byte) {
+	c.state.Delete(c.key(k))
 }
@@ -1,16 +1,91 @@
 package core
 
+import (
+	"blockchain/types"
+	"fmt"
+	"sync"
+)
+
+// Storage persists blocks of the blockchain.
+// Blocks are put in height order starting with the genesis block.
 type Storage interface {
 	Put(*Block) error
+	Get(height uint32) (*Block, error)
+	GetByHeaderHash(hash types.Hash) (*Block, error)
+	Has(hash types.Hash) bool
+	// Iterate calls fn for every stored block in height order
+	// and stops on the first error returned by fn
+	Iterate(fn func(*Block) error) error
 }
 
 type MemoryStore struct {
+	mu        sync.RWMutex
+	blocks    []*Block
+	blocksMap map[types.Hash]*Block
 }
 
 func NewMemoryStore() *MemoryStore {
-	return &MemoryStore{}
+	return &MemoryStore{
+		blocksMap: make(map[types.Hash]*Block),
+	}
 }
 
 func (s *MemoryStore) Put(b *Block) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if int(b.Height) != len(s.blocks) {
+		return fmt.Errorf("MemoryStore.Put: expected block with height (%d), got (%d)", len(s.blocks), b.Height)
+	}
+
+	s.blocks = append(s.blocks, b)
+	s.blocksMap[b.HeaderHash(HeaderHasher{})] = b
+
+	return nil
+}
+
+func (s *MemoryStore) Get(height uint32) (*Block, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if int(height) >= len(s.blocks) {
+		return nil, fmt.Errorf("MemoryStore.Get: block with height (%d) not found", height)
+	}
+
+	return s.blocks[height], nil
+}
+
+func (s *MemoryStore) GetByHeaderHash(hash types.Hash) (*Block, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	b, ok := s.blocksMap[hash]
+	if !ok {
+		return nil, fmt.Errorf("MemoryStore.GetByHeaderHash: block with header hash (%s) not found", hash)
+	}
+
+	return b, nil
+}
+
+func (s *MemoryStore) Has(hash types.Hash) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.blocksMap[hash]
+
+	return ok
+}
+
+func (s *MemoryStore) Iterate(fn func(*Block) error) error {
+	s.mu.RLock()
+	blocks := s.blocks
+	s.mu.RUnlock()
+
+	for _, b := range blocks {
+		if err := fn(b); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
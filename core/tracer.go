@@ -0,0 +1,118 @@
+package core
+
+import (
+	"blockchain/types"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+)
+
+// Tracer is notified about the VM execution, nested calls are traced by the same tracer
+type Tracer interface {
+	// CaptureStep is called before the instruction at pc is executed,
+	// gas is the gas left before paying the instruction cost
+	CaptureStep(pc int, op Instruction, gas, cost uint64, depth int, stack *Stack)
+	// CaptureStore is called when the contract writes to its storage
+	CaptureStore(addr types.Address, key, value []byte)
+	// CaptureEnd is called when the VM at the given depth stops
+	CaptureEnd(depth int, gasUsed uint64, returnData []byte, err error)
+}
+
+// StorageWrite is a write to the contract storage
+type StorageWrite struct {
+	Address types.Address
+	Key     []byte
+	Value   []byte
+}
+
+// TraceStep is an executed instruction, stack items are listed from the bottom
+type TraceStep struct {
+	Pc      int
+	Op      Instruction
+	Gas     uint64
+	GasCost uint64
+	Depth   int
+	Stack   []string
+	Writes  []*StorageWrite
+	Error   string
+}
+
+// Trace is the execution trace of a transaction
+type Trace struct {
+	Steps      []*TraceStep
+	GasUsed    uint64
+	ReturnData []byte
+	Error      string
+}
+
+// StructTracer records every step of the execution
+type StructTracer struct {
+	trace Trace
+}
+
+func NewStructTracer() *StructTracer {
+	return &StructTracer{}
+}
+
+// Trace returns the recorded trace
+func (t *StructTracer) Trace() *Trace {
+	return &t.trace
+}
+
+func (t *StructTracer) CaptureStep(pc int, op Instruction, gas, cost uint64, depth int, stack *Stack) {
+	items := make([]string, stack.Len())
+	for i := range items {
+		item, _ := stack.Peek(len(items) - i)
+		items[i] = stackItemString(item)
+	}
+	t.trace.Steps = append(t.trace.Steps, &TraceStep{
+		Pc:      pc,
+		Op:      op,
+		Gas:     gas,
+		GasCost: cost,
+		Depth:   depth,
+		Stack:   items,
+	})
+}
+
+func (t *StructTracer) CaptureStore(addr types.Address, key, value []byte) {
+	if len(t.trace.Steps) == 0 {
+		return
+	}
+	step := t.trace.Steps[len(t.trace.Steps)-1]
+	step.Writes = append(step.Writes, &StorageWrite{
+		Address: addr,
+		Key:     append([]byte{}, key...),
+		Value:   append([]byte{}, value...),
+	})
+}
+
+// CaptureEnd records the error of the failed step and the result of the outermost VM
+func (t *StructTracer) CaptureEnd(depth int, gasUsed uint64, returnData []byte, err error) {
+	if err != nil && len(t.trace.Steps) > 0 {
+		if step := t.trace.Steps[len(t.trace.Steps)-1]; step.Error == "" {
+			step.Error = err.Error()
+		}
+	}
+	if depth == 0 {
+		t.trace.GasUsed = gasUsed
+		t.trace.ReturnData = returnData
+		if err != nil {
+			t.trace.Error = err.Error()
+		}
+	}
+}
+
+// stackItemString returns the stack item in hex
+func stackItemString(item any) string {
+	switch v := item.(type) {
+	case *big.Int:
+		return "0x" + v.Text(16)
+	case []byte:
+		return "0x" + hex.EncodeToString(v)
+	case byte:
+		return fmt.Sprintf("0x%02x", v)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
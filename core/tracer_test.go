@@ -0,0 +1,59 @@
+package core
+
+import (
+	"blockchain/types"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"testing"
+)
+
+func TestStructTracer(t *testing.T) {
+	caller := types.Address{1}
+	callee := types.Address{2}
+	state := NewState()
+	accounts := NewAccountsState()
+	assert.Nil(t, state.SetCode(callee, new(Instr).Int(5).Int(1).SStore().Bytes()))
+
+	ins := new(Instr).Int(0).Address(callee).Int(1_000).Call()
+	ins.Int(2).Int(3).SStore().Int(1).Int(0).op(InstrDiv)
+	tracer := NewStructTracer()
+	vm := NewVM(&Context{Address: caller}, ins.Bytes(), state, accounts, DefaultGasLimit)
+	vm.SetTracer(tracer)
+	err := vm.Run()
+	assert.ErrorIs(t, err, ErrDivisionByZero)
+
+	trace := tracer.Trace()
+	steps := trace.Steps
+	assert.Len(t, steps, 13)
+	assert.Equal(t, InstrPush1, steps[0].Op)
+	assert.Equal(t, DefaultGasLimit, steps[0].Gas)
+	assert.Equal(t, []string{"0x0"}, steps[1].Stack)
+	assert.Equal(t, DefaultGasLimit-GasQuick, steps[1].Gas)
+
+	// the callee steps are nested in the call
+	assert.Equal(t, InstrCall, steps[3].Op)
+	assert.Equal(t, 1, steps[4].Depth)
+	assert.Equal(t, InstrSStore, steps[6].Op)
+	assert.Equal(t, []*StorageWrite{{callee, wordBytes(big.NewInt(1)), wordBytes(big.NewInt(5))}}, steps[6].Writes)
+	assert.Equal(t, 0, steps[7].Depth)
+	assert.Equal(t, []string{"0x1"}, steps[7].Stack)
+	assert.Equal(t, caller, steps[9].Writes[0].Address)
+
+	assert.Equal(t, InstrDiv, steps[12].Op)
+	assert.Contains(t, steps[12].Error, ErrDivisionByZero.Error())
+	assert.Equal(t, vm.GasUsed(), trace.GasUsed)
+	assert.NotEmpty(t, trace.Error)
+}
+
+func TestVM_Step(t *testing.T) {
+	vm := NewVM(&Context{}, new(Instr).Add(1, 2).Bytes(), NewState(), NewAccountsState(), DefaultGasLimit)
+	assert.Nil(t, vm.Step())
+	assert.Equal(t, 2, vm.PC())
+	assert.Equal(t, 1, vm.Stack().Len())
+	assert.Nil(t, vm.Step())
+	assert.False(t, vm.Done())
+	assert.Nil(t, vm.Step())
+	assert.True(t, vm.Done())
+	assert.Equal(t, int64(3), popInt64(t, vm))
+	assert.Nil(t, vm.Step())
+}
@@ -3,48 +3,94 @@ package core
 import (
 	"blockchain/crypto"
 	"blockchain/types"
-	"encoding/gob"
+	"crypto/sha256"
+	"encoding/binary"
 	"fmt"
 	"math/big"
-	"math/rand"
 )
 
-type Collection struct {
-	MetaData []byte
-	Fee      int64
+type Transaction struct {
+	// ChainID is the id of the chain the transaction is valid on
+	ChainID uint64
+	From    crypto.PublicKey
+	// Nonce must be equal to the nonce of the sender account
+	Nonce uint64
+	// GasLimit is the maximum amount of gas the transaction can use
+	GasLimit uint64
+	// GasPrice is the price the sender pays for a unit of gas
+	GasPrice *big.Int
+	// Payload holds the fields of the transaction type
+	Payload   TxPayload
+	Signature *crypto.Signature
 }
 
-type Mint struct {
-	MetaData        []byte
-	Fee             int64
-	NFT             types.Hash
-	Collection      types.Hash
-	CollectionOwner crypto.PublicKey
-	Signature       crypto.Signature
+func NewTransaction(payload TxPayload) *Transaction {
+	return &Transaction{
+		Payload:  payload,
+		GasLimit: DefaultGasLimit,
+	}
 }
 
-type Transaction struct {
-	// for NFT
-	Inner any
-	// for VM
-	Data      []byte
-	From      crypto.PublicKey
-	To        crypto.PublicKey
-	Value     *big.Int
-	Signature *crypto.Signature
-	Nonce     uint64
+// Type returns type of the transaction payload, it's zero if the transaction has no payload
+func (tx *Transaction) Type() TxType {
+	if tx.Payload == nil {
+		return 0
+	}
+	return tx.Payload.Type()
 }
 
-func NewTransaction(data []byte) *Transaction {
-	return &Transaction{
-		Data:  data,
-		Nonce: rand.Uint64(),
+// IsDeployment reports whether the transaction deploys a contract
+func (tx *Transaction) IsDeployment() bool {
+	return tx.Type() == TxTypeDeploy
+}
+
+// Value returns the value sent by the transaction, it's zero if the payload doesn't send value
+func (tx *Transaction) Value() *big.Int {
+	var value *big.Int
+	switch p := tx.Payload.(type) {
+	case *Transfer:
+		value = p.Value
+	case *Deploy:
+		value = p.Value
+	case *Call:
+		value = p.Value
+	}
+	if value == nil {
+		return new(big.Int)
+	}
+	return value
+}
+
+// Validate checks the transaction has a payload of a registered type with valid fields
+// a non-negative gas price and a gas limit not exceeding MaxGasLimit
+func (tx *Transaction) Validate() error {
+	if tx.GasPrice != nil && tx.GasPrice.Sign() < 0 {
+		return fmt.Errorf("%w: negative gas price (%s)", ErrInvalidTransaction, tx.GasPrice)
+	}
+	if tx.GasLimit > MaxGasLimit {
+		return fmt.Errorf("%w: transaction has gas limit (%d), maximum (%d)", ErrGasLimitTooHigh, tx.GasLimit, MaxGasLimit)
+	}
+	if tx.Payload == nil {
+		return fmt.Errorf("%w: transaction has no payload", ErrInvalidTransaction)
+	}
+	if _, ok := txPayloads[tx.Type()]; !ok {
+		return fmt.Errorf("%w: unknown transaction type (%s)", ErrInvalidTransaction, tx.Type())
 	}
+	return tx.Payload.Validate()
+}
+
+// ContractAddress returns address of the contract deployed by the sender with the given nonce
+func ContractAddress(from types.Address, nonce uint64) types.Address {
+	hash := sha256.Sum256(binary.BigEndian.AppendUint64(from[:], nonce))
+	return types.AddressFromBytes(hash[12:])
 }
 
 func (tx *Transaction) Sign(priv *crypto.PrivateKey) error {
 	tx.From = priv.PublicKey()
-	hash := tx.Hash(TransactionHasher{})
+	hash, err := tx.signingHash()
+	if err != nil {
+		return err
+	}
 	sig, err := priv.Sign(hash[:])
 	if err != nil {
 		return err
@@ -53,18 +99,43 @@ func (tx *Transaction) Sign(priv *crypto.PrivateKey) error {
 	return nil
 }
 
+// Verify validates the transaction and checks its signature
 func (tx *Transaction) Verify() error {
+	if err := tx.Validate(); err != nil {
+		return err
+	}
 	if tx.Signature == nil {
 		return fmt.Errorf("transaction has no signature")
 	}
+	if !tx.Signature.Valid() {
+		return fmt.Errorf("transaction signature has zero component")
+	}
+	if !tx.From.Valid() {
+		return fmt.Errorf("transaction has invalid sender public key (%s)", tx.From)
+	}
 
-	hash := tx.Hash(TransactionHasher{})
+	hash, err := tx.signingHash()
+	if err != nil {
+		return err
+	}
 	if !tx.Signature.Verify(tx.From, hash[:]) {
 		return fmt.Errorf("invalid transaction signature")
 	}
 	return nil
 }
 
+// signingHash returns hash of the transaction domain and the codec version followed by
+// the canonical encoding of every field except the signature, it's the hash of the transaction
+func (tx *Transaction) signingHash() (types.Hash, error) {
+	w := NewBinaryWriter()
+	w.WriteString(transactionDomain)
+	w.WriteVersion()
+	if err := writeSignedFields(w, tx); err != nil {
+		return types.Hash{}, err
+	}
+	return sha256.Sum256(w.Bytes()), nil
+}
+
 func (tx *Transaction) Hash(hasher Hasher[*Transaction]) types.Hash {
 	return hasher.Hash(tx)
 }
@@ -76,8 +147,3 @@ func (tx *Transaction) Encode(enc Encoder[*Transaction]) error {
 func (tx *Transaction) Decode(dec Decoder[*Transaction]) error {
 	return dec.Decode(tx)
 }
-
-func init() {
-	gob.Register(&Collection{})
-	gob.Register(&Mint{})
-}
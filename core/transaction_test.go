@@ -2,6 +2,7 @@ package core
 
 import (
 	"blockchain/crypto"
+	"blockchain/types"
 	"bytes"
 	"github.com/stretchr/testify/assert"
 	"math/big"
@@ -11,7 +12,8 @@ import (
 func randomTxWithSignature() *Transaction {
 	privateKey := crypto.GeneratePrivateKey()
 	tx := &Transaction{
-		Data: []byte("hey"),
+		GasLimit: DefaultGasLimit,
+		Payload:  &Deploy{Code: []byte("hey")},
 	}
 	tx.Sign(privateKey)
 	return tx
@@ -20,7 +22,7 @@ func randomTxWithSignature() *Transaction {
 func TestTransaction_SignAndVerify(t *testing.T) {
 	privateKey := crypto.GeneratePrivateKey()
 	tx := &Transaction{
-		Data: []byte("hey"),
+		Payload: &Deploy{Code: []byte("hey")},
 	}
 	assert.Nil(t, tx.Sign(privateKey))
 	assert.Nil(t, tx.Verify())
@@ -30,32 +32,44 @@ func TestTransaction_SignAndVerify(t *testing.T) {
 	assert.NotNil(t, tx.Verify())
 }
 
+func TestTransaction_VerifyInvalidSignature(t *testing.T) {
+	tx := randomTxWithSignature()
+	sig := tx.Signature
+
+	tx.Signature = &crypto.Signature{S: sig.S}
+	assert.ErrorContains(t, tx.Verify(), "zero component")
+
+	tx.Signature = sig
+	tx.From = crypto.PublicKey{0x02, 0xaa}
+	assert.ErrorContains(t, tx.Verify(), "invalid sender public key")
+}
+
 func TestTransaction_EncodeDecode(t *testing.T) {
 	tx := randomTxWithSignature()
 	buf := new(bytes.Buffer)
-	assert.Nil(t, tx.Encode(NewGobTransactionEncoder(buf)))
+	assert.Nil(t, tx.Encode(NewBinaryTransactionEncoder(buf)))
 
 	txDecoded := new(Transaction)
-	assert.Nil(t, txDecoded.Decode(NewGobTransactionDecoder(buf)))
+	assert.Nil(t, txDecoded.Decode(NewBinaryTransactionDecoder(buf)))
 	assert.Equal(t, tx, txDecoded)
 }
 
 func TestNFTTransaction(t *testing.T) {
-	coll := &Collection{
+	coll := &CreateCollection{
 		MetaData: []byte("ok"),
 		Fee:      200,
 	}
 	privateKey := crypto.GeneratePrivateKey()
 	tx := &Transaction{
-		Inner: coll,
+		Payload: coll,
 	}
 	assert.Nil(t, tx.Sign(privateKey))
 
 	buf := new(bytes.Buffer)
-	assert.Nil(t, tx.Encode(NewGobTransactionEncoder(buf)))
+	assert.Nil(t, tx.Encode(NewBinaryTransactionEncoder(buf)))
 
 	txDecoded := new(Transaction)
-	assert.Nil(t, txDecoded.Decode(NewGobTransactionDecoder(buf)))
+	assert.Nil(t, txDecoded.Decode(NewBinaryTransactionDecoder(buf)))
 	assert.Equal(t, tx, txDecoded)
 }
 
@@ -63,8 +77,10 @@ func TestTransferTransaction(t *testing.T) {
 	fromPrivateKey := crypto.GeneratePrivateKey()
 	toPrivateKey := crypto.GeneratePrivateKey()
 	tx := &Transaction{
-		To:    toPrivateKey.PublicKey(),
-		Value: new(big.Int).SetUint64(1_000_000_000_000),
+		Payload: &Transfer{
+			To:    toPrivateKey.PublicKey().Address(),
+			Value: new(big.Int).SetUint64(1_000_000_000_000),
+		},
 	}
 	assert.Nil(t, tx.Sign(fromPrivateKey))
 }
@@ -74,14 +90,85 @@ func TestTransactionHacked(t *testing.T) {
 	alice := crypto.GeneratePrivateKey()
 	hacker := crypto.GeneratePrivateKey()
 
-	tx := NewTransaction(nil)
+	transfer := &Transfer{
+		To:    alice.PublicKey().Address(),
+		Value: new(big.Int).SetInt64(1_000_000_000),
+	}
+	tx := NewTransaction(transfer)
 	tx.From = bob.PublicKey()
-	tx.To = alice.PublicKey()
-	tx.Value = new(big.Int).SetInt64(1_000_000_000)
 
 	assert.Nil(t, tx.Sign(bob))
 
-	tx.To = hacker.PublicKey()
+	transfer.To = hacker.PublicKey().Address()
+
+	assert.NotNil(t, tx.Verify())
+}
+
+func TestTransaction_HashCoversAllFields(t *testing.T) {
+	privateKey := crypto.GeneratePrivateKey()
+	mint := &Mint{
+		MetaData:   []byte("nft"),
+		Fee:        100,
+		NFT:        types.Hash{1},
+		Collection: types.Hash{2},
+	}
+	assert.Nil(t, mint.Sign(privateKey, 1, privateKey.PublicKey().Address()))
+	tx := &Transaction{
+		Payload: mint,
+		ChainID: 1,
+	}
+	assert.Nil(t, tx.Sign(privateKey))
+	assert.Nil(t, tx.Verify())
+
+	mint.Collection = types.Hash{3}
+	assert.NotNil(t, tx.Verify())
+	mint.Collection = types.Hash{2}
+
+	tx.Payload = &CreateCollection{MetaData: []byte("nft"), Fee: 100}
+	assert.NotNil(t, tx.Verify())
+	tx.Payload = mint
+
+	tx.ChainID = 2
+	assert.NotNil(t, tx.Verify())
+	tx.ChainID = 1
 
+	tx.GasPrice = big.NewInt(1)
 	assert.NotNil(t, tx.Verify())
+	tx.GasPrice = nil
+	assert.Nil(t, tx.Verify())
+
+	tx.Payload = nil
+	assert.NotNil(t, tx.Sign(privateKey))
+	assert.NotNil(t, tx.Verify())
+	assert.Equal(t, types.Hash{}, tx.Hash(TransactionHasher{}))
+}
+
+func TestTransaction_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload TxPayload
+		valid   bool
+	}{
+		{"no payload", nil, false},
+		{"transfer", &Transfer{To: types.Address{1}, Value: big.NewInt(1)}, true},
+		{"transfer without recipient", &Transfer{Value: big.NewInt(1)}, false},
+		{"transfer without value", &Transfer{To: types.Address{1}}, false},
+		{"deploy", &Deploy{Code: []byte("hey")}, true},
+		{"deploy without code", &Deploy{Value: big.NewInt(1)}, false},
+		{"call", &Call{Contract: types.Address{1}}, true},
+		{"call without contract", &Call{Input: []byte("hey")}, false},
+		{"negative value", &Call{Contract: types.Address{1}, Value: big.NewInt(-1)}, false},
+		{"negative fee", &CreateCollection{Fee: -1}, false},
+		{"mint without collection", &Mint{NFT: types.Hash{1}}, false},
+		{"mint without owner signature", &Mint{NFT: types.Hash{1}, Collection: types.Hash{2}}, false},
+	}
+
+	for _, test := range tests {
+		err := NewTransaction(test.payload).Validate()
+		if test.valid {
+			assert.Nil(t, err, test.name)
+		} else {
+			assert.ErrorIs(t, err, ErrInvalidTransaction, test.name)
+		}
+	}
 }
@@ -0,0 +1,548 @@
+package core
+
+import (
+	"blockchain/types"
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+)
+
+const (
+	trieNodeLeaf byte = iota
+	trieNodeExtension
+	trieNodeBranch
+)
+
+type trieNode interface {
+	Hash() types.Hash
+	// encode returns the canonical encoding of the node
+	// which refers to child nodes by their hashes
+	encode() []byte
+}
+
+// hashTrieNode returns hash of the trie node domain followed by the encoded node
+func hashTrieNode(enc []byte) types.Hash {
+	w := NewBinaryWriter()
+	w.WriteString(trieNodeDomain)
+	w.WriteFixed(enc)
+	return sha256.Sum256(w.Bytes())
+}
+
+type leafNode struct {
+	path  []byte
+	value []byte
+	hash  types.Hash
+}
+
+func newLeafNode(path, value []byte) *leafNode {
+	n := &leafNode{
+		path:  path,
+		value: value,
+	}
+	n.hash = hashTrieNode(n.encode())
+	return n
+}
+
+func (n *leafNode) Hash() types.Hash {
+	return n.hash
+}
+
+func (n *leafNode) encode() []byte {
+	w := NewBinaryWriter()
+	w.WriteVersion()
+	w.WriteUint8(trieNodeLeaf)
+	w.WriteBytes(n.path)
+	w.WriteBytes(n.value)
+	return w.Bytes()
+}
+
+type extensionNode struct {
+	path  []byte
+	child trieNode
+	hash  types.Hash
+}
+
+func newExtensionNode(path []byte, child trieNode) *extensionNode {
+	n := &extensionNode{
+		path:  path,
+		child: child,
+	}
+	n.hash = hashTrieNode(n.encode())
+	return n
+}
+
+func (n *extensionNode) Hash() types.Hash {
+	return n.hash
+}
+
+func (n *extensionNode) encode() []byte {
+	w := NewBinaryWriter()
+	w.WriteVersion()
+	w.WriteUint8(trieNodeExtension)
+	w.WriteBytes(n.path)
+	childHash := n.child.Hash()
+	w.WriteFixed(childHash[:])
+	return w.Bytes()
+}
+
+type branchNode struct {
+	children [16]trieNode
+	value    []byte
+	hash     types.Hash
+}
+
+func newBranchNode(children [16]trieNode, value []byte) *branchNode {
+	n := &branchNode{
+		children: children,
+		value:    value,
+	}
+	n.hash = hashTrieNode(n.encode())
+	return n
+}
+
+func (n *branchNode) Hash() types.Hash {
+	return n.hash
+}
+
+func (n *branchNode) encode() []byte {
+	w := NewBinaryWriter()
+	w.WriteVersion()
+	w.WriteUint8(trieNodeBranch)
+	for _, child := range n.children {
+		var childHash types.Hash
+		if child != nil {
+			childHash = child.Hash()
+		}
+		w.WriteFixed(childHash[:])
+	}
+	w.WriteBytes(n.value)
+	return w.Bytes()
+}
+
+// Trie is a Merkle Patricia trie.
+// Nodes are immutable, every modification creates new nodes on the path
+// to the modified key, so copying the trie is cheap.
+// Trie isn't safe for concurrent use.
+type Trie struct {
+	root trieNode
+	// roots saved by Snapshot
+	snapshots []trieNode
+}
+
+func NewTrie() *Trie {
+	return &Trie{}
+}
+
+// Hash returns the root hash of the trie, empty trie has zero hash
+func (t *Trie) Hash() types.Hash {
+	if t.root == nil {
+		return types.Hash{}
+	}
+	return t.root.Hash()
+}
+
+// Copy returns a trie which shares nodes with the original
+// but can be modified independently, snapshots aren't copied
+func (t *Trie) Copy() *Trie {
+	return &Trie{root: t.root}
+}
+
+// Snapshot returns identifier of the current revision of the trie
+func (t *Trie) Snapshot() int {
+	t.snapshots = append(t.snapshots, t.root)
+	return len(t.snapshots) - 1
+}
+
+// RevertToSnapshot discards changes made after the snapshot was taken.
+// Snapshots taken after the given one become invalid.
+func (t *Trie) RevertToSnapshot(id int) {
+	if id < 0 || id >= len(t.snapshots) {
+		panic(fmt.Sprintf("Trie.RevertToSnapshot: invalid snapshot id (%d)", id))
+	}
+	t.root = t.snapshots[id]
+	t.snapshots = t.snapshots[:id]
+}
+
+func (t *Trie) Get(key []byte) ([]byte, bool) {
+	path := keyToNibbles(key)
+	node := t.root
+	for {
+		switch n := node.(type) {
+		case nil:
+			return nil, false
+		case *leafNode:
+			if !bytes.Equal(n.path, path) {
+				return nil, false
+			}
+			return n.value, true
+		case *extensionNode:
+			if !bytes.HasPrefix(path, n.path) {
+				return nil, false
+			}
+			path = path[len(n.path):]
+			node = n.child
+		case *branchNode:
+			if len(path) == 0 {
+				return n.value, n.value != nil
+			}
+			node = n.children[path[0]]
+			path = path[1:]
+		}
+	}
+}
+
+// Put sets value for the key, putting an empty value deletes the key
+func (t *Trie) Put(key, value []byte) {
+	if len(value) == 0 {
+		t.Delete(key)
+		return
+	}
+	t.root = trieInsert(t.root, keyToNibbles(key), bytes.Clone(value))
+}
+
+func (t *Trie) Delete(key []byte) {
+	t.root = trieDelete(t.root, keyToNibbles(key))
+}
+
+// Iterate calls fn for keys having the prefix which aren't less than the start key in ascending order,
+// the iteration stops when fn returns false
+func (t *Trie) Iterate(prefix, start []byte, fn func(key, value []byte) bool) {
+	trieIterate(t.root, nil, keyToNibbles(prefix), keyToNibbles(start), fn)
+}
+
+// Prove returns encoded nodes on the path from the root to the key.
+// The proof shows either presence or absence of the key.
+func (t *Trie) Prove(key []byte) [][]byte {
+	var proof [][]byte
+	path := keyToNibbles(key)
+	node := t.root
+	for node != nil {
+		proof = append(proof, node.encode())
+		switch n := node.(type) {
+		case *leafNode:
+			return proof
+		case *extensionNode:
+			if !bytes.HasPrefix(path, n.path) {
+				return proof
+			}
+			path = path[len(n.path):]
+			node = n.child
+		case *branchNode:
+			if len(path) == 0 {
+				return proof
+			}
+			node = n.children[path[0]]
+			path = path[1:]
+		}
+	}
+	return proof
+}
+
+// VerifyProof checks the proof produced by Trie.Prove against the root hash.
+// It returns the value of the key or nil if the proof shows that the key is absent.
+func VerifyProof(root types.Hash, key []byte, proof [][]byte) ([]byte, error) {
+	if root.IsZero() {
+		if len(proof) != 0 {
+			return nil, fmt.Errorf("VerifyProof: proof for empty trie must be empty")
+		}
+		return nil, nil
+	}
+
+	path := keyToNibbles(key)
+	want := root
+	for i, enc := range proof {
+		if hashTrieNode(enc) != want {
+			return nil, fmt.Errorf("VerifyProof: node (%d) has invalid hash", i)
+		}
+
+		node, err := decodeProofNode(enc)
+		if err != nil {
+			return nil, err
+		}
+
+		last := i == len(proof)-1
+		switch n := node.(type) {
+		case *proofLeaf:
+			if !last {
+				return nil, fmt.Errorf("VerifyProof: leaf node must be the last one")
+			}
+			if !bytes.Equal(n.path, path) {
+				return nil, nil
+			}
+			return n.value, nil
+		case *proofExtension:
+			if !bytes.HasPrefix(path, n.path) {
+				if !last {
+					return nil, fmt.Errorf("VerifyProof: proof has redundant nodes")
+				}
+				return nil, nil
+			}
+			path = path[len(n.path):]
+			want = n.child
+		case *proofBranch:
+			if len(path) == 0 {
+				if !last {
+					return nil, fmt.Errorf("VerifyProof: proof has redundant nodes")
+				}
+				return n.value, nil
+			}
+			want = n.children[path[0]]
+			path = path[1:]
+			if want.IsZero() {
+				if !last {
+					return nil, fmt.Errorf("VerifyProof: proof has redundant nodes")
+				}
+				return nil, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("VerifyProof: proof is incomplete")
+}
+
+// trieIterate visits keys of the node at the path in ascending order,
+// subtrees which can't contain matching keys are skipped. It returns false if the iteration was stopped.
+func trieIterate(node trieNode, path, prefix, start []byte, fn func(key, value []byte) bool) bool {
+	if node == nil {
+		return true
+	}
+	if n := min(len(path), len(prefix)); !bytes.Equal(path[:n], prefix[:n]) {
+		return true
+	}
+	if n := min(len(path), len(start)); bytes.Compare(path[:n], start[:n]) < 0 {
+		return true
+	}
+
+	visit := func(path, value []byte) bool {
+		if !bytes.HasPrefix(path, prefix) || bytes.Compare(path, start) < 0 {
+			return true
+		}
+		return fn(nibblesToKey(path), value)
+	}
+
+	switch n := node.(type) {
+	case *leafNode:
+		return visit(concatPaths(path, n.path), n.value)
+	case *extensionNode:
+		return trieIterate(n.child, concatPaths(path, n.path), prefix, start, fn)
+	case *branchNode:
+		if n.value != nil && !visit(path, n.value) {
+			return false
+		}
+		for i, child := range n.children {
+			if !trieIterate(child, concatPaths(path, []byte{byte(i)}), prefix, start, fn) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func trieInsert(node trieNode, path, value []byte) trieNode {
+	switch n := node.(type) {
+	case nil:
+		return newLeafNode(path, value)
+	case *leafNode:
+		if bytes.Equal(n.path, path) {
+			return newLeafNode(path, value)
+		}
+		prefix := commonPrefixLength(n.path, path)
+		var children [16]trieNode
+		var branchValue []byte
+		if rest := n.path[prefix:]; len(rest) == 0 {
+			branchValue = n.value
+		} else {
+			children[rest[0]] = newLeafNode(rest[1:], n.value)
+		}
+		if rest := path[prefix:]; len(rest) == 0 {
+			branchValue = value
+		} else {
+			children[rest[0]] = newLeafNode(rest[1:], value)
+		}
+		return withExtension(path[:prefix], newBranchNode(children, branchValue))
+	case *extensionNode:
+		prefix := commonPrefixLength(n.path, path)
+		if prefix == len(n.path) {
+			return newExtensionNode(n.path, trieInsert(n.child, path[prefix:], value))
+		}
+		var children [16]trieNode
+		rest := n.path[prefix:]
+		if len(rest) == 1 {
+			children[rest[0]] = n.child
+		} else {
+			children[rest[0]] = newExtensionNode(rest[1:], n.child)
+		}
+		branch := trieInsert(newBranchNode(children, nil), path[prefix:], value)
+		return withExtension(path[:prefix], branch)
+	case *branchNode:
+		if len(path) == 0 {
+			return newBranchNode(n.children, value)
+		}
+		children := n.children
+		children[path[0]] = trieInsert(children[path[0]], path[1:], value)
+		return newBranchNode(children, n.value)
+	}
+	panic(fmt.Sprintf("trieInsert: unknown node type %T", node))
+}
+
+func trieDelete(node trieNode, path []byte) trieNode {
+	switch n := node.(type) {
+	case nil:
+		return nil
+	case *leafNode:
+		if bytes.Equal(n.path, path) {
+			return nil
+		}
+		return n
+	case *extensionNode:
+		if !bytes.HasPrefix(path, n.path) {
+			return n
+		}
+		child := trieDelete(n.child, path[len(n.path):])
+		if child == n.child {
+			return n
+		}
+		return joinPath(n.path, child)
+	case *branchNode:
+		if len(path) == 0 {
+			if n.value == nil {
+				return n
+			}
+			return normalizeBranch(n.children, nil)
+		}
+		child := trieDelete(n.children[path[0]], path[1:])
+		if child == n.children[path[0]] {
+			return n
+		}
+		children := n.children
+		children[path[0]] = child
+		return normalizeBranch(children, n.value)
+	}
+	panic(fmt.Sprintf("trieDelete: unknown node type %T", node))
+}
+
+// normalizeBranch collapses a branch which is left with a single child or only a value
+func normalizeBranch(children [16]trieNode, value []byte) trieNode {
+	index := -1
+	for i, child := range children {
+		if child == nil {
+			continue
+		}
+		if index != -1 {
+			return newBranchNode(children, value)
+		}
+		index = i
+	}
+
+	switch {
+	case index == -1 && value == nil:
+		return nil
+	case index == -1:
+		return newLeafNode(nil, value)
+	case value != nil:
+		return newBranchNode(children, value)
+	}
+	return joinPath([]byte{byte(index)}, children[index])
+}
+
+// joinPath prepends the path to the node merging consecutive path segments
+func joinPath(path []byte, node trieNode) trieNode {
+	switch n := node.(type) {
+	case nil:
+		return nil
+	case *leafNode:
+		return newLeafNode(concatPaths(path, n.path), n.value)
+	case *extensionNode:
+		return newExtensionNode(concatPaths(path, n.path), n.child)
+	}
+	return newExtensionNode(path, node)
+}
+
+func withExtension(path []byte, node trieNode) trieNode {
+	if len(path) == 0 {
+		return node
+	}
+	return newExtensionNode(bytes.Clone(path), node)
+}
+
+func concatPaths(a, b []byte) []byte {
+	path := make([]byte, 0, len(a)+len(b))
+	path = append(path, a...)
+	return append(path, b...)
+}
+
+func commonPrefixLength(a, b []byte) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+// keyToNibbles splits every byte of the key into two 4-bit halves
+func keyToNibbles(key []byte) []byte {
+	nibbles := make([]byte, 0, len(key)*2)
+	for _, b := range key {
+		nibbles = append(nibbles, b>>4, b&0x0f)
+	}
+	return nibbles
+}
+
+// nibblesToKey joins pairs of nibbles into bytes
+func nibblesToKey(nibbles []byte) []byte {
+	key := make([]byte, len(nibbles)/2)
+	for i := range key {
+		key[i] = nibbles[2*i]<<4 | nibbles[2*i+1]
+	}
+	return key
+}
+
+// proof nodes reference children by hashes only
+type proofLeaf struct {
+	path  []byte
+	value []byte
+}
+
+type proofExtension struct {
+	path  []byte
+	child types.Hash
+}
+
+type proofBranch struct {
+	children [16]types.Hash
+	value    []byte
+}
+
+func decodeProofNode(enc []byte) (any, error) {
+	buf := bytes.NewReader(enc)
+	r := NewBinaryReader(buf)
+	r.ReadVersion()
+	kind := r.ReadUint8()
+	if err := r.Err(); err != nil {
+		return nil, fmt.Errorf("decodeProofNode: %w", err)
+	}
+
+	var node any
+	switch kind {
+	case trieNodeLeaf:
+		node = &proofLeaf{path: r.ReadBytes(), value: r.ReadBytes()}
+	case trieNodeExtension:
+		node = &proofExtension{path: r.ReadBytes(), child: r.readHash()}
+	case trieNodeBranch:
+		n := &proofBranch{}
+		for i := range n.children {
+			n.children[i] = r.readHash()
+		}
+		n.value = r.ReadBytes()
+		node = n
+	default:
+		return nil, fmt.Errorf("decodeProofNode: unknown node type (%d)", kind)
+	}
+	if err := r.Err(); err != nil {
+		return nil, fmt.Errorf("decodeProofNode: %w", err)
+	}
+	if buf.Len() != 0 {
+		return nil, fmt.Errorf("decodeProofNode: node has trailing bytes")
+	}
+	return node, nil
+}
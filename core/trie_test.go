@@ -0,0 +1,181 @@
+package core
+
+import (
+	"blockchain/types"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func TestTrie_PutGetDelete(t *testing.T) {
+	trie := NewTrie()
+	assert.True(t, trie.Hash().IsZero())
+
+	trie.Put([]byte("dog"), []byte("puppy"))
+	trie.Put([]byte("do"), []byte("verb"))
+	trie.Put([]byte("doge"), []byte("coin"))
+	trie.Put([]byte("horse"), []byte("stallion"))
+
+	for k, v := range map[string]string{"dog": "puppy", "do": "verb", "doge": "coin", "horse": "stallion"} {
+		value, ok := trie.Get([]byte(k))
+		assert.True(t, ok)
+		assert.Equal(t, v, string(value))
+	}
+	_, ok := trie.Get([]byte("d"))
+	assert.False(t, ok)
+	_, ok = trie.Get([]byte("dogs"))
+	assert.False(t, ok)
+
+	trie.Put([]byte("dog"), []byte("hound"))
+	value, _ := trie.Get([]byte("dog"))
+	assert.Equal(t, "hound", string(value))
+
+	for _, k := range []string{"dog", "do", "doge", "horse"} {
+		trie.Delete([]byte(k))
+		_, ok = trie.Get([]byte(k))
+		assert.False(t, ok)
+	}
+	assert.True(t, trie.Hash().IsZero())
+}
+
+func TestTrie_HashIsOrderIndependent(t *testing.T) {
+	keys := make([][]byte, 200)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprintf("key-%d", rand.Intn(1000)))
+	}
+
+	a := NewTrie()
+	for _, k := range keys {
+		a.Put(k, k)
+	}
+
+	b := NewTrie()
+	for _, i := range rand.Perm(len(keys)) {
+		b.Put(keys[i], keys[i])
+	}
+	assert.Equal(t, a.Hash(), b.Hash())
+
+	// deleting a key restores the previous root
+	root := a.Hash()
+	a.Put([]byte("extra"), []byte("value"))
+	assert.NotEqual(t, root, a.Hash())
+	a.Delete([]byte("extra"))
+	assert.Equal(t, root, a.Hash())
+}
+
+func TestTrie_Copy(t *testing.T) {
+	trie := NewTrie()
+	trie.Put([]byte("a"), []byte("1"))
+	root := trie.Hash()
+
+	trieCopy := trie.Copy()
+	trieCopy.Put([]byte("a"), []byte("2"))
+	trieCopy.Put([]byte("b"), []byte("3"))
+
+	assert.Equal(t, root, trie.Hash())
+	value, _ := trie.Get([]byte("a"))
+	assert.Equal(t, "1", string(value))
+	_, ok := trie.Get([]byte("b"))
+	assert.False(t, ok)
+}
+
+func TestTrie_Proof(t *testing.T) {
+	trie := NewTrie()
+	for i := 0; i < 100; i++ {
+		trie.Put([]byte(fmt.Sprintf("key-%d", i)), []byte(fmt.Sprintf("value-%d", i)))
+	}
+	root := trie.Hash()
+
+	for i := 0; i < 100; i++ {
+		key := []byte(fmt.Sprintf("key-%d", i))
+		value, err := VerifyProof(root, key, trie.Prove(key))
+		assert.Nil(t, err)
+		assert.Equal(t, fmt.Sprintf("value-%d", i), string(value))
+	}
+
+	for _, k := range []string{"key-100", "key-", "other"} {
+		value, err := VerifyProof(root, []byte(k), trie.Prove([]byte(k)))
+		assert.Nil(t, err)
+		assert.Nil(t, value)
+	}
+
+	key := []byte("key-42")
+	proof := trie.Prove(key)
+	_, err := VerifyProof(types.Hash{1}, key, proof)
+	assert.NotNil(t, err)
+
+	proof[len(proof)-1] = newLeafNode(nil, []byte("forged")).encode()
+	_, err = VerifyProof(root, key, proof)
+	assert.NotNil(t, err)
+
+	_, err = VerifyProof(root, key, trie.Prove(key)[:1])
+	assert.NotNil(t, err)
+}
+
+func TestTrie_Iterate(t *testing.T) {
+	trie := NewTrie()
+	for _, k := range []string{"b", "a", "ab", "abc", "abd", "b1", "", "c"} {
+		trie.Put([]byte(k), []byte("value-"+k))
+	}
+
+	iterate := func(prefix, start string, limit int) []string {
+		var result []string
+		trie.Iterate([]byte(prefix), []byte(start), func(key, value []byte) bool {
+			assert.Equal(t, "value-"+string(key), string(value))
+			result = append(result, string(key))
+			return len(result) < limit
+		})
+		return result
+	}
+
+	assert.Equal(t, []string{"", "a", "ab", "abc", "abd", "b", "b1", "c"}, iterate("", "", 100))
+	assert.Equal(t, []string{"ab", "abc", "abd"}, iterate("ab", "", 100))
+	assert.Equal(t, []string{"abd", "b", "b1"}, iterate("", "abcd", 3))
+	assert.Equal(t, []string{"b1"}, iterate("b", "b0", 100))
+	assert.Empty(t, iterate("d", "", 100))
+	assert.Empty(t, iterate("a", "b", 100))
+
+	// keys are visited in sorted order
+	trie = NewTrie()
+	var expected []string
+	for i := 0; i < 200; i++ {
+		key := make([]byte, 1+rand.Intn(4))
+		rand.Read(key)
+		trie.Put(key, []byte{1})
+		expected = append(expected, string(key))
+	}
+	slices.Sort(expected)
+	expected = slices.Compact(expected)
+	var visited []string
+	trie.Iterate(nil, nil, func(key, value []byte) bool {
+		visited = append(visited, string(key))
+		return true
+	})
+	assert.Equal(t, expected, visited)
+}
+
+func TestTrie_Snapshot(t *testing.T) {
+	trie := NewTrie()
+	trie.Put([]byte("a"), []byte("1"))
+	root := trie.Hash()
+
+	first := trie.Snapshot()
+	trie.Put([]byte("b"), []byte("2"))
+	second := trie.Snapshot()
+	trie.Delete([]byte("a"))
+
+	trie.RevertToSnapshot(second)
+	value, ok := trie.Get([]byte("a"))
+	assert.True(t, ok)
+	assert.Equal(t, "1", string(value))
+
+	trie.RevertToSnapshot(first)
+	assert.Equal(t, root, trie.Hash())
+	_, ok = trie.Get([]byte("b"))
+	assert.False(t, ok)
+
+	// snapshots taken after the reverted one are invalid
+	assert.Panics(t, func() { trie.RevertToSnapshot(second) })
+}
@@ -0,0 +1,330 @@
+package core
+
+import (
+	"blockchain/crypto"
+	"blockchain/types"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+var (
+	ErrInvalidTransaction = errors.New("invalid transaction")
+	ErrMintNotAuthorized  = errors.New("mint isn't authorized by the collection owner")
+	ErrNFTExists          = errors.New("NFT already exists")
+	ErrNotNFTOwner        = errors.New("sender isn't the NFT owner")
+)
+
+// TxType identifies the payload of the transaction, it's the first encoded field of the transaction
+type TxType byte
+
+const (
+	TxTypeTransfer TxType = iota + 1
+	TxTypeDeploy
+	TxTypeCall
+	TxTypeCreateCollection
+	TxTypeMint
+	TxTypeTransferNFT
+	TxTypeBurnNFT
+)
+
+var txTypeNames = map[TxType]string{
+	TxTypeTransfer:         "transfer",
+	TxTypeDeploy:           "deploy",
+	TxTypeCall:             "call",
+	TxTypeCreateCollection: "create_collection",
+	TxTypeMint:             "mint",
+	TxTypeTransferNFT:      "transfer_nft",
+	TxTypeBurnNFT:          "burn_nft",
+}
+
+func (t TxType) String() string {
+	if name, ok := txTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown(%d)", byte(t))
+}
+
+// TxPayload holds the fields specific to the transaction type
+type TxPayload interface {
+	Type() TxType
+	// Validate rejects fields which are invalid or ambiguous for the transaction type
+	Validate() error
+	encode(w *BinaryWriter)
+	decode(r *BinaryReader)
+}
+
+// txPayloads creates empty payloads of the registered transaction types for decoding
+var txPayloads = map[TxType]func() TxPayload{
+	TxTypeTransfer:         func() TxPayload { return new(Transfer) },
+	TxTypeDeploy:           func() TxPayload { return new(Deploy) },
+	TxTypeCall:             func() TxPayload { return new(Call) },
+	TxTypeCreateCollection: func() TxPayload { return new(CreateCollection) },
+	TxTypeMint:             func() TxPayload { return new(Mint) },
+	TxTypeTransferNFT:      func() TxPayload { return new(TransferNFT) },
+	TxTypeBurnNFT:          func() TxPayload { return new(BurnNFT) },
+}
+
+// Transfer sends the value to the account, it can't be sent to a contract
+type Transfer struct {
+	To    types.Address
+	Value *big.Int
+}
+
+func (p *Transfer) Type() TxType {
+	return TxTypeTransfer
+}
+
+func (p *Transfer) Validate() error {
+	if p.To.IsZero() {
+		return fmt.Errorf("%w: transfer has no recipient", ErrInvalidTransaction)
+	}
+	if p.Value == nil || p.Value.Sign() <= 0 {
+		return fmt.Errorf("%w: transfer value must be positive", ErrInvalidTransaction)
+	}
+	return nil
+}
+
+func (p *Transfer) encode(w *BinaryWriter) {
+	w.WriteFixed(p.To[:])
+	w.WriteBigInt(p.Value)
+}
+
+func (p *Transfer) decode(r *BinaryReader) {
+	r.ReadFixed(p.To[:])
+	p.Value = r.ReadBigInt()
+}
+
+// Deploy creates a contract with the code at ContractAddress of the sender and the transaction nonce,
+// the value is sent to the contract
+type Deploy struct {
+	Code  []byte
+	Value *big.Int
+}
+
+func (p *Deploy) Type() TxType {
+	return TxTypeDeploy
+}
+
+func (p *Deploy) Validate() error {
+	if len(p.Code) == 0 {
+		return fmt.Errorf("%w: deployment has no code", ErrInvalidTransaction)
+	}
+	return validateValue(p.Value)
+}
+
+func (p *Deploy) encode(w *BinaryWriter) {
+	w.WriteBytes(p.Code)
+	w.WriteBigInt(p.Value)
+}
+
+func (p *Deploy) decode(r *BinaryReader) {
+	p.Code = r.ReadBytes()
+	p.Value = r.ReadBigInt()
+}
+
+// Call executes the contract with the input, the value is sent to the contract before the execution
+type Call struct {
+	Contract types.Address
+	Input    []byte
+	Value    *big.Int
+}
+
+func (p *Call) Type() TxType {
+	return TxTypeCall
+}
+
+func (p *Call) Validate() error {
+	if p.Contract.IsZero() {
+		return fmt.Errorf("%w: call has no contract", ErrInvalidTransaction)
+	}
+	return validateValue(p.Value)
+}
+
+func (p *Call) encode(w *BinaryWriter) {
+	w.WriteFixed(p.Contract[:])
+	w.WriteBytes(p.Input)
+	w.WriteBigInt(p.Value)
+}
+
+func (p *Call) decode(r *BinaryReader) {
+	r.ReadFixed(p.Contract[:])
+	p.Input = r.ReadBytes()
+	p.Value = r.ReadBigInt()
+}
+
+// CreateCollection creates an NFT collection identified by the transaction hash
+type CreateCollection struct {
+	MetaData []byte
+	Fee      int64
+}
+
+func (p *CreateCollection) Type() TxType {
+	return TxTypeCreateCollection
+}
+
+func (p *CreateCollection) Validate() error {
+	return validateFee(p.Fee)
+}
+
+func (p *CreateCollection) encode(w *BinaryWriter) {
+	w.WriteBytes(p.MetaData)
+	w.WriteInt64(p.Fee)
+}
+
+func (p *CreateCollection) decode(r *BinaryReader) {
+	p.MetaData = r.ReadBytes()
+	p.Fee = r.ReadInt64()
+}
+
+// Mint creates the NFT in the collection, the signature of the collection owner
+// allows the sender of the transaction to mint it
+type Mint struct {
+	MetaData        []byte
+	Fee             int64
+	NFT             types.Hash
+	Collection      types.Hash
+	CollectionOwner crypto.PublicKey
+	Signature       crypto.Signature
+}
+
+func (p *Mint) Type() TxType {
+	return TxTypeMint
+}
+
+func (p *Mint) Validate() error {
+	if p.NFT.IsZero() {
+		return fmt.Errorf("%w: mint has no NFT hash", ErrInvalidTransaction)
+	}
+	if p.Collection.IsZero() {
+		return fmt.Errorf("%w: mint has no collection", ErrInvalidTransaction)
+	}
+	if !p.CollectionOwner.Valid() || !p.Signature.Valid() {
+		return fmt.Errorf("%w: mint isn't signed by the collection owner", ErrInvalidTransaction)
+	}
+	return validateFee(p.Fee)
+}
+
+// SigningHash returns the hash the collection owner signs to allow the minter to mint the NFT on the chain
+func (p *Mint) SigningHash(chainID uint64, minter types.Address) types.Hash {
+	w := NewBinaryWriter()
+	w.WriteString(mintDomain)
+	w.WriteVersion()
+	w.WriteUint64(chainID)
+	w.WriteFixed(minter[:])
+	w.WriteFixed(p.Collection[:])
+	w.WriteFixed(p.NFT[:])
+	w.WriteBytes(p.MetaData)
+	w.WriteInt64(p.Fee)
+	return sha256.Sum256(w.Bytes())
+}
+
+// Sign allows the minter to mint the NFT, the key must be the key of the collection owner
+func (p *Mint) Sign(priv *crypto.PrivateKey, chainID uint64, minter types.Address) error {
+	hash := p.SigningHash(chainID, minter)
+	sig, err := priv.Sign(hash[:])
+	if err != nil {
+		return err
+	}
+	p.CollectionOwner = priv.PublicKey()
+	p.Signature = *sig
+	return nil
+}
+
+// Verify checks the signature of the collection owner for the minter on the chain
+func (p *Mint) Verify(chainID uint64, minter types.Address) error {
+	hash := p.SigningHash(chainID, minter)
+	if !p.Signature.Verify(p.CollectionOwner, hash[:]) {
+		return fmt.Errorf("%w: invalid signature of NFT (%s)", ErrMintNotAuthorized, p.NFT)
+	}
+	return nil
+}
+
+func (p *Mint) encode(w *BinaryWriter) {
+	w.WriteBytes(p.MetaData)
+	w.WriteInt64(p.Fee)
+	w.WriteFixed(p.NFT[:])
+	w.WriteFixed(p.Collection[:])
+	w.WriteBytes(p.CollectionOwner)
+	w.WriteBigInt(p.Signature.R)
+	w.WriteBigInt(p.Signature.S)
+}
+
+func (p *Mint) decode(r *BinaryReader) {
+	p.MetaData = r.ReadBytes()
+	p.Fee = r.ReadInt64()
+	r.ReadFixed(p.NFT[:])
+	r.ReadFixed(p.Collection[:])
+	p.CollectionOwner = r.ReadBytes()
+	p.Signature.R = r.ReadBigInt()
+	p.Signature.S = r.ReadBigInt()
+}
+
+// TransferNFT sends the NFT owned by the sender to the new owner
+type TransferNFT struct {
+	NFT types.Hash
+	To  types.Address
+}
+
+func (p *TransferNFT) Type() TxType {
+	return TxTypeTransferNFT
+}
+
+func (p *TransferNFT) Validate() error {
+	if p.NFT.IsZero() {
+		return fmt.Errorf("%w: NFT transfer has no NFT hash", ErrInvalidTransaction)
+	}
+	if p.To.IsZero() {
+		return fmt.Errorf("%w: NFT transfer has no recipient", ErrInvalidTransaction)
+	}
+	return nil
+}
+
+func (p *TransferNFT) encode(w *BinaryWriter) {
+	w.WriteFixed(p.NFT[:])
+	w.WriteFixed(p.To[:])
+}
+
+func (p *TransferNFT) decode(r *BinaryReader) {
+	r.ReadFixed(p.NFT[:])
+	r.ReadFixed(p.To[:])
+}
+
+// BurnNFT destroys the NFT owned by the sender, the hash of the burned NFT can't be minted again
+type BurnNFT struct {
+	NFT types.Hash
+}
+
+func (p *BurnNFT) Type() TxType {
+	return TxTypeBurnNFT
+}
+
+func (p *BurnNFT) Validate() error {
+	if p.NFT.IsZero() {
+		return fmt.Errorf("%w: NFT burn has no NFT hash", ErrInvalidTransaction)
+	}
+	return nil
+}
+
+func (p *BurnNFT) encode(w *BinaryWriter) {
+	w.WriteFixed(p.NFT[:])
+}
+
+func (p *BurnNFT) decode(r *BinaryReader) {
+	r.ReadFixed(p.NFT[:])
+}
+
+func validateValue(value *big.Int) error {
+	if value != nil && value.Sign() < 0 {
+		return fmt.Errorf("%w: negative value (%s)", ErrInvalidTransaction, value)
+	}
+	return nil
+}
+
+func validateFee(fee int64) error {
+	if fee < 0 {
+		return fmt.Errorf("%w: negative fee (%d)", ErrInvalidTransaction, fee)
+	}
+	return nil
+}
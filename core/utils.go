@@ -1,7 +1,8 @@
 package core
 
 import (
-	"encoding/binary"
+	"blockchain/types"
+	"math/big"
 )
 
 type Instr struct {
@@ -15,63 +16,30 @@ func (i *Instr) Bytes() []byte {
 
 // Add composes "add two integers and push result to the stack" instruction
 func (i *Instr) Add(a, b int) *Instr {
-	ins := []byte{
-		byte(a),
-		byte(InstrPushInt),
-		byte(b),
-		byte(InstrPushInt),
-		byte(InstrAdd),
-	}
-	i.data = append(i.data, ins...)
-	return i
+	return i.Int(a).Int(b).op(InstrAdd)
 }
 
 // Sub composes "subtract two integers and push result to the stack" instruction
 func (i *Instr) Sub(a, b int) *Instr {
-	ins := []byte{
-		byte(a),
-		byte(InstrPushInt),
-		byte(b),
-		byte(InstrPushInt),
-		byte(InstrSub),
-	}
-	i.data = append(i.data, ins...)
-	return i
+	return i.Int(a).Int(b).op(InstrSub)
 }
 
 // Mul composes "multiply two integers and push result to the stack" instruction
 func (i *Instr) Mul(a, b int) *Instr {
-	ins := []byte{
-		byte(a),
-		byte(InstrPushInt),
-		byte(b),
-		byte(InstrPushInt),
-		byte(InstrMul),
-	}
-	i.data = append(i.data, ins...)
-	return i
+	return i.Int(a).Int(b).op(InstrMul)
 }
 
 // Div composes "divide two integers and push result to the stack" instruction
 func (i *Instr) Div(a, b int) *Instr {
-	ins := []byte{
-		byte(a),
-		byte(InstrPushInt),
-		byte(b),
-		byte(InstrPushInt),
-		byte(InstrDiv),
-	}
-	i.data = append(i.data, ins...)
-	return i
+	return i.Int(a).Int(b).op(InstrDiv)
 }
 
 // String composes "push string to the stack" instruction
 func (i *Instr) String(str string) *Instr {
 	for _, char := range []byte(str) {
-		i.data = append(i.data, char, byte(InstrPushByte))
+		i.data = append(i.data, byte(InstrPushByte), char)
 	}
-	i.data = append(i.data, byte(len(str)), byte(InstrPushInt), byte(InstrPack))
-	return i
+	return i.Int(len(str)).op(InstrPack)
 }
 
 // Store composes "store key-value pair to the state" instruction
@@ -87,13 +55,185 @@ func (i *Instr) Get(str string) *Instr {
 	return i
 }
 
-func serializeInt64(value int64) []byte {
-	buf := make([]byte, 8)
-	binary.LittleEndian.PutUint64(buf, uint64(value))
-	return buf
+// Len returns the length of composed instructions,
+// which is the position of the next instruction
+func (i *Instr) Len() int {
+	return len(i.data)
+}
+
+// Int composes "push integer to the stack" instruction
+func (i *Instr) Int(v int) *Instr {
+	return i.BigInt(big.NewInt(int64(v)))
+}
+
+// BigInt composes "push integer to the stack" instruction using the shortest push instruction,
+// negative integers are pushed in two's complement form
+func (i *Instr) BigInt(v *big.Int) *Instr {
+	b := toWord(new(big.Int).Set(v)).Bytes()
+	if len(b) == 0 {
+		b = []byte{0}
+	}
+	i.data = append(i.data, byte(InstrPush1)+byte(len(b)-1))
+	i.data = append(i.data, b...)
+	return i
+}
+
+// Address composes "push address to the stack" instruction
+func (i *Instr) Address(addr types.Address) *Instr {
+	return i.BigInt(addressToInt(addr))
+}
+
+// op composes an instruction taking its operands from the stack
+func (i *Instr) op(instr Instruction) *Instr {
+	i.data = append(i.data, byte(instr))
+	return i
+}
+
+// Eq composes "push 1 if two top items are equal, 0 otherwise" instruction
+func (i *Instr) Eq() *Instr {
+	return i.op(InstrEq)
+}
+
+// Lt composes "push 1 if the second item is less than the top one, 0 otherwise" instruction
+func (i *Instr) Lt() *Instr {
+	return i.op(InstrLt)
+}
+
+// Gt composes "push 1 if the second item is greater than the top one, 0 otherwise" instruction
+func (i *Instr) Gt() *Instr {
+	return i.op(InstrGt)
+}
+
+// And composes "push 1 if both top items are not zero, 0 otherwise" instruction
+func (i *Instr) And() *Instr {
+	return i.op(InstrAnd)
+}
+
+// Or composes "push 1 if any of two top items is not zero, 0 otherwise" instruction
+func (i *Instr) Or() *Instr {
+	return i.op(InstrOr)
+}
+
+// Not composes "push 1 if the top item is zero, 0 otherwise" instruction
+func (i *Instr) Not() *Instr {
+	return i.op(InstrNot)
+}
+
+// JumpDest composes "mark position as a jump destination" instruction,
+// the position is given by Len before the call
+func (i *Instr) JumpDest() *Instr {
+	return i.op(InstrJumpDest)
+}
+
+// Jump composes "continue execution from the jump destination" instruction
+func (i *Instr) Jump(dest int) *Instr {
+	return i.Int(dest).op(InstrJump)
+}
+
+// JumpI composes "continue execution from the jump destination if the top item is not zero" instruction
+func (i *Instr) JumpI(dest int) *Instr {
+	return i.Int(dest).op(InstrJumpI)
+}
+
+// Dup composes "push copy of the nth item from the top of the stack" instruction
+func (i *Instr) Dup(n int) *Instr {
+	return i.Int(n).op(InstrDup)
+}
+
+// Swap composes "swap the top item with the nth item below it" instruction
+func (i *Instr) Swap(n int) *Instr {
+	return i.Int(n).op(InstrSwap)
+}
+
+// Pop composes "remove the top item from the stack" instruction
+func (i *Instr) Pop() *Instr {
+	return i.op(InstrPop)
+}
+
+// Return composes "stop execution returning the top item" instruction
+func (i *Instr) Return() *Instr {
+	return i.op(InstrReturn)
+}
+
+// Revert composes "stop execution reverting state changes and returning the top item" instruction
+func (i *Instr) Revert() *Instr {
+	return i.op(InstrRevert)
+}
+
+// Caller composes "push address of the caller to the stack" instruction
+func (i *Instr) Caller() *Instr {
+	return i.op(InstrCaller)
+}
+
+// CallValue composes "push amount sent with the call to the stack" instruction
+func (i *Instr) CallValue() *Instr {
+	return i.op(InstrCallValue)
+}
+
+// Balance composes "push balance of the address on the top of the stack" instruction
+func (i *Instr) Balance() *Instr {
+	return i.op(InstrBalance)
+}
+
+// SelfBalance composes "push balance of the contract to the stack" instruction
+func (i *Instr) SelfBalance() *Instr {
+	return i.op(InstrSelfBalance)
+}
+
+// BlockHeight composes "push height of the current block to the stack" instruction
+func (i *Instr) BlockHeight() *Instr {
+	return i.op(InstrBlockHeight)
+}
+
+// Timestamp composes "push timestamp of the current block to the stack" instruction
+func (i *Instr) Timestamp() *Instr {
+	return i.op(InstrTimestamp)
+}
+
+// Transfer composes "transfer the amount on the top of the stack from the contract
+// to the address below it" instruction
+func (i *Instr) Transfer() *Instr {
+	return i.op(InstrTransfer)
+}
+
+// Call composes "call the contract with the gas on the top of the stack,
+// the address and the value below it and push 1 if the call succeeded, 0 otherwise" instruction
+func (i *Instr) Call() *Instr {
+	return i.op(InstrCall)
+}
+
+// ReturnData composes "push data returned by the last call to the stack" instruction
+func (i *Instr) ReturnData() *Instr {
+	return i.op(InstrReturnData)
+}
+
+// Log composes "emit log with the given number of topics from the top of the stack
+// and data below them" instruction
+func (i *Instr) Log(topics int) *Instr {
+	return i.Int(topics).op(InstrLog)
+}
+
+// Topic composes "push topic to the stack" instruction
+func (i *Instr) Topic(topic types.Hash) *Instr {
+	return i.BigInt(new(big.Int).SetBytes(topic[:]))
+}
+
+// SLoad composes "push storage word by the key on the top of the stack" instruction
+func (i *Instr) SLoad() *Instr {
+	return i.op(InstrSLoad)
+}
+
+// SStore composes "store the word below the top of the stack by the key on the top" instruction
+func (i *Instr) SStore() *Instr {
+	return i.op(InstrSStore)
+}
+
+// Input composes "push the nth word of the call input to the stack" instruction
+func (i *Instr) Input() *Instr {
+	return i.op(InstrInput)
 }
 
-func deserializeInt64(b []byte) int64 {
-	value := binary.LittleEndian.Uint64(b)
-	return int64(value)
+// Hash composes "push sha256 of the two top words, the word below the top goes first" instruction
+func (i *Instr) Hash() *Instr {
+	return i.op(InstrHash)
 }
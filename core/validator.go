@@ -5,10 +5,23 @@ import (
 	"fmt"
 )
 
-var ErrBlockAlreadyExists = errors.New("block already exists")
+var (
+	ErrBlockAlreadyExists = errors.New("block already exists")
+	ErrNonceTooLow        = errors.New("nonce too low")
+	ErrNonceTooHigh       = errors.New("nonce too high")
+	ErrInvalidChainID     = errors.New("invalid chain id")
+)
 
+// Validator checks the block can be appended to the chain
+// and returns the result of its execution which is committed along with the block
 type Validator interface {
-	ValidateBlock(*Block) error
+	ValidateBlock(*Block) (*BlockExecution, error)
+}
+
+// BlockExecution holds the state and receipts resulting from the execution of the block
+type BlockExecution struct {
+	state    *chainState
+	receipts []*Receipt
 }
 
 type BlockValidator struct {
@@ -21,27 +34,52 @@ func NewBlockValidator(bc *Blockchain) *BlockValidator {
 	}
 }
 
-func (v *BlockValidator) ValidateBlock(block *Block) error {
+func (v *BlockValidator) ValidateBlock(block *Block) (*BlockExecution, error) {
 	if v.bc.HasBlock(block.Height) {
-		return ErrBlockAlreadyExists
+		return nil, ErrBlockAlreadyExists
+	}
+	if block.ChainID != v.bc.ChainID() {
+		return nil, fmt.Errorf("%w: block (%s) has chain id (%d) => expected (%d)",
+			ErrInvalidChainID, block.HeaderHash(HeaderHasher{}), block.ChainID, v.bc.ChainID())
 	}
 	if block.Height != v.bc.Height()+1 {
-		return fmt.Errorf("block (%s) with height (%d) is too high => current height (%d)",
+		return nil, fmt.Errorf("block (%s) with height (%d) is too high => current height (%d)",
 			block.HeaderHash(HeaderHasher{}), block.Height, v.bc.Height())
 	}
 
 	prevBlock, err := v.bc.GetBlock(block.Height - 1)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	prevHeaderHash := HeaderHasher{}.Hash(prevBlock.Header)
 	if prevHeaderHash != block.PrevHeaderHash {
-		return fmt.Errorf("hash of the previous block header is invalid")
+		return nil, fmt.Errorf("hash of the previous block header is invalid")
 	}
 
 	if err = block.Verify(); err != nil {
-		return err
+		return nil, err
+	}
+	for _, tx := range block.Transactions {
+		if err = CheckGasPrice(tx, v.bc.MinGasPrice()); err != nil {
+			return nil, err
+		}
+	}
+
+	state, receipts, err := v.bc.executeBlock(block)
+	if err != nil {
+		return nil, err
+	}
+	if stateRoot := state.root(); stateRoot != block.StateRoot {
+		return nil, fmt.Errorf("block (%s) has invalid state root (%s) => expected (%s)",
+			block.HeaderHash(HeaderHasher{}), block.StateRoot, stateRoot)
+	}
+	if receiptsHash := HashReceipts(receipts); receiptsHash != block.ReceiptsHash {
+		return nil, fmt.Errorf("block (%s) has invalid receipts hash (%s) => expected (%s)",
+			block.HeaderHash(HeaderHasher{}), block.ReceiptsHash, receiptsHash)
+	}
+	if LogsBloom(receipts) != block.LogsBloom {
+		return nil, fmt.Errorf("block (%s) has invalid logs bloom", block.HeaderHash(HeaderHasher{}))
 	}
 
-	return nil
+	return &BlockExecution{state: state, receipts: receipts}, nil
 }
@@ -1,14 +1,18 @@
 package core
 
 import (
-	"slices"
+	"blockchain/types"
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
 )
 
 type Instruction byte
 
 const (
-	InstrPushInt Instruction = iota + 10 // 0-9 are reserved for digits
-	InstrAdd
+	InstrAdd Instruction = iota + 1 // 0 is an invalid instruction
 	InstrSub
 	InstrMul
 	InstrDiv
@@ -16,8 +20,149 @@ const (
 	InstrPack
 	InstrStore
 	InstrGet
+	InstrEq
+	InstrLt
+	InstrGt
+	InstrAnd
+	InstrOr
+	InstrNot
+	InstrJump
+	InstrJumpI
+	InstrJumpDest
+	InstrDup
+	InstrSwap
+	InstrPop
+	InstrReturn
+	InstrRevert
+	InstrCaller
+	InstrCallValue
+	InstrBalance
+	InstrSelfBalance
+	InstrBlockHeight
+	InstrTimestamp
+	InstrTransfer
+	InstrCall
+	InstrReturnData
+	InstrLog
+	InstrSLoad
+	InstrSStore
+	InstrInput
+	InstrHash
 )
 
+// InstrPush1 to InstrPush32 push the big-endian integer of 1 to 32 bytes following the instruction
+const (
+	InstrPush1  Instruction = 0x60
+	InstrPush32 Instruction = InstrPush1 + wordSize - 1
+)
+
+// wordSize is the size of integers in bytes
+const wordSize = 32
+
+// stackSize is the maximum number of items on the VM stack
+const stackSize = 128
+
+// MaxCallDepth is the maximum depth of nested contract calls
+const MaxCallDepth = 64
+
+// MaxLogTopics is the maximum number of topics of a log
+const MaxLogTopics = 4
+
+var (
+	ErrStackUnderflow = errors.New("stack underflow")
+	ErrStackOverflow  = errors.New("stack overflow")
+	ErrDivisionByZero = errors.New("division by zero")
+	ErrInvalidOpcode  = errors.New("invalid opcode")
+	ErrInvalidOperand = errors.New("invalid operand")
+	ErrInvalidJump    = errors.New("invalid jump destination")
+	ErrReverted       = errors.New("execution reverted")
+)
+
+// wordModulus is 2^256, integer arithmetic wraps around it
+var wordModulus = new(big.Int).Lsh(big.NewInt(1), wordSize*8)
+
+// toWord reduces the integer to the range of 256-bit unsigned integers
+func toWord(x *big.Int) *big.Int {
+	if x.Sign() >= 0 && x.Cmp(wordModulus) < 0 {
+		return x
+	}
+	return x.Mod(x, wordModulus)
+}
+
+// wordBytes returns 32-byte big-endian encoding of the integer
+func wordBytes(x *big.Int) []byte {
+	return toWord(new(big.Int).Set(x)).FillBytes(make([]byte, wordSize))
+}
+
+// ImmediateSize returns the number of bytes following the instruction which are its operand
+func (i Instruction) ImmediateSize() int {
+	switch {
+	case i >= InstrPush1 && i <= InstrPush32:
+		return int(i-InstrPush1) + 1
+	case i == InstrPushByte:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// instructionNames are mnemonics of the instructions, push instructions are named by their size
+var instructionNames = map[Instruction]string{
+	InstrAdd:         "ADD",
+	InstrSub:         "SUB",
+	InstrMul:         "MUL",
+	InstrDiv:         "DIV",
+	InstrPushByte:    "PUSHBYTE",
+	InstrPack:        "PACK",
+	InstrStore:       "STORE",
+	InstrGet:         "GET",
+	InstrEq:          "EQ",
+	InstrLt:          "LT",
+	InstrGt:          "GT",
+	InstrAnd:         "AND",
+	InstrOr:          "OR",
+	InstrNot:         "NOT",
+	InstrJump:        "JUMP",
+	InstrJumpI:       "JUMPI",
+	InstrJumpDest:    "JUMPDEST",
+	InstrDup:         "DUP",
+	InstrSwap:        "SWAP",
+	InstrPop:         "POP",
+	InstrReturn:      "RETURN",
+	InstrRevert:      "REVERT",
+	InstrCaller:      "CALLER",
+	InstrCallValue:   "CALLVALUE",
+	InstrBalance:     "BALANCE",
+	InstrSelfBalance: "SELFBALANCE",
+	InstrBlockHeight: "BLOCKHEIGHT",
+	InstrTimestamp:   "TIMESTAMP",
+	InstrTransfer:    "TRANSFER",
+	InstrCall:        "CALL",
+	InstrReturnData:  "RETURNDATA",
+	InstrLog:         "LOG",
+	InstrSLoad:       "SLOAD",
+	InstrSStore:      "SSTORE",
+	InstrInput:       "INPUT",
+	InstrHash:        "HASH",
+}
+
+// IsValid reports whether the VM can execute the instruction
+func (i Instruction) IsValid() bool {
+	_, ok := instructionNames[i]
+	return ok || (i >= InstrPush1 && i <= InstrPush32)
+}
+
+// String returns the mnemonic of the instruction
+func (i Instruction) String() string {
+	if name, ok := instructionNames[i]; ok {
+		return name
+	}
+	if i >= InstrPush1 && i <= InstrPush32 {
+		return fmt.Sprintf("PUSH%d", i.ImmediateSize())
+	}
+	return fmt.Sprintf("INVALID(0x%02x)", byte(i))
+}
+
 type Stack struct {
 	data    []any
 	pointer int
@@ -30,104 +175,671 @@ func NewStack(size int) *Stack {
 	}
 }
 
-func (s *Stack) Push(v any) {
+// Len returns the number of items on the stack
+func (s *Stack) Len() int {
+	return s.pointer + 1
+}
+
+func (s *Stack) Push(v any) error {
+	if s.pointer == len(s.data)-1 {
+		return ErrStackOverflow
+	}
 	s.pointer++
 	s.data[s.pointer] = v
+	return nil
 }
 
-func (s *Stack) Pop() any {
+func (s *Stack) Pop() (any, error) {
+	if s.pointer < 0 {
+		return nil, ErrStackUnderflow
+	}
 	value := s.data[s.pointer]
-	slices.Delete(s.data, s.pointer, s.pointer+1)
+	s.data[s.pointer] = nil
 	s.pointer--
-	return value
+	return value, nil
+}
+
+// Peek returns the nth item from the top of the stack, the top item is the first one
+func (s *Stack) Peek(n int) (any, error) {
+	if n < 1 || n > s.Len() {
+		return nil, ErrStackUnderflow
+	}
+	return s.data[s.pointer-n+1], nil
+}
+
+// Swap swaps the top item of the stack with the nth item below it
+func (s *Stack) Swap(n int) error {
+	if n < 1 || n >= s.Len() {
+		return ErrStackUnderflow
+	}
+	s.data[s.pointer], s.data[s.pointer-n] = s.data[s.pointer-n], s.data[s.pointer]
+	return nil
+}
+
+// Context is the read-only environment of the contract execution
+type Context struct {
+	// Caller is the address which called the contract
+	Caller types.Address
+	// Address is the address of the executed contract
+	Address types.Address
+	// Value is the amount sent to the contract with the call
+	Value       *big.Int
+	BlockHeight uint32
+	Timestamp   int64
+	// Input is the data sent to the contract with the call
+	Input []byte
+}
+
+// ContractStorage is the key-value storage of the executed contract
+type ContractStorage interface {
+	Add(k, v []byte)
+	Get(k []byte) ([]byte, error)
 }
 
 // VM is virtual machine
 type VM struct {
+	ctx           *Context
 	data          []byte
 	pointer       int
 	stack         *Stack
-	contractState *State
+	state         *State
+	contractState ContractStorage
+	accounts      *AccountsState
+	gasLimit      uint64
+	gasUsed       uint64
+	// depth is the number of calls the VM is nested in
+	depth int
+	// returnBuffer holds data returned by the last call
+	returnBuffer []byte
+	// logs emitted by the contract and successful calls it made
+	logs []*Log
+	// jumpDests holds valid jump destinations, computed on the first jump
+	jumpDests  map[int]bool
+	returnData []byte
+	stopped    bool
+	tracer     Tracer
 }
 
-func NewVM(data []byte, contractState *State) *VM {
+// NewVM returns VM executing the code of the contract at ctx.Address,
+// the contract storage and code of called contracts are kept in the state
+func NewVM(ctx *Context, data []byte, state *State, accounts *AccountsState, gasLimit uint64) *VM {
 	return &VM{
+		ctx:           ctx,
 		data:          data,
-		stack:         NewStack(128),
-		contractState: contractState,
+		stack:         NewStack(stackSize),
+		state:         state,
+		contractState: state.ContractStorage(ctx.Address),
+		accounts:      accounts,
+		gasLimit:      gasLimit,
 	}
 }
 
-func (vm *VM) Run() error {
-	for {
-		instr := Instruction(vm.data[vm.pointer])
-		if err := vm.Exec(instr); err != nil {
-			return err
-		}
-		vm.pointer++
-		if vm.pointer > len(vm.data)-1 {
-			break
+// GasUsed returns gas consumed by executed instructions
+func (vm *VM) GasUsed() uint64 {
+	return vm.gasUsed
+}
+
+// ReturnData returns the value passed to the return or revert instruction
+func (vm *VM) ReturnData() []byte {
+	return vm.returnData
+}
+
+// Logs returns logs emitted during the execution
+func (vm *VM) Logs() []*Log {
+	return vm.logs
+}
+
+// useGas consumes gas, all the gas is consumed if there isn't enough of it
+func (vm *VM) useGas(gas uint64) error {
+	if vm.gasLimit-vm.gasUsed < gas {
+		vm.gasUsed = vm.gasLimit
+		return ErrOutOfGas
+	}
+	vm.gasUsed += gas
+	return nil
+}
+
+// validJumpDest reports whether dest is a jump destination instruction
+// and not a part of an immediate operand
+func (vm *VM) validJumpDest(dest int) bool {
+	if vm.jumpDests == nil {
+		vm.jumpDests = make(map[int]bool)
+		for pos := 0; pos < len(vm.data); pos++ {
+			instr := Instruction(vm.data[pos])
+			if instr == InstrJumpDest {
+				vm.jumpDests[pos] = true
+			}
+			pos += instr.ImmediateSize()
 		}
 	}
+	return vm.jumpDests[dest]
+}
+
+// SetTracer sets the tracer notified about every executed instruction
+func (vm *VM) SetTracer(tracer Tracer) {
+	vm.tracer = tracer
+}
+
+// PC returns position of the next instruction
+func (vm *VM) PC() int {
+	return vm.pointer
+}
+
+// Stack returns the VM stack, it must not be modified
+func (vm *VM) Stack() *Stack {
+	return vm.stack
+}
+
+// Done reports whether the execution is finished
+func (vm *VM) Done() bool {
+	return vm.stopped || vm.pointer >= len(vm.data)
+}
+
+func (vm *VM) Run() error {
+	var err error
+	for !vm.Done() && err == nil {
+		err = vm.Step()
+	}
+	if vm.tracer != nil {
+		vm.tracer.CaptureEnd(vm.depth, vm.gasUsed, vm.returnData, err)
+	}
+	return err
+}
+
+// Step executes the next instruction
+func (vm *VM) Step() error {
+	if vm.Done() {
+		return nil
+	}
+	instr := Instruction(vm.data[vm.pointer])
+	gas := InstructionGas(instr)
+	if vm.tracer != nil {
+		vm.tracer.CaptureStep(vm.pointer, instr, vm.gasLimit-vm.gasUsed, gas, vm.depth, vm.stack)
+	}
+	if err := vm.useGas(gas); err != nil {
+		return err
+	}
+	pos := vm.pointer
+	if err := vm.Exec(instr); err != nil {
+		return fmt.Errorf("instruction (%s) at (%d): %w", instr, pos, err)
+	}
+	vm.pointer++
 	return nil
 }
 
+// store writes the contract storage
+func (vm *VM) store(key, value []byte) {
+	vm.contractState.Add(key, value)
+	if vm.tracer != nil {
+		vm.tracer.CaptureStore(vm.ctx.Address, key, value)
+	}
+}
+
 func (vm *VM) Exec(instr Instruction) error {
 	switch instr {
-	case InstrPushInt:
-		// "vm.data[vm.instrPointer]-1" is the byte that is pushed to the stack
-		// which comes before "InstrPush" command
-		vm.stack.Push(int(vm.data[vm.pointer-1]))
-	case InstrAdd:
-		b := vm.stack.Pop().(int)
-		a := vm.stack.Pop().(int)
-		c := a + b
-		vm.stack.Push(c)
-	case InstrSub:
-		b := vm.stack.Pop().(int)
-		a := vm.stack.Pop().(int)
-		c := a - b
-		vm.stack.Push(c)
-	case InstrMul:
-		b := vm.stack.Pop().(int)
-		a := vm.stack.Pop().(int)
-		c := a * b
-		vm.stack.Push(c)
-	case InstrDiv:
-		b := vm.stack.Pop().(int)
-		a := vm.stack.Pop().(int)
-		c := a / b
-		vm.stack.Push(c)
+	case InstrAdd, InstrSub, InstrMul, InstrDiv:
+		b, err := vm.popInt()
+		if err != nil {
+			return err
+		}
+		a, err := vm.popInt()
+		if err != nil {
+			return err
+		}
+		c := new(big.Int)
+		switch instr {
+		case InstrAdd:
+			c.Add(a, b)
+		case InstrSub:
+			c.Sub(a, b)
+		case InstrMul:
+			c.Mul(a, b)
+		case InstrDiv:
+			if b.Sign() == 0 {
+				return ErrDivisionByZero
+			}
+			c.Div(a, b)
+		}
+		return vm.stack.Push(toWord(c))
 	case InstrPushByte:
-		vm.stack.Push(vm.data[vm.pointer-1])
+		operand, err := vm.immediate(1)
+		if err != nil {
+			return err
+		}
+		return vm.stack.Push(operand[0])
 	// pack into array
 	case InstrPack:
-		n := vm.stack.Pop().(int)
-		b := make([]byte, n)
-		for i := n - 1; i >= 0; i-- {
-			b[i] = vm.stack.Pop().(byte)
+		n, err := vm.popInt()
+		if err != nil {
+			return err
+		}
+		if !n.IsInt64() || n.Int64() > int64(vm.stack.Len()) {
+			return ErrStackUnderflow
 		}
-		vm.stack.Push(b)
+		b := make([]byte, n.Int64())
+		for i := len(b) - 1; i >= 0; i-- {
+			value, err := vm.stack.Pop()
+			if err != nil {
+				return err
+			}
+			char, ok := value.(byte)
+			if !ok {
+				return fmt.Errorf("%w: expected byte, got (%T)", ErrInvalidOperand, value)
+			}
+			b[i] = char
+		}
+		return vm.stack.Push(b)
 	case InstrStore:
-		key := vm.stack.Pop().([]byte)
-		value := vm.stack.Pop()
-
-		var serializedValue []byte
-		switch v := value.(type) {
-		case int:
-			serializedValue = serializeInt64(int64(v))
-		default:
-			panic("VM.Exec: unknown type")
+		key, err := vm.popBytes()
+		if err != nil {
+			return err
 		}
-
-		vm.contractState.Add(key, serializedValue)
+		value, err := vm.stack.Pop()
+		if err != nil {
+			return err
+		}
+		serializedValue, err := valueBytes(value)
+		if err != nil {
+			return err
+		}
+		vm.store(key, serializedValue)
 	case InstrGet:
-		key := vm.stack.Pop().([]byte)
+		key, err := vm.popBytes()
+		if err != nil {
+			return err
+		}
 		value, err := vm.contractState.Get(key)
 		if err != nil {
 			return err
 		}
-		vm.stack.Push(value)
+		return vm.stack.Push(value)
+	case InstrEq:
+		b, err := vm.stack.Pop()
+		if err != nil {
+			return err
+		}
+		a, err := vm.stack.Pop()
+		if err != nil {
+			return err
+		}
+		eq, err := equal(a, b)
+		if err != nil {
+			return err
+		}
+		return vm.stack.Push(boolToInt(eq))
+	case InstrLt, InstrGt, InstrAnd, InstrOr:
+		b, err := vm.popInt()
+		if err != nil {
+			return err
+		}
+		a, err := vm.popInt()
+		if err != nil {
+			return err
+		}
+		var c bool
+		switch instr {
+		case InstrLt:
+			c = a.Cmp(b) < 0
+		case InstrGt:
+			c = a.Cmp(b) > 0
+		case InstrAnd:
+			c = a.Sign() != 0 && b.Sign() != 0
+		case InstrOr:
+			c = a.Sign() != 0 || b.Sign() != 0
+		}
+		return vm.stack.Push(boolToInt(c))
+	case InstrNot:
+		a, err := vm.popInt()
+		if err != nil {
+			return err
+		}
+		return vm.stack.Push(boolToInt(a.Sign() == 0))
+	case InstrJump:
+		dest, err := vm.popInt()
+		if err != nil {
+			return err
+		}
+		return vm.jump(dest)
+	case InstrJumpI:
+		dest, err := vm.popInt()
+		if err != nil {
+			return err
+		}
+		cond, err := vm.popInt()
+		if err != nil {
+			return err
+		}
+		if cond.Sign() != 0 {
+			return vm.jump(dest)
+		}
+	case InstrJumpDest:
+	case InstrDup:
+		n, err := vm.popSmallInt()
+		if err != nil {
+			return err
+		}
+		value, err := vm.stack.Peek(n)
+		if err != nil {
+			return err
+		}
+		return vm.stack.Push(value)
+	case InstrSwap:
+		n, err := vm.popSmallInt()
+		if err != nil {
+			return err
+		}
+		return vm.stack.Swap(n)
+	case InstrPop:
+		_, err := vm.stack.Pop()
+		return err
+	case InstrReturn, InstrRevert:
+		value, err := vm.stack.Pop()
+		if err != nil {
+			return err
+		}
+		vm.returnData, err = valueBytes(value)
+		if err != nil {
+			return err
+		}
+		vm.stopped = true
+		if instr == InstrRevert {
+			return ErrReverted
+		}
+	case InstrCaller:
+		return vm.stack.Push(addressToInt(vm.ctx.Caller))
+	case InstrCallValue:
+		value := new(big.Int)
+		if vm.ctx.Value != nil {
+			value.Set(vm.ctx.Value)
+		}
+		return vm.stack.Push(value)
+	case InstrBalance:
+		addr, err := vm.popAddress()
+		if err != nil {
+			return err
+		}
+		return vm.stack.Push(vm.balance(addr))
+	case InstrSelfBalance:
+		return vm.stack.Push(vm.balance(vm.ctx.Address))
+	case InstrBlockHeight:
+		return vm.stack.Push(new(big.Int).SetUint64(uint64(vm.ctx.BlockHeight)))
+	case InstrTimestamp:
+		return vm.stack.Push(toWord(big.NewInt(vm.ctx.Timestamp)))
+	case InstrTransfer:
+		amount, err := vm.popInt()
+		if err != nil {
+			return err
+		}
+		to, err := vm.popAddress()
+		if err != nil {
+			return err
+		}
+		return vm.accounts.Transfer(vm.ctx.Address, to, amount)
+	case InstrCall:
+		gas, err := vm.popInt()
+		if err != nil {
+			return err
+		}
+		addr, err := vm.popAddress()
+		if err != nil {
+			return err
+		}
+		value, err := vm.popInt()
+		if err != nil {
+			return err
+		}
+		return vm.stack.Push(boolToInt(vm.call(addr, value, gas)))
+	case InstrReturnData:
+		return vm.stack.Push(append([]byte{}, vm.returnBuffer...))
+	case InstrLog:
+		n, err := vm.popSmallInt()
+		if err != nil {
+			return err
+		}
+		if n > MaxLogTopics {
+			return fmt.Errorf("%w: log can't have (%d) topics", ErrInvalidOperand, n)
+		}
+		topics := make([]types.Hash, n)
+		for i := n - 1; i >= 0; i-- {
+			topic, err := vm.popInt()
+			if err != nil {
+				return err
+			}
+			topics[i] = types.HashFromBytes(wordBytes(topic))
+		}
+		value, err := vm.stack.Pop()
+		if err != nil {
+			return err
+		}
+		data, err := valueBytes(value)
+		if err != nil {
+			return err
+		}
+		vm.logs = append(vm.logs, &Log{
+			Address: vm.ctx.Address,
+			Topics:  topics,
+			Data:    data,
+		})
+	// storage words, keys and values are integers and a missing key reads as zero
+	case InstrSLoad:
+		key, err := vm.popInt()
+		if err != nil {
+			return err
+		}
+		value, err := vm.contractState.Get(wordBytes(key))
+		if err != nil {
+			return vm.stack.Push(new(big.Int))
+		}
+		return vm.stack.Push(new(big.Int).SetBytes(value))
+	case InstrSStore:
+		key, err := vm.popInt()
+		if err != nil {
+			return err
+		}
+		value, err := vm.popInt()
+		if err != nil {
+			return err
+		}
+		vm.store(wordBytes(key), wordBytes(value))
+	case InstrInput:
+		n, err := vm.popInt()
+		if err != nil {
+			return err
+		}
+		return vm.stack.Push(vm.inputWord(n))
+	case InstrHash:
+		b, err := vm.popInt()
+		if err != nil {
+			return err
+		}
+		a, err := vm.popInt()
+		if err != nil {
+			return err
+		}
+		hash := sha256.Sum256(append(wordBytes(a), wordBytes(b)...))
+		return vm.stack.Push(new(big.Int).SetBytes(hash[:]))
+	default:
+		if size := instr.ImmediateSize(); size > 0 {
+			operand, err := vm.immediate(size)
+			if err != nil {
+				return err
+			}
+			return vm.stack.Push(new(big.Int).SetBytes(operand))
+		}
+		return ErrInvalidOpcode
+	}
+	return nil
+}
+
+// inputWord returns the nth 32-byte word of the call input, the input is padded with zeros
+func (vm *VM) inputWord(n *big.Int) *big.Int {
+	word := make([]byte, wordSize)
+	if n.IsInt64() && n.Int64() < int64(len(vm.ctx.Input)/wordSize+1) {
+		copy(word, vm.ctx.Input[n.Int64()*wordSize:])
+	}
+	return new(big.Int).SetBytes(word)
+}
+
+// immediate returns the operand following the current instruction and moves the pointer to its last byte
+func (vm *VM) immediate(size int) ([]byte, error) {
+	start := vm.pointer + 1
+	if start+size > len(vm.data) {
+		return nil, fmt.Errorf("%w: push instruction needs (%d) bytes of operand, got (%d)",
+			ErrInvalidOpcode, size, len(vm.data)-start)
+	}
+	vm.pointer += size
+	return vm.data[start : start+size], nil
+}
+
+// call executes code of the contract at the address forwarding at most the given gas
+// and reports whether the call succeeded.
+// Changes made by a failed call are reverted, the caller continues execution in any case.
+func (vm *VM) call(addr types.Address, value, gas *big.Int) bool {
+	vm.returnBuffer = nil
+	if vm.depth >= MaxCallDepth {
+		return false
+	}
+
+	callGas := vm.gasLimit - vm.gasUsed
+	if gas.IsUint64() && gas.Uint64() < callGas {
+		callGas = gas.Uint64()
+	}
+
+	accountsSnapshot := vm.accounts.Snapshot()
+	stateSnapshot := vm.state.Snapshot()
+	revert := func() {
+		vm.accounts.RevertToSnapshot(accountsSnapshot)
+		vm.state.RevertToSnapshot(stateSnapshot)
+	}
+
+	if value.Sign() > 0 {
+		if err := vm.accounts.Transfer(vm.ctx.Address, addr, value); err != nil {
+			revert()
+			return false
+		}
+	}
+
+	// call of an account without code only transfers the value
+	code, err := vm.state.Code(addr)
+	if err != nil {
+		return true
+	}
+
+	ctx := &Context{
+		Caller:      vm.ctx.Address,
+		Address:     addr,
+		Value:       value,
+		BlockHeight: vm.ctx.BlockHeight,
+		Timestamp:   vm.ctx.Timestamp,
+	}
+	callee := NewVM(ctx, code, vm.state, vm.accounts, callGas)
+	callee.depth = vm.depth + 1
+	callee.tracer = vm.tracer
+	err = callee.Run()
+	vm.gasUsed += callee.GasUsed()
+	vm.returnBuffer = callee.ReturnData()
+	if err != nil {
+		revert()
+		return false
+	}
+	vm.logs = append(vm.logs, callee.Logs()...)
+	return true
+}
+
+// jump moves execution to the jump destination,
+// the next executed instruction is the one after the destination
+func (vm *VM) jump(dest *big.Int) error {
+	if !dest.IsInt64() || !vm.validJumpDest(int(dest.Int64())) {
+		return fmt.Errorf("%w: (%s)", ErrInvalidJump, dest)
 	}
+	vm.pointer = int(dest.Int64())
 	return nil
 }
+
+func (vm *VM) popInt() (*big.Int, error) {
+	value, err := vm.stack.Pop()
+	if err != nil {
+		return nil, err
+	}
+	v, ok := value.(*big.Int)
+	if !ok {
+		return nil, fmt.Errorf("%w: expected int, got (%T)", ErrInvalidOperand, value)
+	}
+	return v, nil
+}
+
+// popAddress pops an address, which is the lowest 20 bytes of the integer
+func (vm *VM) popAddress() (types.Address, error) {
+	v, err := vm.popInt()
+	if err != nil {
+		return types.Address{}, err
+	}
+	return types.AddressFromBytes(wordBytes(v)[wordSize-len(types.Address{}):]), nil
+}
+
+// balance returns balance of the account, non-existent account has zero balance
+func (vm *VM) balance(addr types.Address) *big.Int {
+	balance, _ := vm.accounts.GetBalance(addr)
+	return new(big.Int).Set(balance)
+}
+
+func addressToInt(addr types.Address) *big.Int {
+	return new(big.Int).SetBytes(addr[:])
+}
+
+// popSmallInt pops an integer used as a stack position
+func (vm *VM) popSmallInt() (int, error) {
+	v, err := vm.popInt()
+	if err != nil {
+		return 0, err
+	}
+	if !v.IsInt64() || v.Int64() > stackSize {
+		return 0, ErrStackUnderflow
+	}
+	return int(v.Int64()), nil
+}
+
+func (vm *VM) popBytes() ([]byte, error) {
+	value, err := vm.stack.Pop()
+	if err != nil {
+		return nil, err
+	}
+	v, ok := value.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("%w: expected bytes, got (%T)", ErrInvalidOperand, value)
+	}
+	return v, nil
+}
+
+// valueBytes serializes the stack item, integers are serialized as 32-byte words
+func valueBytes(value any) ([]byte, error) {
+	switch v := value.(type) {
+	case *big.Int:
+		return wordBytes(v), nil
+	case []byte:
+		return v, nil
+	default:
+		return nil, fmt.Errorf("%w: can't serialize value of type (%T)", ErrInvalidOperand, value)
+	}
+}
+
+func equal(a, b any) (bool, error) {
+	switch a := a.(type) {
+	case *big.Int:
+		if b, ok := b.(*big.Int); ok {
+			return a.Cmp(b) == 0, nil
+		}
+	case []byte:
+		if b, ok := b.([]byte); ok {
+			return bytes.Equal(a, b), nil
+		}
+	}
+	return false, fmt.Errorf("%w: can't compare (%T) and (%T)", ErrInvalidOperand, a, b)
+}
+
+func boolToInt(b bool) *big.Int {
+	if b {
+		return big.NewInt(1)
+	}
+	return new(big.Int)
+}
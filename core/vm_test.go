@@ -1,91 +1,591 @@
 package core
 
 import (
+	"blockchain/types"
+	"bytes"
+	"crypto/sha256"
 	"github.com/stretchr/testify/assert"
+	"math/big"
 	"testing"
 )
 
 func TestStackPushPop(t *testing.T) {
-	stack := NewStack(8)
-	stack.Push(1)
-	stack.Push(2)
-	stack.Push("hey")
-	assert.Equal(t, "hey", stack.Pop())
-	assert.Equal(t, 2, stack.Pop())
+	stack := NewStack(3)
+	assert.Nil(t, stack.Push(1))
+	assert.Nil(t, stack.Push(2))
+	assert.Nil(t, stack.Push("hey"))
+	assert.ErrorIs(t, stack.Push(3), ErrStackOverflow)
+
+	value, err := stack.Pop()
+	assert.Nil(t, err)
+	assert.Equal(t, "hey", value)
+	value, _ = stack.Pop()
+	assert.Equal(t, 2, value)
+	value, _ = stack.Pop()
+	assert.Equal(t, 1, value)
+	_, err = stack.Pop()
+	assert.ErrorIs(t, err, ErrStackUnderflow)
 }
 
 func TestVM_InstrAdd(t *testing.T) {
 	ins := new(Instr)
 	ins.Add(1, 2)
 	contractState := NewState()
-	vm := NewVM(ins.Bytes(), contractState)
+	vm := NewVM(&Context{}, ins.Bytes(), contractState, NewAccountsState(), DefaultGasLimit)
 	assert.Nil(t, vm.Run())
 
-	result := vm.stack.Pop()
-	assert.Equal(t, 3, result)
+	assert.Equal(t, int64(3), popInt64(t, vm))
 }
 
 func TestVM_InstrSub(t *testing.T) {
 	ins := new(Instr)
 	ins.Sub(5, 3)
 	contractState := NewState()
-	vm := NewVM(ins.Bytes(), contractState)
+	vm := NewVM(&Context{}, ins.Bytes(), contractState, NewAccountsState(), DefaultGasLimit)
 	assert.Nil(t, vm.Run())
 
-	result := vm.stack.Pop()
-	assert.Equal(t, 2, result)
+	assert.Equal(t, int64(2), popInt64(t, vm))
 }
 
 func TestVM_InstrMul(t *testing.T) {
 	ins := new(Instr)
 	ins.Mul(4, 2)
 	contractState := NewState()
-	vm := NewVM(ins.Bytes(), contractState)
+	vm := NewVM(&Context{}, ins.Bytes(), contractState, NewAccountsState(), DefaultGasLimit)
 	assert.Nil(t, vm.Run())
 
-	result := vm.stack.Pop()
-	assert.Equal(t, 8, result)
+	assert.Equal(t, int64(8), popInt64(t, vm))
 }
 
 func TestVM_InstrDiv(t *testing.T) {
 	ins := new(Instr)
 	ins.Div(8, 2)
 	contractState := NewState()
-	vm := NewVM(ins.Bytes(), contractState)
+	vm := NewVM(&Context{}, ins.Bytes(), contractState, NewAccountsState(), DefaultGasLimit)
 	assert.Nil(t, vm.Run())
 
-	result := vm.stack.Pop()
-	assert.Equal(t, 4, result)
+	assert.Equal(t, int64(4), popInt64(t, vm))
 }
 
 func TestVM_InstrPushByteInstrPack(t *testing.T) {
 	ins := new(Instr)
 	ins.String("hey")
 	contractState := NewState()
-	vm := NewVM(ins.Bytes(), contractState)
+	vm := NewVM(&Context{}, ins.Bytes(), contractState, NewAccountsState(), DefaultGasLimit)
 	assert.Nil(t, vm.Run())
 
-	result := vm.stack.Pop().([]byte)
-	assert.Equal(t, "hey", string(result))
+	result, _ := vm.stack.Pop()
+	assert.Equal(t, "hey", string(result.([]byte)))
 }
 
 func TestVM_InstrStore(t *testing.T) {
 	ins := new(Instr)
 	ins.Add(5, 2).String("hey").Store()
 	contractState := NewState()
-	vm := NewVM(ins.Bytes(), contractState)
+	vm := NewVM(&Context{}, ins.Bytes(), contractState, NewAccountsState(), DefaultGasLimit)
 	assert.Nil(t, vm.Run())
 
-	value, err := contractState.Get([]byte("hey"))
+	value, err := contractState.ContractStorage(types.Address{}).Get([]byte("hey"))
 	assert.Nil(t, err)
-	assert.Equal(t, int64(7), deserializeInt64(value))
+	assert.Equal(t, int64(7), new(big.Int).SetBytes(value).Int64())
 }
 
 func TestVM_InstrGet(t *testing.T) {
 	ins := new(Instr)
 	ins.Add(2, 3).String("hey").Store().Get("hey")
 	contractState := NewState()
-	vm := NewVM(ins.Bytes(), contractState)
+	vm := NewVM(&Context{}, ins.Bytes(), contractState, NewAccountsState(), DefaultGasLimit)
+	assert.Nil(t, vm.Run())
+	result, _ := vm.stack.Pop()
+	assert.Equal(t, int64(5), new(big.Int).SetBytes(result.([]byte)).Int64())
+}
+
+func TestVM_OutOfGas(t *testing.T) {
+	ins := new(Instr)
+	ins.Add(2, 3).String("hey").Store()
+
+	vm := NewVM(&Context{}, ins.Bytes(), NewState(), NewAccountsState(), DefaultGasLimit)
+	assert.Nil(t, vm.Run())
+	gasUsed := vm.GasUsed()
+	assert.Greater(t, gasUsed, GasStore)
+
+	contractState := NewState()
+	vm = NewVM(&Context{}, ins.Bytes(), contractState, NewAccountsState(), gasUsed-1)
+	assert.ErrorIs(t, vm.Run(), ErrOutOfGas)
+	assert.Equal(t, gasUsed-1, vm.GasUsed())
+	_, err := contractState.ContractStorage(types.Address{}).Get([]byte("hey"))
+	assert.NotNil(t, err)
+}
+
+func TestVM_Errors(t *testing.T) {
+	push1 := byte(InstrPush1)
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"stack underflow", []byte{push1, 1, byte(InstrAdd)}, ErrStackUnderflow},
+		{"division by zero", new(Instr).Div(1, 0).Bytes(), ErrDivisionByZero},
+		{"invalid opcode", []byte{0xff}, ErrInvalidOpcode},
+		{"push without operand", []byte{push1}, ErrInvalidOpcode},
+		{"truncated push", []byte{byte(InstrPush32), 1, 2}, ErrInvalidOpcode},
+		{"add bytes", append(new(Instr).String("a").String("b").Bytes(), byte(InstrAdd)), ErrInvalidOperand},
+		{"store bytes key", new(Instr).Add(1, 2).Add(3, 4).Store().Bytes(), ErrInvalidOperand},
+		{"pack too many", []byte{byte(InstrPushByte), 'a', push1, 2, byte(InstrPack)}, ErrStackUnderflow},
+		{"pack huge", append(new(Instr).Sub(0, 1).Bytes(), byte(InstrPack)), ErrStackUnderflow},
+		{"stack overflow", bytes.Repeat([]byte{push1, 1}, stackSize+1), ErrStackOverflow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vm := NewVM(&Context{}, tt.data, NewState(), NewAccountsState(), DefaultGasLimit)
+			assert.ErrorIs(t, vm.Run(), tt.err)
+		})
+	}
+}
+
+func FuzzVM_Run(f *testing.F) {
+	f.Add([]byte{})
+	f.Add(new(Instr).Add(1, 2).Bytes())
+	f.Add(new(Instr).Div(8, 0).Bytes())
+	f.Add(new(Instr).Add(2, 3).String("hey").Store().Get("hey").Bytes())
+	f.Add([]byte{byte(InstrPack), byte(InstrStore), byte(InstrGet)})
+	f.Add(new(Instr).JumpDest().Int(1).JumpI(0).Bytes())
+	f.Add(new(Instr).Int(1).Dup(1).Swap(1).Eq().Not().Return().Bytes())
+	f.Add(new(Instr).Int(0).Address(types.Address{1}).Int(100).Call().ReturnData().Bytes())
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		contractState := NewState()
+		contractState.ContractStorage(types.Address{}).Add([]byte("hey"), wordBytes(big.NewInt(5)))
+		vm := NewVM(&Context{}, data, contractState, NewAccountsState(), DefaultGasLimit)
+		vm.Run()
+		assert.LessOrEqual(t, vm.GasUsed(), uint64(DefaultGasLimit))
+	})
+}
+
+func popInt64(t *testing.T, vm *VM) int64 {
+	value, err := vm.popInt()
+	assert.Nil(t, err)
+	return value.Int64()
+}
+
+func runInstr(t *testing.T, ins *Instr) (*VM, error) {
+	vm := NewVM(&Context{}, ins.Bytes(), NewState(), NewAccountsState(), DefaultGasLimit)
+	err := vm.Run()
+	return vm, err
+}
+
+func TestVM_Comparison(t *testing.T) {
+	tests := []struct {
+		name   string
+		ins    *Instr
+		result int
+	}{
+		{"eq", new(Instr).Int(3).Int(3).Eq(), 1},
+		{"not eq", new(Instr).Int(3).Int(4).Eq(), 0},
+		{"eq bytes", new(Instr).String("hey").String("hey").Eq(), 1},
+		{"lt", new(Instr).Int(3).Int(4).Lt(), 1},
+		{"not lt", new(Instr).Int(4).Int(4).Lt(), 0},
+		{"gt", new(Instr).Int(5).Int(4).Gt(), 1},
+		{"not gt", new(Instr).Int(3).Int(4).Gt(), 0},
+		{"and", new(Instr).Int(1).Int(2).And(), 1},
+		{"not and", new(Instr).Int(1).Int(0).And(), 0},
+		{"or", new(Instr).Int(0).Int(2).Or(), 1},
+		{"not or", new(Instr).Int(0).Int(0).Or(), 0},
+		{"not", new(Instr).Int(0).Not(), 1},
+		{"not not", new(Instr).Int(7).Not(), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vm, err := runInstr(t, tt.ins)
+			assert.Nil(t, err)
+			assert.Equal(t, int64(tt.result), popInt64(t, vm))
+		})
+	}
+
+	_, err := runInstr(t, new(Instr).Int(1).String("a").Eq())
+	assert.ErrorIs(t, err, ErrInvalidOperand)
+}
+
+func TestVM_StackInstructions(t *testing.T) {
+	vm, err := runInstr(t, new(Instr).Int(1).Int(2).Int(3).Dup(3).Swap(2).Pop())
+	assert.Nil(t, err)
+	// 1 2 3 1 -> 1 1 3 2 -> 1 1 3
+	for _, expected := range []int{3, 1, 1} {
+		assert.Equal(t, int64(expected), popInt64(t, vm))
+	}
+
+	_, err = runInstr(t, new(Instr).Int(1).Dup(2))
+	assert.ErrorIs(t, err, ErrStackUnderflow)
+	_, err = runInstr(t, new(Instr).Int(1).Swap(1))
+	assert.ErrorIs(t, err, ErrStackUnderflow)
+	_, err = runInstr(t, new(Instr).Pop())
+	assert.ErrorIs(t, err, ErrStackUnderflow)
+}
+
+func TestVM_Jump(t *testing.T) {
+	// sum of numbers from 5 to 1
+	ins := new(Instr).Int(0).Int(5)
+	loop := ins.Len()
+	ins.JumpDest()
+	ins.Dup(1).Swap(2).op(InstrAdd).Swap(1).Int(1).op(InstrSub)
+	ins.Dup(1).JumpI(loop)
+	ins.Pop().Return()
+
+	vm, err := runInstr(t, ins)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(15), new(big.Int).SetBytes(vm.ReturnData()).Int64())
+
+	// jump over the store
+	skipped := new(Instr).Add(1, 2).String("a").Store()
+	ins = new(Instr).Jump(new(Instr).Jump(0).Len() + skipped.Len())
+	ins.data = append(ins.data, skipped.Bytes()...)
+	ins.JumpDest()
+	contractState := NewState()
+	vm = NewVM(&Context{}, ins.Bytes(), contractState, NewAccountsState(), DefaultGasLimit)
+	assert.Nil(t, vm.Run())
+	_, err = contractState.ContractStorage(types.Address{}).Get([]byte("a"))
+	assert.NotNil(t, err)
+
+	// jump to an instruction which is not a jump destination
+	_, err = runInstr(t, new(Instr).Jump(0))
+	assert.ErrorIs(t, err, ErrInvalidJump)
+	// jump to an operand looking like a jump destination
+	_, err = runInstr(t, new(Instr).Int(int(InstrJumpDest)).Pop().Jump(0))
+	assert.ErrorIs(t, err, ErrInvalidJump)
+	_, err = runInstr(t, new(Instr).Jump(200))
+	assert.ErrorIs(t, err, ErrInvalidJump)
+	// condition isn't met
+	_, err = runInstr(t, new(Instr).Int(0).JumpI(200))
+	assert.Nil(t, err)
+
+	// infinite loop runs out of gas
+	ins = new(Instr)
+	ins.JumpDest().Jump(0)
+	_, err = runInstr(t, ins)
+	assert.ErrorIs(t, err, ErrOutOfGas)
+}
+
+func TestVM_ReturnRevert(t *testing.T) {
+	vm, err := runInstr(t, new(Instr).String("ok").Return().Add(1, 2))
+	assert.Nil(t, err)
+	assert.Equal(t, "ok", string(vm.ReturnData()))
+	// execution stops at return
+	assert.Equal(t, 0, vm.stack.Len())
+
+	vm, err = runInstr(t, new(Instr).String("fail").Revert())
+	assert.ErrorIs(t, err, ErrReverted)
+	assert.Equal(t, "fail", string(vm.ReturnData()))
+}
+
+func TestInstr_BigInt(t *testing.T) {
+	tests := []struct {
+		value *big.Int
+		data  []byte
+	}{
+		{big.NewInt(0), []byte{byte(InstrPush1), 0}},
+		{big.NewInt(255), []byte{byte(InstrPush1), 0xff}},
+		{big.NewInt(256), []byte{byte(InstrPush1) + 1, 0x01, 0x00}},
+		{big.NewInt(-1), append([]byte{byte(InstrPush32)}, bytes.Repeat([]byte{0xff}, 32)...)},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.data, new(Instr).BigInt(tt.value).Bytes())
+	}
+}
+
+func TestVM_BigInt(t *testing.T) {
+	oneEther, _ := new(big.Int).SetString("1000000000000000000", 10)
+	vm, err := runInstr(t, new(Instr).BigInt(oneEther).Int(3).op(InstrMul))
+	assert.Nil(t, err)
+	result, _ := vm.popInt()
+	assert.Equal(t, "3000000000000000000", result.String())
+
+	vm, err = runInstr(t, new(Instr).Add(70_000, 1_000_000))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1_070_000), popInt64(t, vm))
+
+	// arithmetic wraps around 2^256
+	max := new(big.Int).Sub(wordModulus, big.NewInt(1))
+	vm, err = runInstr(t, new(Instr).BigInt(max).Int(2).op(InstrAdd))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), popInt64(t, vm))
+
+	vm, err = runInstr(t, new(Instr).Sub(1, 2))
+	assert.Nil(t, err)
+	result, _ = vm.popInt()
+	assert.Equal(t, max, result)
+
+	// integers are stored as 32-byte words
+	contractState := NewState()
+	ins := new(Instr).BigInt(oneEther).String("balance").Store()
+	vm = NewVM(&Context{}, ins.Bytes(), contractState, NewAccountsState(), DefaultGasLimit)
+	assert.Nil(t, vm.Run())
+	value, err := contractState.ContractStorage(types.Address{}).Get([]byte("balance"))
+	assert.Nil(t, err)
+	assert.Len(t, value, 32)
+	assert.Equal(t, oneEther, new(big.Int).SetBytes(value))
+}
+
+func TestVM_Context(t *testing.T) {
+	caller := types.Address{1}
+	contract := types.Address{2}
+	other := types.Address{3}
+	accounts := NewAccountsState()
+	accounts.CreateAccount(contract, big.NewInt(1_000))
+	accounts.CreateAccount(other, big.NewInt(50))
+
+	ctx := &Context{
+		Caller:      caller,
+		Address:     contract,
+		Value:       big.NewInt(10),
+		BlockHeight: 7,
+		Timestamp:   1_700_000_000,
+	}
+	tests := []struct {
+		name   string
+		ins    *Instr
+		result *big.Int
+	}{
+		{"caller", new(Instr).Caller(), new(big.Int).SetBytes(caller[:])},
+		{"call value", new(Instr).CallValue(), big.NewInt(10)},
+		{"balance", new(Instr).Address(other).Balance(), big.NewInt(50)},
+		{"balance of missing account", new(Instr).Address(types.Address{4}).Balance(), big.NewInt(0)},
+		{"self balance", new(Instr).SelfBalance(), big.NewInt(1_000)},
+		{"block height", new(Instr).BlockHeight(), big.NewInt(7)},
+		{"timestamp", new(Instr).Timestamp(), big.NewInt(1_700_000_000)},
+		{"caller is equal to its address", new(Instr).Caller().Address(caller).Eq(), big.NewInt(1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vm := NewVM(ctx, tt.ins.Bytes(), NewState(), accounts, DefaultGasLimit)
+			assert.Nil(t, vm.Run())
+			result, err := vm.popInt()
+			assert.Nil(t, err)
+			assert.Equal(t, 0, tt.result.Cmp(result))
+		})
+	}
+}
+
+func TestVM_Transfer(t *testing.T) {
+	contract := types.Address{2}
+	to := types.Address{3}
+	accounts := NewAccountsState()
+	accounts.CreateAccount(contract, big.NewInt(1_000))
+	ctx := &Context{Address: contract}
+
+	vm := NewVM(ctx, new(Instr).Address(to).Int(300).Transfer().Bytes(), NewState(), accounts, DefaultGasLimit)
 	assert.Nil(t, vm.Run())
-	assert.Equal(t, int64(5), deserializeInt64(vm.stack.Pop().([]byte)))
+	balance, _ := accounts.GetBalance(contract)
+	assert.Equal(t, big.NewInt(700), balance)
+	balance, _ = accounts.GetBalance(to)
+	assert.Equal(t, big.NewInt(300), balance)
+
+	vm = NewVM(ctx, new(Instr).Address(to).Int(701).Transfer().Bytes(), NewState(), accounts, DefaultGasLimit)
+	assert.NotNil(t, vm.Run())
+	balance, _ = accounts.GetBalance(contract)
+	assert.Equal(t, big.NewInt(700), balance)
+}
+
+func TestVM_Call(t *testing.T) {
+	caller := types.Address{1}
+	callee := types.Address{2}
+	state := NewState()
+	accounts := NewAccountsState()
+	accounts.CreateAccount(caller, big.NewInt(1_000))
+
+	// stores the caller and returns the received value doubled
+	ins := new(Instr)
+	ins.Caller().String("caller").Store()
+	ins.CallValue().Int(2).op(InstrMul).Return()
+	assert.Nil(t, state.SetCode(callee, ins.Bytes()))
+
+	ins = new(Instr)
+	ins.Int(100).Address(callee).Int(10_000).Call().String("ok").Store()
+	ins.ReturnData().String("result").Store()
+	vm := NewVM(&Context{Address: caller}, ins.Bytes(), state, accounts, DefaultGasLimit)
+	assert.Nil(t, vm.Run())
+
+	value, err := state.ContractStorage(caller).Get([]byte("ok"))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), new(big.Int).SetBytes(value).Int64())
+	value, err = state.ContractStorage(caller).Get([]byte("result"))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(200), new(big.Int).SetBytes(value).Int64())
+	value, err = state.ContractStorage(callee).Get([]byte("caller"))
+	assert.Nil(t, err)
+	assert.Equal(t, caller[:], value[wordSize-len(caller):])
+
+	balance, _ := accounts.GetBalance(callee)
+	assert.Equal(t, big.NewInt(100), balance)
+	// gas used by the callee is charged to the caller
+	assert.Greater(t, vm.GasUsed(), GasCall+2*GasStore)
+}
+
+func TestVM_CallRevert(t *testing.T) {
+	caller := types.Address{1}
+	callee := types.Address{2}
+	state := NewState()
+	accounts := NewAccountsState()
+	accounts.CreateAccount(caller, big.NewInt(1_000))
+
+	ins := new(Instr)
+	ins.Int(1).String("written").Store()
+	ins.String("no").Revert()
+	assert.Nil(t, state.SetCode(callee, ins.Bytes()))
+
+	ins = new(Instr)
+	ins.Int(100).Address(callee).Int(10_000).Call().String("ok").Store()
+	ins.ReturnData().String("reason").Store()
+	vm := NewVM(&Context{Address: caller}, ins.Bytes(), state, accounts, DefaultGasLimit)
+	assert.Nil(t, vm.Run())
+
+	value, err := state.ContractStorage(caller).Get([]byte("ok"))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), new(big.Int).SetBytes(value).Int64())
+	value, err = state.ContractStorage(caller).Get([]byte("reason"))
+	assert.Nil(t, err)
+	assert.Equal(t, "no", string(value))
+
+	// changes of the callee and the value transfer are reverted
+	_, err = state.ContractStorage(callee).Get([]byte("written"))
+	assert.NotNil(t, err)
+	balance, _ := accounts.GetBalance(caller)
+	assert.Equal(t, big.NewInt(1_000), balance)
+}
+
+func TestVM_CallGas(t *testing.T) {
+	caller := types.Address{1}
+	callee := types.Address{2}
+	state := NewState()
+
+	// infinite loop
+	ins := new(Instr)
+	ins.JumpDest().Jump(0)
+	assert.Nil(t, state.SetCode(callee, ins.Bytes()))
+
+	ins = new(Instr)
+	ins.Int(0).Address(callee).Int(5_000).Call().Return()
+	vm := NewVM(&Context{Address: caller}, ins.Bytes(), state, NewAccountsState(), DefaultGasLimit)
+	assert.Nil(t, vm.Run())
+	assert.Equal(t, int64(0), new(big.Int).SetBytes(vm.ReturnData()).Int64())
+	// only the forwarded gas is consumed by the callee
+	assert.Greater(t, vm.GasUsed(), uint64(5_000))
+	assert.Less(t, vm.GasUsed(), uint64(6_000))
+}
+
+func TestVM_CallDepth(t *testing.T) {
+	contract := types.Address{1}
+	state := NewState()
+
+	// calls itself until the call fails
+	ins := new(Instr)
+	ins.Int(0).Address(contract).Int(int(DefaultGasLimit)).Call().Return()
+	assert.Nil(t, state.SetCode(contract, ins.Bytes()))
+
+	vm := NewVM(&Context{Address: contract}, ins.Bytes(), state, NewAccountsState(), DefaultGasLimit)
+	vm.depth = MaxCallDepth - 1
+	assert.Nil(t, vm.Run())
+	// the nested call succeeded, its own call exceeded the depth limit
+	assert.Equal(t, int64(1), new(big.Int).SetBytes(vm.ReturnData()).Int64())
+	assert.Equal(t, int64(0), new(big.Int).SetBytes(vm.returnBuffer).Int64())
+
+	vm = NewVM(&Context{Address: contract}, ins.Bytes(), state, NewAccountsState(), DefaultGasLimit)
+	assert.Nil(t, vm.Run())
+	assert.Equal(t, int64(1), new(big.Int).SetBytes(vm.ReturnData()).Int64())
+}
+
+func TestVM_Log(t *testing.T) {
+	contract := types.Address{1}
+	callee := types.Address{2}
+	topic := types.Hash{0xaa}
+	state := NewState()
+
+	ins := new(Instr)
+	ins.String("callee").Log(0)
+	assert.Nil(t, state.SetCode(callee, ins.Bytes()))
+
+	ins = new(Instr)
+	ins.String("hey").Topic(topic).Int(7).Log(2)
+	ins.Int(0).Address(callee).Int(10_000).Call().Pop()
+	vm := NewVM(&Context{Address: contract}, ins.Bytes(), state, NewAccountsState(), DefaultGasLimit)
+	assert.Nil(t, vm.Run())
+
+	logs := vm.Logs()
+	assert.Len(t, logs, 2)
+	assert.Equal(t, contract, logs[0].Address)
+	assert.Equal(t, []types.Hash{topic, types.HashFromBytes(wordBytes(big.NewInt(7)))}, logs[0].Topics)
+	assert.Equal(t, "hey", string(logs[0].Data))
+	assert.Equal(t, callee, logs[1].Address)
+	assert.Empty(t, logs[1].Topics)
+
+	// logs of a failed call are discarded
+	ins = new(Instr)
+	ins.String("callee").Log(0).String("no").Revert()
+	assert.Nil(t, state.SetCode(types.Address{3}, ins.Bytes()))
+	ins = new(Instr)
+	ins.Int(0).Address(types.Address{3}).Int(10_000).Call()
+	vm = NewVM(&Context{Address: contract}, ins.Bytes(), state, NewAccountsState(), DefaultGasLimit)
+	assert.Nil(t, vm.Run())
+	assert.Empty(t, vm.Logs())
+
+	_, err := runInstr(t, new(Instr).String("hey").Int(1).Int(2).Int(3).Int(4).Int(5).Log(5))
+	assert.ErrorIs(t, err, ErrInvalidOperand)
+}
+
+func TestVM_StorageWords(t *testing.T) {
+	contract := types.Address{2}
+	state := NewState()
+	ctx := &Context{Address: contract}
+
+	vm := NewVM(ctx, new(Instr).Int(7).SLoad().Bytes(), state, NewAccountsState(), DefaultGasLimit)
+	assert.Nil(t, vm.Run())
+	assert.Equal(t, int64(0), popInt64(t, vm))
+
+	vm = NewVM(ctx, new(Instr).Int(42).Int(7).SStore().Int(7).SLoad().Bytes(), state, NewAccountsState(), DefaultGasLimit)
+	assert.Nil(t, vm.Run())
+	assert.Equal(t, int64(42), popInt64(t, vm))
+
+	value, err := state.ContractStorage(contract).Get(wordBytes(big.NewInt(7)))
+	assert.Nil(t, err)
+	assert.Equal(t, wordBytes(big.NewInt(42)), value)
+
+	_, err = runInstr(t, new(Instr).String("key").Int(7).SStore())
+	assert.ErrorIs(t, err, ErrInvalidOperand)
+}
+
+func TestVM_Hash(t *testing.T) {
+	vm, err := runInstr(t, new(Instr).Int(1).Int(2).Hash())
+	assert.Nil(t, err)
+	result, err := vm.popInt()
+	assert.Nil(t, err)
+	hash := sha256.Sum256(append(wordBytes(big.NewInt(1)), wordBytes(big.NewInt(2))...))
+	assert.Equal(t, 0, new(big.Int).SetBytes(hash[:]).Cmp(result))
+
+	_, err = runInstr(t, new(Instr).Int(1).Hash())
+	assert.ErrorIs(t, err, ErrStackUnderflow)
+}
+
+func TestVM_Input(t *testing.T) {
+	input := append(wordBytes(big.NewInt(5)), 0x01)
+	ctx := &Context{Input: input}
+
+	tests := []struct {
+		n      int
+		result *big.Int
+	}{
+		{0, big.NewInt(5)},
+		{1, new(big.Int).Lsh(big.NewInt(1), 248)},
+		{2, big.NewInt(0)},
+	}
+
+	for _, tt := range tests {
+		vm := NewVM(ctx, new(Instr).Int(tt.n).Input().Bytes(), NewState(), NewAccountsState(), DefaultGasLimit)
+		assert.Nil(t, vm.Run())
+		result, err := vm.popInt()
+		assert.Nil(t, err)
+		assert.Equal(t, 0, tt.result.Cmp(result))
+	}
 }
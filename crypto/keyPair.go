@@ -79,6 +79,12 @@ func (pub PublicKey) Key() *ecdsa.PublicKey {
 	return BytesToPublicKey(pub)
 }
 
+// Valid reports whether the public key is a compressed point of the curve
+func (pub PublicKey) Valid() bool {
+	x, _ := elliptic.UnmarshalCompressed(elliptic.P256(), pub)
+	return x != nil
+}
+
 func (pub PublicKey) Address() types.Address {
 	hash := sha256.Sum256(pub)
 	// return last 20 elements of the array
@@ -93,6 +99,16 @@ type Signature struct {
 	R, S *big.Int
 }
 
+// Valid reports whether R and S of the signature are positive
+func (s *Signature) Valid() bool {
+	return s != nil && s.R != nil && s.S != nil && s.R.Sign() > 0 && s.S.Sign() > 0
+}
+
+// Verify reports whether the signature of the data is valid for the public key,
+// it's false for invalid signatures and public keys
 func (s *Signature) Verify(pub PublicKey, data []byte) bool {
+	if !s.Valid() || !pub.Valid() {
+		return false
+	}
 	return ecdsa.Verify(pub.Key(), data, s.R, s.S)
 }
@@ -2,6 +2,7 @@ package crypto
 
 import (
 	"github.com/stretchr/testify/assert"
+	"math/big"
 	"testing"
 )
 
@@ -27,3 +28,16 @@ func TestSignVerifyFail(t *testing.T) {
 	assert.False(t, sig.Verify(publicKey2, msg))
 	assert.False(t, sig.Verify(publicKey1, []byte("hey!")))
 }
+
+func TestSignVerifyInvalid(t *testing.T) {
+	privateKey := GeneratePrivateKey()
+	msg := []byte("hey")
+	sig, err := privateKey.Sign(msg)
+	assert.Nil(t, err)
+
+	assert.False(t, sig.Verify(PublicKey{0x02, 0xaa}, msg))
+	assert.False(t, sig.Verify(nil, msg))
+	assert.False(t, (&Signature{S: sig.S}).Verify(privateKey.PublicKey(), msg))
+	assert.False(t, (&Signature{R: sig.R, S: new(big.Int)}).Verify(privateKey.PublicKey(), msg))
+	assert.False(t, (*Signature)(nil).Verify(privateKey.PublicKey(), msg))
+}
@@ -2,11 +2,13 @@ module blockchain
 
 go 1.22.2
 
-require github.com/stretchr/testify v1.9.0
+require (
+	github.com/labstack/echo/v4 v4.12.0
+	github.com/stretchr/testify v1.9.0
+)
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
-	github.com/labstack/echo/v4 v4.12.0 // indirect
 	github.com/labstack/gommon v0.4.2 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
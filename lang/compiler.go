@@ -0,0 +1,634 @@
+// Package lang compiles a small contract language to the VM bytecode.
+//
+// A contract is a list of statements executed on every call:
+//
+//	var owner;                      // storage variable, zero until assigned
+//	map balances;                   // storage mapping from integers to integers
+//
+//	let to = input(1);              // local variable living on the stack
+//	require(balances[caller()] >= input(2), "insufficient balance");
+//	balances[to] = balances[to] + input(2);
+//	if to == owner { ... } else { ... }
+//	while i < 10 { i = i + 1; }
+//	transfer(to, 100);              // sends coins from the contract
+//	emit Transfer(caller(), to);    // logs the event name hash and up to 3 topics
+//	return balances[to];
+//
+// All values are 256-bit unsigned integers, addresses included. Expressions support
+// + - * / == != < > <= >= && || ! with the usual precedence, && and || evaluate both operands.
+// Builtins are caller(), value(), selfbalance(), height(), timestamp(), balance(addr)
+// and input(n) which is the nth 32-byte word of the call input.
+package lang
+
+import (
+	"blockchain/asm"
+	"blockchain/core"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+var (
+	ErrSyntax     = errors.New("syntax error")
+	ErrUndeclared = errors.New("undeclared variable")
+	ErrRedeclared = errors.New("variable redeclared")
+)
+
+// maxEmitArgs is the number of event topics besides the event name
+const maxEmitArgs = core.MaxLogTopics - 1
+
+// maxMessageSize is the maximum size of the require message, which is packed on the stack
+const maxMessageSize = 64
+
+var wordModulus = new(big.Int).Lsh(big.NewInt(1), 256)
+
+// keywords can't be used as variable names
+var keywords = map[string]bool{
+	"var": true, "map": true, "let": true, "if": true, "else": true, "while": true,
+	"require": true, "transfer": true, "emit": true, "return": true,
+	"caller": true, "value": true, "selfbalance": true, "height": true, "timestamp": true,
+	"balance": true, "input": true,
+}
+
+// builtins without arguments and their instructions
+var builtins = map[string]core.Instruction{
+	"caller":      core.InstrCaller,
+	"value":       core.InstrCallValue,
+	"selfbalance": core.InstrSelfBalance,
+	"height":      core.InstrBlockHeight,
+	"timestamp":   core.InstrTimestamp,
+}
+
+type variableKind int
+
+const (
+	variableLocal variableKind = iota
+	variableStorage
+	variableMap
+)
+
+type variable struct {
+	kind variableKind
+	// slot is the position of the local variable on the stack counting from the bottom
+	slot int
+	// key is the storage key of the variable or the base key of the mapping
+	key *big.Int
+}
+
+type compiler struct {
+	tokens []token
+	pos    int
+	code   strings.Builder
+	// depth is the number of items on the stack at the current instruction
+	depth int
+	// scopes of variables, the first one holds storage variables
+	scopes []map[string]*variable
+	labels int
+}
+
+// Compile compiles the contract source to bytecode
+func Compile(src string) ([]byte, error) {
+	source, err := CompileAsm(src)
+	if err != nil {
+		return nil, err
+	}
+	return asm.Assemble(source)
+}
+
+// CompileAsm compiles the contract source to assembly
+func CompileAsm(src string) (string, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return "", err
+	}
+
+	c := &compiler{
+		tokens: tokens,
+		scopes: []map[string]*variable{{}, {}},
+	}
+	for c.peek().kind != tokenEOF {
+		if err := c.statement(); err != nil {
+			return "", err
+		}
+	}
+	return c.code.String(), nil
+}
+
+// Input encodes the arguments as the call input, each argument is a 32-byte word
+func Input(args ...*big.Int) []byte {
+	input := make([]byte, 0, len(args)*32)
+	for _, arg := range args {
+		word := new(big.Int).Mod(arg, wordModulus)
+		input = append(input, word.FillBytes(make([]byte, 32))...)
+	}
+	return input
+}
+
+func (c *compiler) peek() token {
+	return c.tokens[c.pos]
+}
+
+func (c *compiler) next() token {
+	tok := c.tokens[c.pos]
+	if tok.kind != tokenEOF {
+		c.pos++
+	}
+	return tok
+}
+
+// accept consumes the next token if it is the given punctuation or keyword
+func (c *compiler) accept(text string) bool {
+	tok := c.peek()
+	if (tok.kind == tokenPunct || tok.kind == tokenIdent) && tok.text == text {
+		c.pos++
+		return true
+	}
+	return false
+}
+
+func (c *compiler) expect(text string) error {
+	if !c.accept(text) {
+		return c.errorf("%w: expected (%s), got (%s)", ErrSyntax, text, c.peek())
+	}
+	return nil
+}
+
+func (c *compiler) expectIdent() (string, error) {
+	tok := c.peek()
+	if tok.kind != tokenIdent || keywords[tok.text] {
+		return "", c.errorf("%w: expected name, got (%s)", ErrSyntax, tok)
+	}
+	c.pos++
+	return tok.text, nil
+}
+
+// errorf returns the error at the line of the current token
+func (c *compiler) errorf(format string, args ...any) error {
+	return fmt.Errorf("line (%d): "+format, append([]any{c.peek().line}, args...)...)
+}
+
+// emit appends the instruction changing the stack depth by delta
+func (c *compiler) emit(delta int, format string, args ...any) {
+	c.code.WriteString("\t" + fmt.Sprintf(format, args...) + "\n")
+	c.depth += delta
+}
+
+func (c *compiler) push(value *big.Int) {
+	c.emit(1, "PUSH 0x%x", value)
+}
+
+func (c *compiler) newLabel(name string) string {
+	c.labels++
+	return fmt.Sprintf("%s_%d", name, c.labels)
+}
+
+// label marks the jump destination
+func (c *compiler) label(name string) {
+	c.code.WriteString(name + ":\tJUMPDEST\n")
+}
+
+func (c *compiler) lookup(name string) *variable {
+	for i := len(c.scopes) - 1; i >= 0; i-- {
+		if v, ok := c.scopes[i][name]; ok {
+			return v
+		}
+	}
+	return nil
+}
+
+func (c *compiler) declare(name string, scope map[string]*variable, v *variable) error {
+	if c.lookup(name) != nil {
+		return c.errorf("%w (%s)", ErrRedeclared, name)
+	}
+	scope[name] = v
+	return nil
+}
+
+func (c *compiler) statement() error {
+	tok := c.peek()
+	if tok.kind != tokenIdent {
+		return c.errorf("%w: unexpected (%s)", ErrSyntax, tok)
+	}
+
+	switch tok.text {
+	case "var", "map":
+		return c.storageDeclaration()
+	case "let":
+		return c.letStatement()
+	case "if":
+		c.next()
+		return c.ifStatement()
+	case "while":
+		return c.whileStatement()
+	case "require":
+		return c.requireStatement()
+	case "transfer":
+		return c.transferStatement()
+	case "emit":
+		return c.emitStatement()
+	case "return":
+		c.next()
+		if err := c.expression(); err != nil {
+			return err
+		}
+		c.emit(-1, "RETURN")
+		return c.expect(";")
+	default:
+		return c.assignment()
+	}
+}
+
+// storageDeclaration declares the storage variable keyed by the hash of its name
+func (c *compiler) storageDeclaration() error {
+	kind := variableStorage
+	if c.next().text == "map" {
+		kind = variableMap
+	}
+	if len(c.scopes) > 2 {
+		return c.errorf("%w: storage variables are declared at the top level", ErrSyntax)
+	}
+
+	name, err := c.expectIdent()
+	if err != nil {
+		return err
+	}
+	hash := sha256.Sum256([]byte(name))
+	v := &variable{kind: kind, key: new(big.Int).SetBytes(hash[:])}
+	if err := c.declare(name, c.scopes[0], v); err != nil {
+		return err
+	}
+	return c.expect(";")
+}
+
+func (c *compiler) letStatement() error {
+	c.next()
+	name, err := c.expectIdent()
+	if err != nil {
+		return err
+	}
+	if err := c.expect("="); err != nil {
+		return err
+	}
+	if err := c.expression(); err != nil {
+		return err
+	}
+	v := &variable{kind: variableLocal, slot: c.depth}
+	if err := c.declare(name, c.scopes[len(c.scopes)-1], v); err != nil {
+		return err
+	}
+	return c.expect(";")
+}
+
+func (c *compiler) assignment() error {
+	name, err := c.expectIdent()
+	if err != nil {
+		return err
+	}
+	v := c.lookup(name)
+	if v == nil {
+		return c.errorf("%w (%s)", ErrUndeclared, name)
+	}
+
+	if v.kind == variableMap {
+		if err := c.mapKey(v); err != nil {
+			return err
+		}
+	}
+	if err := c.expect("="); err != nil {
+		return err
+	}
+	if err := c.expression(); err != nil {
+		return err
+	}
+
+	switch v.kind {
+	case variableLocal:
+		// replaces the local variable with the value on the top of the stack
+		c.emit(1, "PUSH %d", c.depth-v.slot)
+		c.emit(-1, "SWAP")
+		c.emit(-1, "POP")
+	case variableStorage:
+		c.push(v.key)
+		c.emit(-2, "SSTORE")
+	case variableMap:
+		c.emit(1, "PUSH 1")
+		c.emit(-1, "SWAP")
+		c.emit(-2, "SSTORE")
+	}
+	return c.expect(";")
+}
+
+// mapKey pushes the storage key of the mapping item, which is the hash of the base key and the key,
+// so items can't alias variables or items of other mappings
+func (c *compiler) mapKey(v *variable) error {
+	if err := c.expect("["); err != nil {
+		return err
+	}
+	c.push(v.key)
+	if err := c.expression(); err != nil {
+		return err
+	}
+	c.emit(-1, "HASH")
+	return c.expect("]")
+}
+
+// block compiles statements in braces, local variables of the block are popped at its end
+func (c *compiler) block() error {
+	if err := c.expect("{"); err != nil {
+		return err
+	}
+	scope := make(map[string]*variable)
+	c.scopes = append(c.scopes, scope)
+	for !c.accept("}") {
+		if c.peek().kind == tokenEOF {
+			return c.errorf("%w: expected (}), got (%s)", ErrSyntax, c.peek())
+		}
+		if err := c.statement(); err != nil {
+			return err
+		}
+	}
+	c.scopes = c.scopes[:len(c.scopes)-1]
+
+	for range scope {
+		c.emit(-1, "POP")
+	}
+	return nil
+}
+
+// condition jumps to the label if the expression is zero
+func (c *compiler) condition(label string) error {
+	if err := c.expression(); err != nil {
+		return err
+	}
+	c.emit(0, "NOT")
+	c.emit(1, "PUSH %s", label)
+	c.emit(-2, "JUMPI")
+	return nil
+}
+
+func (c *compiler) ifStatement() error {
+	elseLabel, endLabel := c.newLabel("else"), c.newLabel("end")
+	if err := c.condition(elseLabel); err != nil {
+		return err
+	}
+	if err := c.block(); err != nil {
+		return err
+	}
+
+	if !c.accept("else") {
+		c.label(elseLabel)
+		return nil
+	}
+	c.emit(1, "PUSH %s", endLabel)
+	c.emit(-1, "JUMP")
+	c.label(elseLabel)
+	var err error
+	if c.accept("if") {
+		err = c.ifStatement()
+	} else {
+		err = c.block()
+	}
+	if err != nil {
+		return err
+	}
+	c.label(endLabel)
+	return nil
+}
+
+func (c *compiler) whileStatement() error {
+	c.next()
+	startLabel, endLabel := c.newLabel("while"), c.newLabel("end")
+	c.label(startLabel)
+	if err := c.condition(endLabel); err != nil {
+		return err
+	}
+	if err := c.block(); err != nil {
+		return err
+	}
+	c.emit(1, "PUSH %s", startLabel)
+	c.emit(-1, "JUMP")
+	c.label(endLabel)
+	return nil
+}
+
+// requireStatement reverts with the optional message if the condition is zero
+func (c *compiler) requireStatement() error {
+	c.next()
+	if err := c.expect("("); err != nil {
+		return err
+	}
+	okLabel := c.newLabel("ok")
+	if err := c.expression(); err != nil {
+		return err
+	}
+	c.emit(1, "PUSH %s", okLabel)
+	c.emit(-2, "JUMPI")
+
+	var message string
+	if c.accept(",") {
+		tok := c.next()
+		if tok.kind != tokenString {
+			return c.errorf("%w: expected message, got (%s)", ErrSyntax, tok)
+		}
+		if len(tok.text) > maxMessageSize {
+			return c.errorf("%w: message is longer than (%d) bytes", ErrSyntax, maxMessageSize)
+		}
+		message = tok.text
+	}
+	for _, char := range []byte(message) {
+		c.emit(1, "PUSHBYTE 0x%02x", char)
+	}
+	c.emit(1, "PUSH %d", len(message))
+	c.emit(-len(message), "PACK")
+	c.emit(-1, "REVERT")
+	c.label(okLabel)
+
+	if err := c.expect(")"); err != nil {
+		return err
+	}
+	return c.expect(";")
+}
+
+func (c *compiler) transferStatement() error {
+	c.next()
+	args, err := c.arguments()
+	if err != nil {
+		return err
+	}
+	if args != 2 {
+		return c.errorf("%w: transfer takes (2) arguments, got (%d)", ErrSyntax, args)
+	}
+	c.emit(-2, "TRANSFER")
+	return c.expect(";")
+}
+
+// emitStatement logs the event with the hash of its name as the first topic and empty data
+func (c *compiler) emitStatement() error {
+	c.next()
+	name, err := c.expectIdent()
+	if err != nil {
+		return err
+	}
+
+	c.emit(1, "PUSH 0")
+	c.emit(0, "PACK")
+	hash := sha256.Sum256([]byte(name))
+	c.push(new(big.Int).SetBytes(hash[:]))
+	args, err := c.arguments()
+	if err != nil {
+		return err
+	}
+	if args > maxEmitArgs {
+		return c.errorf("%w: event takes at most (%d) arguments, got (%d)", ErrSyntax, maxEmitArgs, args)
+	}
+	c.emit(1, "PUSH %d", args+1)
+	c.emit(-(args + 3), "LOG")
+	return c.expect(";")
+}
+
+// arguments pushes the arguments in parentheses and returns their number
+func (c *compiler) arguments() (int, error) {
+	if err := c.expect("("); err != nil {
+		return 0, err
+	}
+	var n int
+	for !c.accept(")") {
+		if n > 0 {
+			if err := c.expect(","); err != nil {
+				return 0, err
+			}
+		}
+		if err := c.expression(); err != nil {
+			return 0, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// binaryOperators by precedence level from the lowest one
+var binaryOperators = []map[string][]string{
+	{"||": {"OR"}},
+	{"&&": {"AND"}},
+	{"==": {"EQ"}, "!=": {"EQ", "NOT"}, "<": {"LT"}, ">": {"GT"}, "<=": {"GT", "NOT"}, ">=": {"LT", "NOT"}},
+	{"+": {"ADD"}, "-": {"SUB"}},
+	{"*": {"MUL"}, "/": {"DIV"}},
+}
+
+func (c *compiler) expression() error {
+	return c.binary(0)
+}
+
+func (c *compiler) binary(level int) error {
+	if level == len(binaryOperators) {
+		return c.unary()
+	}
+	if err := c.binary(level + 1); err != nil {
+		return err
+	}
+	for {
+		tok := c.peek()
+		instrs, ok := binaryOperators[level][tok.text]
+		if !ok || tok.kind != tokenPunct {
+			return nil
+		}
+		c.next()
+		if err := c.binary(level + 1); err != nil {
+			return err
+		}
+		c.emit(-1, instrs[0])
+		for _, instr := range instrs[1:] {
+			c.emit(0, instr)
+		}
+	}
+}
+
+func (c *compiler) unary() error {
+	switch {
+	case c.accept("!"):
+		if err := c.unary(); err != nil {
+			return err
+		}
+		c.emit(0, "NOT")
+	case c.accept("-"):
+		c.emit(1, "PUSH 0")
+		if err := c.unary(); err != nil {
+			return err
+		}
+		c.emit(-1, "SUB")
+	default:
+		return c.primary()
+	}
+	return nil
+}
+
+func (c *compiler) primary() error {
+	tok := c.next()
+	switch tok.kind {
+	case tokenNumber:
+		value, ok := new(big.Int).SetString(tok.text, 0)
+		if !ok || value.Cmp(wordModulus) >= 0 {
+			return c.errorf("%w: invalid number (%s)", ErrSyntax, tok)
+		}
+		c.push(value)
+		return nil
+	case tokenPunct:
+		if tok.text != "(" {
+			break
+		}
+		if err := c.expression(); err != nil {
+			return err
+		}
+		return c.expect(")")
+	case tokenIdent:
+		return c.identifier(tok.text)
+	}
+	return c.errorf("%w: unexpected (%s)", ErrSyntax, tok)
+}
+
+func (c *compiler) identifier(name string) error {
+	if instr, ok := builtins[name]; ok {
+		if err := c.expect("("); err != nil {
+			return err
+		}
+		c.emit(1, "%s", instr)
+		return c.expect(")")
+	}
+
+	switch name {
+	case "balance", "input":
+		if err := c.expect("("); err != nil {
+			return err
+		}
+		if err := c.expression(); err != nil {
+			return err
+		}
+		if name == "balance" {
+			c.emit(0, "BALANCE")
+		} else {
+			c.emit(0, "INPUT")
+		}
+		return c.expect(")")
+	}
+
+	v := c.lookup(name)
+	if v == nil {
+		return c.errorf("%w (%s)", ErrUndeclared, name)
+	}
+	switch v.kind {
+	case variableLocal:
+		c.emit(1, "PUSH %d", c.depth-v.slot+1)
+		c.emit(0, "DUP")
+	case variableStorage:
+		c.push(v.key)
+		c.emit(0, "SLOAD")
+	case variableMap:
+		if err := c.mapKey(v); err != nil {
+			return err
+		}
+		c.emit(0, "SLOAD")
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+package lang
+
+import (
+	"blockchain/core"
+	"blockchain/types"
+	"crypto/sha256"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"testing"
+)
+
+const tokenContract = `
+// minimal token, the first caller becomes the owner
+var owner;
+var supply;
+map balances;
+
+if owner == 0 {
+	owner = caller();
+}
+
+let method = input(0);
+if method == 1 {
+	// mint(to, amount)
+	require(caller() == owner, "only owner");
+	let to = input(1);
+	balances[to] = balances[to] + input(2);
+	supply = supply + input(2);
+	emit Mint(to);
+} else if method == 2 {
+	// transfer(to, amount)
+	let to = input(1);
+	let amount = input(2);
+	require(balances[caller()] >= amount, "insufficient balance");
+	balances[caller()] = balances[caller()] - amount;
+	balances[to] = balances[to] + amount;
+	emit Transfer(caller(), to);
+} else if method == 3 {
+	// balanceOf(account)
+	return balances[input(1)];
+} else {
+	return supply;
+}
+`
+
+const escrowContract = `
+// holds the deposit of the buyer until the buyer releases it to the seller
+// or the seller refunds it
+var buyer;
+var seller;
+var amount;
+
+let method = input(0);
+if method == 1 {
+	// deposit(seller)
+	require(amount == 0, "already deposited");
+	require(value() > 0);
+	buyer = caller();
+	seller = input(1);
+	amount = value();
+} else {
+	require(amount > 0, "nothing deposited");
+	let to = seller;
+	if method == 2 {
+		require(caller() == buyer, "only buyer");
+	} else {
+		require(caller() == seller, "only seller");
+		to = buyer;
+	}
+	amount = 0;
+	transfer(to, selfbalance());
+}
+`
+
+// contract runs the compiled code with the shared state
+type contract struct {
+	t        *testing.T
+	code     []byte
+	address  types.Address
+	state    *core.State
+	accounts *core.AccountsState
+}
+
+func newContract(t *testing.T, src string) *contract {
+	code, err := Compile(src)
+	assert.Nil(t, err)
+	return &contract{
+		t:        t,
+		code:     code,
+		address:  types.Address{0xc0},
+		state:    core.NewState(),
+		accounts: core.NewAccountsState(),
+	}
+}
+
+func (c *contract) call(caller types.Address, value int64, args ...int64) (*core.VM, error) {
+	input := make([]*big.Int, len(args))
+	for i, arg := range args {
+		input[i] = big.NewInt(arg)
+	}
+	if value > 0 {
+		assert.Nil(c.t, c.accounts.Transfer(caller, c.address, big.NewInt(value)))
+	}
+
+	ctx := &core.Context{
+		Caller:  caller,
+		Address: c.address,
+		Value:   big.NewInt(value),
+		Input:   Input(input...),
+	}
+	vm := core.NewVM(ctx, c.code, c.state, c.accounts, core.DefaultGasLimit)
+	return vm, vm.Run()
+}
+
+func (c *contract) result(caller types.Address, args ...int64) int64 {
+	vm, err := c.call(caller, 0, args...)
+	assert.Nil(c.t, err)
+	return new(big.Int).SetBytes(vm.ReturnData()).Int64()
+}
+
+func address(addr types.Address) int64 {
+	return new(big.Int).SetBytes(addr[:]).Int64()
+}
+
+func TestCompile_Token(t *testing.T) {
+	token := newContract(t, tokenContract)
+	owner, alice, bob := types.Address{1}, types.Address{19: 2}, types.Address{19: 3}
+
+	_, err := token.call(owner, 0, 1, address(alice), 100)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(100), token.result(owner, 3, address(alice)))
+	assert.Equal(t, int64(100), token.result(owner, 4))
+
+	_, err = token.call(alice, 0, 1, address(alice), 100)
+	assert.ErrorIs(t, err, core.ErrReverted)
+
+	vm, err := token.call(alice, 0, 2, address(bob), 30)
+	assert.Nil(t, err)
+	assert.Len(t, vm.Logs(), 1)
+	assert.Len(t, vm.Logs()[0].Topics, 3)
+	assert.Equal(t, int64(70), token.result(owner, 3, address(alice)))
+	assert.Equal(t, int64(30), token.result(owner, 3, address(bob)))
+
+	vm, err = token.call(bob, 0, 2, address(alice), 31)
+	assert.ErrorIs(t, err, core.ErrReverted)
+	assert.Equal(t, []byte("insufficient balance"), vm.ReturnData())
+	assert.Equal(t, int64(30), token.result(owner, 3, address(bob)))
+}
+
+func TestCompile_Escrow(t *testing.T) {
+	escrow := newContract(t, escrowContract)
+	buyer, seller := types.Address{19: 1}, types.Address{19: 2}
+	escrow.accounts.CreateAccount(buyer, big.NewInt(1_000))
+
+	_, err := escrow.call(buyer, 300, 1, address(seller))
+	assert.Nil(t, err)
+
+	vm, err := escrow.call(seller, 0, 2)
+	assert.ErrorIs(t, err, core.ErrReverted)
+	assert.Equal(t, []byte("only buyer"), vm.ReturnData())
+
+	_, err = escrow.call(buyer, 0, 2)
+	assert.Nil(t, err)
+	balance, _ := escrow.accounts.GetBalance(seller)
+	assert.Equal(t, big.NewInt(300), balance)
+
+	_, err = escrow.call(buyer, 0, 2)
+	assert.ErrorIs(t, err, core.ErrReverted)
+
+	_, err = escrow.call(buyer, 200, 1, address(seller))
+	assert.Nil(t, err)
+	_, err = escrow.call(seller, 0, 3)
+	assert.Nil(t, err)
+	balance, _ = escrow.accounts.GetBalance(buyer)
+	assert.Equal(t, big.NewInt(700), balance)
+}
+
+func TestCompile_Expressions(t *testing.T) {
+	tests := []struct {
+		src    string
+		result int64
+	}{
+		{"return 1 + 2 * 3;", 7},
+		{"return (1 + 2) * 3;", 9},
+		{"return 10 - 4 - 3;", 3},
+		{"return 7 / 2;", 3},
+		{"return 1 < 2 && 2 <= 2 && 3 >= 4 == 0;", 1},
+		{"return !(1 == 1) || 2 != 2;", 0},
+		{"return -1 + 2;", 1},
+		{"let i = 0; let sum = 0; while i < 5 { i = i + 1; sum = sum + i; } return sum;", 15},
+		{"let x = 3; if x > 5 { return 1; } else if x > 2 { return 2; } return 3;", 2},
+		{"let x = 1; if x == 1 { let y = 5; x = y + 1; } let z = 10; return x + z;", 16},
+		{"var x; x = 4; x = x * x; return x;", 16},
+		{"map m; m[1] = 2; m[2] = m[1] + 1; return m[2] * 10 + m[3];", 30},
+	}
+
+	for _, test := range tests {
+		vm, err := newContract(t, test.src).call(types.Address{}, 0)
+		assert.Nil(t, err, test.src)
+		assert.Equal(t, int64(test.result), new(big.Int).SetBytes(vm.ReturnData()).Int64(), test.src)
+	}
+}
+
+func TestCompile_Errors(t *testing.T) {
+	tests := []struct {
+		src string
+		err error
+	}{
+		{"return x;", ErrUndeclared},
+		{"let x = 1; let x = 2;", ErrRedeclared},
+		{"var x; map x;", ErrRedeclared},
+		{"if 1 { var x; }", ErrSyntax},
+		{"let var = 1;", ErrSyntax},
+		{"return 1", ErrSyntax},
+		{"if 1 { return 1;", ErrSyntax},
+		{"emit E(1, 2, 3, 4);", ErrSyntax},
+		{"transfer(1);", ErrSyntax},
+		{"require(1, 2);", ErrSyntax},
+		{"return 1 @ 2;", ErrSyntax},
+		{`require(1, "unterminated);`, ErrSyntax},
+	}
+
+	for _, test := range tests {
+		_, err := Compile(test.src)
+		assert.ErrorIs(t, err, test.err, test.src)
+	}
+
+	_, err := Compile("let x = 1;\n\nreturn y;")
+	assert.ErrorContains(t, err, "line (3)")
+}
+
+func TestCompile_MapKeyDoesntAliasVariables(t *testing.T) {
+	token := newContract(t, tokenContract)
+	owner, alice := types.Address{1}, types.Address{19: 2}
+	_, err := token.call(owner, 0, 1, address(alice), 1)
+	assert.Nil(t, err)
+
+	// offset by the base key of balances, the key used to land on the slot of owner
+	ownerKey, balancesKey := sha256.Sum256([]byte("owner")), sha256.Sum256([]byte("balances"))
+	to := new(big.Int).Sub(new(big.Int).SetBytes(ownerKey[:]), new(big.Int).SetBytes(balancesKey[:]))
+	to.Mod(to, new(big.Int).Lsh(big.NewInt(1), 256))
+	ctx := &core.Context{
+		Caller:  alice,
+		Address: token.address,
+		Input:   Input(big.NewInt(2), to, big.NewInt(1)),
+	}
+	assert.Nil(t, core.NewVM(ctx, token.code, token.state, token.accounts, core.DefaultGasLimit).Run())
+
+	// the owner can still mint
+	_, err = token.call(owner, 0, 1, address(alice), 1)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), token.result(owner, 3, address(alice)))
+	assert.Equal(t, int64(2), token.result(owner, 4))
+}
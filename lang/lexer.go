@@ -0,0 +1,108 @@
+package lang
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenNumber
+	tokenString
+	tokenPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	line int
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of file"
+	}
+	return t.text
+}
+
+// puncts are operators and delimiters, longer ones go first
+var puncts = []string{
+	"==", "!=", "<=", ">=", "&&", "||",
+	"+", "-", "*", "/", "<", ">", "!", "=",
+	"(", ")", "{", "}", "[", "]", ",", ";",
+}
+
+// tokenize splits the source into tokens, "//" starts a comment
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+	line := 1
+	for pos := 0; pos < len(src); {
+		c := src[pos]
+		switch {
+		case c == '\n':
+			line++
+			pos++
+		case c == ' ' || c == '\t' || c == '\r':
+			pos++
+		case strings.HasPrefix(src[pos:], "//"):
+			for pos < len(src) && src[pos] != '\n' {
+				pos++
+			}
+		case isLetter(c):
+			start := pos
+			for pos < len(src) && (isLetter(src[pos]) || isDigit(src[pos])) {
+				pos++
+			}
+			tokens = append(tokens, token{tokenIdent, src[start:pos], line})
+		case isDigit(c):
+			start := pos
+			for pos < len(src) && (isLetter(src[pos]) || isDigit(src[pos])) {
+				pos++
+			}
+			tokens = append(tokens, token{tokenNumber, src[start:pos], line})
+		case c == '"':
+			end := pos + 1
+			for end < len(src) && src[end] != '"' && src[end] != '\n' {
+				if src[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(src) || src[end] != '"' {
+				return nil, fmt.Errorf("line (%d): %w: unterminated string", line, ErrSyntax)
+			}
+			str, err := strconv.Unquote(src[pos : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("line (%d): %w: invalid string (%s)", line, ErrSyntax, src[pos:end+1])
+			}
+			tokens = append(tokens, token{tokenString, str, line})
+			pos = end + 1
+		default:
+			punct := ""
+			for _, p := range puncts {
+				if strings.HasPrefix(src[pos:], p) {
+					punct = p
+					break
+				}
+			}
+			if punct == "" {
+				return nil, fmt.Errorf("line (%d): %w: unexpected character (%c)", line, ErrSyntax, c)
+			}
+			tokens = append(tokens, token{tokenPunct, punct, line})
+			pos += len(punct)
+		}
+	}
+	return append(tokens, token{tokenEOF, "", line}), nil
+}
+
+func isLetter(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
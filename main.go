@@ -13,33 +13,41 @@ import (
 	"math/big"
 	"net"
 	"net/http"
+	"path/filepath"
+	"strings"
 	"time"
 )
 
 func main() {
 	privateKey := crypto.GeneratePrivateKey()
-	localNode := makeServer(":3000", ":8000", privateKey, nil)
+	// the validator key is funded in the genesis block, so it can send transactions
+	genesis := core.DefaultGenesisConfig()
+	genesis.Alloc = []core.GenesisAccount{{
+		Address: privateKey.PublicKey().Address(),
+		Balance: big.NewInt(1_000_000_000_000),
+	}}
+
+	localNode := makeServer(":3000", ":8000", privateKey, nil, genesis)
 	go localNode.Start()
 
 	time.Sleep(2 * time.Second)
-	remoteNode1 := makeServer(":3001", ":8001", nil, []string{":3000"})
+	remoteNode1 := makeServer(":3001", ":8001", nil, []string{":3000"}, genesis)
 	go remoteNode1.Start()
 
-	remoteNode2 := makeServer(":3002", ":8002", nil, []string{":3000"})
+	remoteNode2 := makeServer(":3002", ":8002", nil, []string{":3000"}, genesis)
 	go remoteNode2.Start()
 
-	remoteNode3 := makeServer(":3003", ":8003", nil, []string{":3000"})
+	remoteNode3 := makeServer(":3003", ":8003", nil, []string{":3000"}, genesis)
 	time.Sleep(12 * time.Second)
 	go remoteNode3.Start()
 
 	// causes EOF error because connection is closed after sending a tx
-	//err := sendTransactionViaTCP(":3000")
+	//err := sendTransactionViaTCP(privateKey, ":3000")
 	//if err != nil {
 	//	fmt.Println(err)
 	//}
 
-	//collectionOwnerPrivateKey := crypto.GeneratePrivateKey()
-	//collHash, err := createCollection(collectionOwnerPrivateKey, "http://localhost:8000/transaction")
+	//collHash, err := createCollection(privateKey, "http://localhost:8000/transaction")
 	//if err != nil {
 	//	fmt.Println(err)
 	//}
@@ -47,7 +55,7 @@ func main() {
 
 	//go func() {
 	//	for range time.Tick(time.Second) {
-	//		err := mintNFT(collectionOwnerPrivateKey, collHash, "http://localhost:8000/transaction")
+	//		err := mintNFT(privateKey, collHash, "http://localhost:8000/transaction")
 	//		if err != nil {
 	//			fmt.Println(err)
 	//		}
@@ -61,12 +69,18 @@ func main() {
 	select {}
 }
 
-func makeServer(addr, apiAddr string, pk *crypto.PrivateKey, seedNodes []string) *network.Server {
+func makeServer(addr, apiAddr string, pk *crypto.PrivateKey, seedNodes []string, genesis *core.GenesisConfig) *network.Server {
+	store, err := core.NewFileStore(filepath.Join("data", strings.TrimPrefix(addr, ":")))
+	if err != nil {
+		log.Fatal(err)
+	}
 	opts := network.ServerOpts{
 		Addr:       addr,
 		APIAddr:    apiAddr,
 		PrivateKey: pk,
 		SeedNodes:  seedNodes,
+		Storage:    store,
+		Genesis:    genesis,
 	}
 	server, err := network.NewServer(opts)
 	if err != nil {
@@ -75,24 +89,25 @@ func makeServer(addr, apiAddr string, pk *crypto.PrivateKey, seedNodes []string)
 	return server
 }
 
-func sendTransactionViaTCP(addr string) error {
+func sendTransactionViaTCP(privateKey *crypto.PrivateKey, addr string) error {
 	conn, err := net.Dial("tcp", addr)
 	if err != nil {
 		return err
 	}
 
-	privateKey := crypto.GeneratePrivateKey()
 	ins := new(core.Instr)
 	ins.Add(2, 3).String("hey").Store().Get("hey")
 
-	tx := core.NewTransaction(ins.Bytes())
+	tx := core.NewTransaction(&core.Deploy{Code: ins.Bytes()})
+	tx.ChainID = core.DefaultChainID
+	tx.GasPrice = big.NewInt(core.DefaultMinGasPrice)
 	err = tx.Sign(privateKey)
 	if err != nil {
 		return err
 	}
 
 	buf := new(bytes.Buffer)
-	if err = tx.Encode(core.NewGobTransactionEncoder(buf)); err != nil {
+	if err = tx.Encode(core.NewBinaryTransactionEncoder(buf)); err != nil {
 		return err
 	}
 
@@ -106,19 +121,20 @@ func sendTransactionViaTCP(addr string) error {
 	return nil
 }
 
-func sendTransactionViaHTTP(addr string) error {
-	privateKey := crypto.GeneratePrivateKey()
+func sendTransactionViaHTTP(privateKey *crypto.PrivateKey, addr string) error {
 	ins := new(core.Instr)
 	ins.Add(6, 1).String("hey").Store().Get("hey")
 
-	tx := core.NewTransaction(ins.Bytes())
+	tx := core.NewTransaction(&core.Deploy{Code: ins.Bytes()})
+	tx.ChainID = core.DefaultChainID
+	tx.GasPrice = big.NewInt(core.DefaultMinGasPrice)
 	err := tx.Sign(privateKey)
 	if err != nil {
 		return err
 	}
 
 	buf := new(bytes.Buffer)
-	if err = tx.Encode(core.NewGobTransactionEncoder(buf)); err != nil {
+	if err = tx.Encode(core.NewBinaryTransactionEncoder(buf)); err != nil {
 		return err
 	}
 
@@ -144,16 +160,19 @@ func sendTransactionViaHTTP(addr string) error {
 
 func sendCoins(priv *crypto.PrivateKey, addr string) error {
 	toPrivateKey := crypto.GeneratePrivateKey()
-	tx := core.NewTransaction(nil)
-	tx.To = toPrivateKey.PublicKey()
-	tx.Value = big.NewInt(1_000_000)
+	tx := core.NewTransaction(&core.Transfer{
+		To:    toPrivateKey.PublicKey().Address(),
+		Value: big.NewInt(1_000_000),
+	})
+	tx.ChainID = core.DefaultChainID
+	tx.GasPrice = big.NewInt(core.DefaultMinGasPrice)
 
 	if err := tx.Sign(priv); err != nil {
 		return err
 	}
 
 	buf := new(bytes.Buffer)
-	if err := tx.Encode(core.NewGobTransactionEncoder(buf)); err != nil {
+	if err := tx.Encode(core.NewBinaryTransactionEncoder(buf)); err != nil {
 		return err
 	}
 
@@ -169,21 +188,20 @@ func sendCoins(priv *crypto.PrivateKey, addr string) error {
 	return err
 }
 
-func sendNFTTransactionViaHTTP(addr string) error {
-	privateKey := crypto.GeneratePrivateKey()
-
-	tx := core.NewTransaction(nil)
-	tx.Inner = core.Collection{
+func sendNFTTransactionViaHTTP(privateKey *crypto.PrivateKey, addr string) error {
+	tx := core.NewTransaction(&core.CreateCollection{
 		MetaData: []byte("Some stuff"),
 		Fee:      150,
-	}
+	})
+	tx.ChainID = core.DefaultChainID
+	tx.GasPrice = big.NewInt(core.DefaultMinGasPrice)
 
 	if err := tx.Sign(privateKey); err != nil {
 		return err
 	}
 
 	buf := new(bytes.Buffer)
-	if err := tx.Encode(core.NewGobTransactionEncoder(buf)); err != nil {
+	if err := tx.Encode(core.NewBinaryTransactionEncoder(buf)); err != nil {
 		return err
 	}
 
@@ -208,18 +226,19 @@ func sendNFTTransactionViaHTTP(addr string) error {
 }
 
 func createCollection(priv *crypto.PrivateKey, addr string) (types.Hash, error) {
-	tx := core.NewTransaction(nil)
-	tx.Inner = core.Collection{
+	tx := core.NewTransaction(&core.CreateCollection{
 		MetaData: []byte("Some stuff"),
 		Fee:      150,
-	}
+	})
+	tx.ChainID = core.DefaultChainID
+	tx.GasPrice = big.NewInt(core.DefaultMinGasPrice)
 
 	if err := tx.Sign(priv); err != nil {
 		return types.Hash{}, err
 	}
 
 	buf := new(bytes.Buffer)
-	if err := tx.Encode(core.NewGobTransactionEncoder(buf)); err != nil {
+	if err := tx.Encode(core.NewBinaryTransactionEncoder(buf)); err != nil {
 		return types.Hash{}, err
 	}
 
@@ -255,21 +274,26 @@ func mintNFT(priv *crypto.PrivateKey, coll types.Hash, addr string) error {
 		return err
 	}
 
-	tx := core.NewTransaction(nil)
-	tx.Inner = core.Mint{
-		MetaData:        metaBuf.Bytes(),
-		Fee:             150,
-		NFT:             utils.RandomHash(),
-		Collection:      coll,
-		CollectionOwner: priv.PublicKey(),
+	mint := &core.Mint{
+		MetaData:   metaBuf.Bytes(),
+		Fee:        150,
+		NFT:        utils.RandomHash(),
+		Collection: coll,
 	}
+	if err := mint.Sign(priv, core.DefaultChainID, priv.PublicKey().Address()); err != nil {
+		return err
+	}
+
+	tx := core.NewTransaction(mint)
+	tx.ChainID = core.DefaultChainID
+	tx.GasPrice = big.NewInt(core.DefaultMinGasPrice)
 
 	if err := tx.Sign(priv); err != nil {
 		return err
 	}
 
 	buf := new(bytes.Buffer)
-	if err := tx.Encode(core.NewGobTransactionEncoder(buf)); err != nil {
+	if err := tx.Encode(core.NewBinaryTransactionEncoder(buf)); err != nil {
 		return err
 	}
 
@@ -13,6 +13,15 @@ import (
 	"strconv"
 )
 
+// maxLogsBlockRange is the maximum number of blocks scanned by a logs query
+const maxLogsBlockRange = 10_000
+
+// default and maximum number of entries returned by a storage range query
+const (
+	defaultStorageLimit = 100
+	maxStorageLimit     = 1_000
+)
+
 type APIConfig struct {
 	ListenAddr string
 	Logger     *slog.Logger
@@ -43,7 +52,18 @@ func (a *API) Start() {
 
 	e.GET("/block/:id", a.handleGetBlock)
 	e.GET("/transaction/:hash", a.handleGetTransaction)
+	e.GET("/transaction/:hash/proof", a.handleGetTransactionProof)
+	e.GET("/transaction/:hash/trace", a.handleGetTransactionTrace)
 	e.POST("/transaction", a.handlePostTransaction)
+	e.POST("/simulate", a.handleSimulate)
+	e.GET("/receipt/:hash", a.handleGetReceipt)
+	e.GET("/account/:address/nonce", a.handleGetNonce)
+	e.GET("/account/:address/nfts", a.handleGetNFTsByOwner)
+	e.GET("/collection/:hash/nfts", a.handleGetNFTsByCollection)
+	e.GET("/nft/:hash/history", a.handleGetNFTHistory)
+	e.GET("/logs", a.handleGetLogs)
+	e.GET("/contract/:address/storage", a.handleGetStorageRange)
+	e.GET("/contract/:address/storage/:key", a.handleGetStorage)
 
 	go func() {
 		if err := e.Start(a.ListenAddr); err != nil {
@@ -91,6 +111,232 @@ func (a *API) handleGetTransaction(c echo.Context) error {
 	return c.JSON(http.StatusOK, ToTransactionRes(transaction))
 }
 
+func (a *API) handleGetTransactionProof(c echo.Context) error {
+	hashStr := c.Param("hash")
+	b, err := hex.DecodeString(hashStr)
+	if err != nil || len(b) != len(types.Hash{}) {
+		return c.JSON(http.StatusBadRequest, ErrorRes{"invalid transaction hash"})
+	}
+	hash := types.HashFromBytes(b)
+
+	block, proof, err := a.blockchain.GetTransactionProof(hash)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, ErrorRes{err.Error()})
+	}
+	return c.JSON(http.StatusOK, ToTransactionProofRes(hash, block, proof))
+}
+
+// handleGetTransactionTrace replays the transaction and returns every executed VM step
+func (a *API) handleGetTransactionTrace(c echo.Context) error {
+	hashStr := c.Param("hash")
+	b, err := hex.DecodeString(hashStr)
+	if err != nil || len(b) != len(types.Hash{}) {
+		return c.JSON(http.StatusBadRequest, ErrorRes{"invalid transaction hash"})
+	}
+	hash := types.HashFromBytes(b)
+
+	trace, err := a.blockchain.TraceTransaction(hash)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, ErrorRes{err.Error()})
+	}
+	return c.JSON(http.StatusOK, ToTraceRes(hash, trace))
+}
+
+func (a *API) handleGetReceipt(c echo.Context) error {
+	hashStr := c.Param("hash")
+	b, err := hex.DecodeString(hashStr)
+	if err != nil || len(b) != len(types.Hash{}) {
+		return c.JSON(http.StatusBadRequest, ErrorRes{"invalid transaction hash"})
+	}
+	hash := types.HashFromBytes(b)
+
+	receipt, err := a.blockchain.GetReceipt(hash)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, ErrorRes{err.Error()})
+	}
+	return c.JSON(http.StatusOK, ToReceiptRes(receipt))
+}
+
+func (a *API) handleGetNonce(c echo.Context) error {
+	b, err := hex.DecodeString(c.Param("address"))
+	if err != nil || len(b) != len(types.Address{}) {
+		return c.JSON(http.StatusBadRequest, ErrorRes{"invalid account address"})
+	}
+	addr := types.AddressFromBytes(b)
+
+	return c.JSON(http.StatusOK, NonceRes{
+		Address: addr.String(),
+		Nonce:   a.blockchain.GetNonce(addr),
+	})
+}
+
+// handleGetNFTsByOwner returns NFTs currently owned by the account
+func (a *API) handleGetNFTsByOwner(c echo.Context) error {
+	b, err := hex.DecodeString(c.Param("address"))
+	if err != nil || len(b) != len(types.Address{}) {
+		return c.JSON(http.StatusBadRequest, ErrorRes{"invalid account address"})
+	}
+	addr := types.AddressFromBytes(b)
+
+	return c.JSON(http.StatusOK, ToNFTsRes(a.blockchain.GetNFTsByOwner(addr)))
+}
+
+// handleGetNFTsByCollection returns NFTs of the collection which aren't burned
+func (a *API) handleGetNFTsByCollection(c echo.Context) error {
+	b, err := hex.DecodeString(c.Param("hash"))
+	if err != nil || len(b) != len(types.Hash{}) {
+		return c.JSON(http.StatusBadRequest, ErrorRes{"invalid collection hash"})
+	}
+	hash := types.HashFromBytes(b)
+
+	nfts, err := a.blockchain.GetNFTsByCollection(hash)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, ErrorRes{err.Error()})
+	}
+	return c.JSON(http.StatusOK, ToNFTsRes(nfts))
+}
+
+// handleGetNFTHistory returns the NFT with all its owners from the mint
+func (a *API) handleGetNFTHistory(c echo.Context) error {
+	b, err := hex.DecodeString(c.Param("hash"))
+	if err != nil || len(b) != len(types.Hash{}) {
+		return c.JSON(http.StatusBadRequest, ErrorRes{"invalid NFT hash"})
+	}
+	hash := types.HashFromBytes(b)
+
+	nft, err := a.blockchain.GetNFT(hash)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, ErrorRes{err.Error()})
+	}
+	return c.JSON(http.StatusOK, ToNFTHistoryRes(nft))
+}
+
+// defaultLogsFrom returns the first height of the largest allowed range of blocks ending at the height
+func defaultLogsFrom(to uint32) uint32 {
+	if to < maxLogsBlockRange {
+		return 0
+	}
+	return to - maxLogsBlockRange + 1
+}
+
+// handleGetLogs returns logs filtered by block range, contract address and topic,
+// all query parameters are optional, the range defaults to the latest maxLogsBlockRange blocks
+func (a *API) handleGetLogs(c echo.Context) error {
+	to := a.blockchain.Height()
+	if v := c.QueryParam("to"); v != "" {
+		height, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorRes{"invalid to height"})
+		}
+		to = uint32(height)
+	}
+	from := defaultLogsFrom(to)
+	if v := c.QueryParam("from"); v != "" {
+		height, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorRes{"invalid from height"})
+		}
+		from = uint32(height)
+	}
+	if to >= from && to-from >= maxLogsBlockRange {
+		return c.JSON(http.StatusBadRequest, ErrorRes{"block range is too large"})
+	}
+
+	filter := &core.LogFilter{}
+	if v := c.QueryParam("address"); v != "" {
+		b, err := hex.DecodeString(v)
+		if err != nil || len(b) != len(types.Address{}) {
+			return c.JSON(http.StatusBadRequest, ErrorRes{"invalid contract address"})
+		}
+		addr := types.AddressFromBytes(b)
+		filter.Address = &addr
+	}
+	if v := c.QueryParam("topic"); v != "" {
+		b, err := hex.DecodeString(v)
+		if err != nil || len(b) != len(types.Hash{}) {
+			return c.JSON(http.StatusBadRequest, ErrorRes{"invalid topic"})
+		}
+		topic := types.HashFromBytes(b)
+		filter.Topic = &topic
+	}
+
+	logs, err := a.blockchain.FilterLogs(from, to, filter)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorRes{err.Error()})
+	}
+
+	res := make([]*LogRes, len(logs))
+	for i, l := range logs {
+		res[i] = ToLogRes(l)
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// handleSimulate executes the transaction on the current state without persisting it,
+// deployments use the current nonce of the sender
+func (a *API) handleSimulate(c echo.Context) error {
+	var req SimulateReq
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorRes{"invalid simulation request"})
+	}
+	tx, err := req.ToTransaction()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorRes{err.Error()})
+	}
+	tx.ChainID = a.blockchain.ChainID()
+	tx.Nonce = a.blockchain.GetNonce(tx.From.Address())
+
+	result, err := a.blockchain.Simulate(tx)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorRes{err.Error()})
+	}
+	return c.JSON(http.StatusOK, ToSimulationRes(result))
+}
+
+// handleGetStorage returns the value of the contract storage key with its proof,
+// the value is empty if the key is absent
+func (a *API) handleGetStorage(c echo.Context) error {
+	b, err := hex.DecodeString(c.Param("address"))
+	if err != nil || len(b) != len(types.Address{}) {
+		return c.JSON(http.StatusBadRequest, ErrorRes{"invalid contract address"})
+	}
+	addr := types.AddressFromBytes(b)
+	key, err := hex.DecodeString(c.Param("key"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorRes{"invalid storage key"})
+	}
+
+	return c.JSON(http.StatusOK, ToStorageRes(addr, a.blockchain.GetStorage(addr, key)))
+}
+
+// handleGetStorageRange returns a page of the contract storage entries with proofs,
+// keys can be filtered by prefix and the next page starts from the key given by next of the previous page
+func (a *API) handleGetStorageRange(c echo.Context) error {
+	b, err := hex.DecodeString(c.Param("address"))
+	if err != nil || len(b) != len(types.Address{}) {
+		return c.JSON(http.StatusBadRequest, ErrorRes{"invalid contract address"})
+	}
+	addr := types.AddressFromBytes(b)
+
+	prefix, err := hex.DecodeString(c.QueryParam("prefix"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorRes{"invalid key prefix"})
+	}
+	start, err := hex.DecodeString(c.QueryParam("start"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorRes{"invalid start key"})
+	}
+	limit := defaultStorageLimit
+	if v := c.QueryParam("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > maxStorageLimit {
+			return c.JSON(http.StatusBadRequest, ErrorRes{"invalid limit"})
+		}
+	}
+
+	return c.JSON(http.StatusOK, ToStorageRes(addr, a.blockchain.GetStorageRange(addr, prefix, start, limit)))
+}
+
 func (a *API) handlePostTransaction(c echo.Context) error {
 	from, err := net.ResolveIPAddr("ip", c.Request().RemoteAddr)
 	if err != nil {
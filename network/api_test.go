@@ -0,0 +1,17 @@
+package network
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDefaultLogsFrom(t *testing.T) {
+	assert.Equal(t, uint32(0), defaultLogsFrom(0))
+	assert.Equal(t, uint32(0), defaultLogsFrom(maxLogsBlockRange-1))
+	assert.Equal(t, uint32(1), defaultLogsFrom(maxLogsBlockRange))
+
+	// the default range is never too large
+	for _, to := range []uint32{5, maxLogsBlockRange, 3 * maxLogsBlockRange} {
+		assert.Less(t, to-defaultLogsFrom(to), uint32(maxLogsBlockRange))
+	}
+}
@@ -1,9 +1,13 @@
 package network
 
 import (
+	"blockchain/asm"
 	"blockchain/core"
 	"blockchain/crypto"
+	"blockchain/types"
 	"encoding/hex"
+	"fmt"
+	"math/big"
 	"slices"
 )
 
@@ -14,6 +18,9 @@ type ErrorRes struct {
 type BlockRes struct {
 	Version          uint32       `json:"version"`
 	TransactionsHash string       `json:"transactions_hash"`
+	StateRoot        string       `json:"state_root"`
+	ReceiptsHash     string       `json:"receipts_hash"`
+	LogsBloom        string       `json:"logs_bloom"`
 	PrevHeaderHash   string       `json:"prev_header_hash"`
 	Height           uint32       `json:"height"`
 	Timestamp        int64        `json:"timestamp"`
@@ -27,6 +34,9 @@ func ToBlockRes(b *core.Block) *BlockRes {
 	blockRes := &BlockRes{
 		Version:          b.Version,
 		TransactionsHash: b.TransactionsHash.String(),
+		StateRoot:        b.StateRoot.String(),
+		ReceiptsHash:     b.ReceiptsHash.String(),
+		LogsBloom:        b.LogsBloom.String(),
 		PrevHeaderHash:   b.PrevHeaderHash.String(),
 		Height:           b.Height,
 		Timestamp:        b.Timestamp,
@@ -45,19 +55,421 @@ func ToBlockRes(b *core.Block) *BlockRes {
 }
 
 type TransactionRes struct {
-	Data      []byte       `json:"data"`
+	Type string `json:"type"`
+	// To is the recipient of the transfer or the called contract
+	To    string `json:"to,omitempty"`
+	Value string `json:"value,omitempty"`
+	// Data is the code of the deployment or the input of the call
+	Data []byte `json:"data,omitempty"`
+	// NFT is the minted, transferred or burned NFT
+	NFT       string       `json:"nft,omitempty"`
+	Code      []string     `json:"code,omitempty"`
+	ChainID   uint64       `json:"chain_id"`
 	From      string       `json:"from"`
+	Nonce     uint64       `json:"nonce"`
+	GasLimit  uint64       `json:"gas_limit"`
+	GasPrice  string       `json:"gas_price"`
 	Signature SignatureRes `json:"signature"`
 	Hash      string       `json:"hash"`
 }
 
 func ToTransactionRes(tx *core.Transaction) *TransactionRes {
-	return &TransactionRes{
-		Data:      tx.Data,
+	gasPrice := "0"
+	if tx.GasPrice != nil {
+		gasPrice = tx.GasPrice.String()
+	}
+
+	res := &TransactionRes{
+		Type:      tx.Type().String(),
+		ChainID:   tx.ChainID,
 		From:      hex.EncodeToString(tx.From),
+		Nonce:     tx.Nonce,
+		GasLimit:  tx.GasLimit,
+		GasPrice:  gasPrice,
 		Signature: ToSignatureRes(tx.Signature),
 		Hash:      tx.Hash(core.TransactionHasher{}).String(),
 	}
+
+	switch p := tx.Payload.(type) {
+	case *core.Transfer:
+		res.To = p.To.String()
+		res.Value = toValueRes(p.Value)
+	case *core.Deploy:
+		res.Value = toValueRes(p.Value)
+		res.Data = p.Code
+		res.Code = toCodeRes(p.Code)
+	case *core.Call:
+		res.To = p.Contract.String()
+		res.Value = toValueRes(p.Value)
+		res.Data = p.Input
+	case *core.Mint:
+		res.NFT = p.NFT.String()
+	case *core.TransferNFT:
+		res.To = p.To.String()
+		res.NFT = p.NFT.String()
+	case *core.BurnNFT:
+		res.NFT = p.NFT.String()
+	}
+
+	return res
+}
+
+func toValueRes(value *big.Int) string {
+	if value == nil {
+		return "0"
+	}
+	return value.String()
+}
+
+// toCodeRes disassembles the bytecode, one instruction prefixed with its offset per line
+func toCodeRes(code []byte) []string {
+	var lines []string
+	for _, instr := range asm.Disassemble(code) {
+		lines = append(lines, fmt.Sprintf("%04d: %s", instr.Offset, instr))
+	}
+	return lines
+}
+
+type TransactionProofRes struct {
+	TransactionHash  string   `json:"transaction_hash"`
+	BlockHeight      uint32   `json:"block_height"`
+	HeaderHash       string   `json:"header_hash"`
+	TransactionsHash string   `json:"transactions_hash"`
+	Index            int      `json:"index"`
+	Total            int      `json:"total"`
+	Hashes           []string `json:"hashes"`
+}
+
+func ToTransactionProofRes(hash types.Hash, b *core.Block, proof *core.MerkleProof) *TransactionProofRes {
+	hashes := make([]string, len(proof.Hashes))
+	for i, h := range proof.Hashes {
+		hashes[i] = h.String()
+	}
+
+	return &TransactionProofRes{
+		TransactionHash:  hash.String(),
+		BlockHeight:      b.Height,
+		HeaderHash:       b.HeaderHash(core.HeaderHasher{}).String(),
+		TransactionsHash: b.TransactionsHash.String(),
+		Index:            proof.Index,
+		Total:            proof.Total,
+		Hashes:           hashes,
+	}
+}
+
+type LogRes struct {
+	Address         string   `json:"address"`
+	Topics          []string `json:"topics"`
+	Data            []byte   `json:"data"`
+	BlockHeight     uint32   `json:"block_height"`
+	TransactionHash string   `json:"transaction_hash"`
+}
+
+func ToLogRes(l *core.Log) *LogRes {
+	topics := make([]string, len(l.Topics))
+	for i, topic := range l.Topics {
+		topics[i] = topic.String()
+	}
+
+	return &LogRes{
+		Address:         l.Address.String(),
+		Topics:          topics,
+		Data:            l.Data,
+		BlockHeight:     l.BlockHeight,
+		TransactionHash: l.TransactionHash.String(),
+	}
+}
+
+type StorageWriteRes struct {
+	Address string `json:"address"`
+	Key     string `json:"key"`
+	Value   string `json:"value"`
+}
+
+type TraceStepRes struct {
+	Pc      int                `json:"pc"`
+	Op      string             `json:"op"`
+	Gas     uint64             `json:"gas"`
+	GasCost uint64             `json:"gas_cost"`
+	Depth   int                `json:"depth"`
+	Stack   []string           `json:"stack"`
+	Storage []*StorageWriteRes `json:"storage,omitempty"`
+	Error   string             `json:"error,omitempty"`
+}
+
+type TraceRes struct {
+	TransactionHash string          `json:"transaction_hash"`
+	GasUsed         uint64          `json:"gas_used"`
+	ReturnData      string          `json:"return_data"`
+	Error           string          `json:"error,omitempty"`
+	Steps           []*TraceStepRes `json:"steps"`
+}
+
+func ToTraceRes(hash types.Hash, trace *core.Trace) *TraceRes {
+	steps := make([]*TraceStepRes, len(trace.Steps))
+	for i, step := range trace.Steps {
+		var writes []*StorageWriteRes
+		for _, w := range step.Writes {
+			writes = append(writes, &StorageWriteRes{
+				Address: w.Address.String(),
+				Key:     hex.EncodeToString(w.Key),
+				Value:   hex.EncodeToString(w.Value),
+			})
+		}
+		steps[i] = &TraceStepRes{
+			Pc:      step.Pc,
+			Op:      step.Op.String(),
+			Gas:     step.Gas,
+			GasCost: step.GasCost,
+			Depth:   step.Depth,
+			Stack:   step.Stack,
+			Storage: writes,
+			Error:   step.Error,
+		}
+	}
+
+	return &TraceRes{
+		TransactionHash: hash.String(),
+		GasUsed:         trace.GasUsed,
+		ReturnData:      hex.EncodeToString(trace.ReturnData),
+		Error:           trace.Error,
+		Steps:           steps,
+	}
+}
+
+type ReceiptRes struct {
+	TransactionHash string    `json:"transaction_hash"`
+	Status          string    `json:"status"`
+	Error           string    `json:"error,omitempty"`
+	GasUsed         uint64    `json:"gas_used"`
+	ContractAddress string    `json:"contract_address,omitempty"`
+	Logs            []*LogRes `json:"logs"`
+	BlockHeight     uint32    `json:"block_height"`
+	Index           uint32    `json:"index"`
+}
+
+func ToReceiptRes(r *core.Receipt) *ReceiptRes {
+	logs := make([]*LogRes, len(r.Logs))
+	for i, l := range r.Logs {
+		logs[i] = ToLogRes(l)
+	}
+
+	var contractAddress string
+	if !r.ContractAddress.IsZero() {
+		contractAddress = r.ContractAddress.String()
+	}
+
+	return &ReceiptRes{
+		TransactionHash: r.TransactionHash.String(),
+		Status:          r.Status.String(),
+		Error:           r.Error,
+		GasUsed:         r.GasUsed,
+		ContractAddress: contractAddress,
+		Logs:            logs,
+		BlockHeight:     r.BlockHeight,
+		Index:           r.Index,
+	}
+}
+
+// SimulateReq is a transaction to simulate, all fields are optional
+// and byte fields are hex encoded
+type SimulateReq struct {
+	// From is the public key of the sender
+	From string `json:"from"`
+	// To is the public key of the recipient or the address of the called contract
+	To       string `json:"to"`
+	Value    string `json:"value"`
+	Data     string `json:"data"`
+	GasLimit uint64 `json:"gas_limit"`
+	GasPrice string `json:"gas_price"`
+}
+
+// ToTransaction returns the unsigned transaction, the gas limit defaults to core.DefaultGasLimit
+// and is clamped to core.MaxGasLimit.
+// The transaction deploys the data if there is no recipient, calls the contract with the data
+// if the recipient is an address and transfers the value if it's a public key.
+func (r *SimulateReq) ToTransaction() (*core.Transaction, error) {
+	from, err := hex.DecodeString(r.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender (%s)", r.From)
+	}
+	to, err := hex.DecodeString(r.To)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipient (%s)", r.To)
+	}
+	data, err := hex.DecodeString(r.Data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data (%s)", r.Data)
+	}
+	var value *big.Int
+	if r.Value != "" {
+		v, ok := new(big.Int).SetString(r.Value, 10)
+		if !ok || v.Sign() < 0 {
+			return nil, fmt.Errorf("invalid value (%s)", r.Value)
+		}
+		value = v
+	}
+
+	var payload core.TxPayload
+	switch {
+	case len(to) == 0:
+		payload = &core.Deploy{Code: data, Value: value}
+	case len(to) == len(types.Address{}):
+		payload = &core.Call{Contract: types.AddressFromBytes(to), Input: data, Value: value}
+	case len(data) > 0:
+		return nil, fmt.Errorf("data can't be sent to account (%s)", r.To)
+	default:
+		payload = &core.Transfer{To: crypto.PublicKey(to).Address(), Value: value}
+	}
+
+	tx := core.NewTransaction(payload)
+	tx.From = from
+	if r.GasPrice != "" {
+		price, ok := new(big.Int).SetString(r.GasPrice, 10)
+		if !ok || price.Sign() < 0 {
+			return nil, fmt.Errorf("invalid gas price (%s)", r.GasPrice)
+		}
+		tx.GasPrice = price
+	}
+	if r.GasLimit > 0 {
+		tx.GasLimit = min(r.GasLimit, core.MaxGasLimit)
+	}
+	return tx, nil
+}
+
+type SimulationRes struct {
+	ReturnData      string    `json:"return_data"`
+	Logs            []*LogRes `json:"logs"`
+	GasUsed         uint64    `json:"gas_used"`
+	ContractAddress string    `json:"contract_address,omitempty"`
+	Error           string    `json:"error,omitempty"`
+}
+
+func ToSimulationRes(r *core.SimulationResult) *SimulationRes {
+	logs := make([]*LogRes, len(r.Logs))
+	for i, l := range r.Logs {
+		logs[i] = ToLogRes(l)
+	}
+
+	var contractAddress string
+	if !r.ContractAddress.IsZero() {
+		contractAddress = r.ContractAddress.String()
+	}
+
+	return &SimulationRes{
+		ReturnData:      hex.EncodeToString(r.ReturnData),
+		Logs:            logs,
+		GasUsed:         r.GasUsed,
+		ContractAddress: contractAddress,
+		Error:           r.Error,
+	}
+}
+
+type StorageEntryRes struct {
+	Key   string   `json:"key"`
+	Value string   `json:"value"`
+	Proof []string `json:"proof"`
+}
+
+// StorageRes holds contract storage entries, proofs are verified against contract_root
+// and the state root is the hash of accounts_root followed by contract_root and nfts_root
+type StorageRes struct {
+	Address      string             `json:"address"`
+	StateRoot    string             `json:"state_root"`
+	AccountsRoot string             `json:"accounts_root"`
+	ContractRoot string             `json:"contract_root"`
+	NFTsRoot     string             `json:"nfts_root"`
+	Entries      []*StorageEntryRes `json:"entries"`
+	Next         string             `json:"next,omitempty"`
+}
+
+func ToStorageRes(addr types.Address, q *core.StorageQuery) *StorageRes {
+	entries := make([]*StorageEntryRes, len(q.Entries))
+	for i, entry := range q.Entries {
+		proof := make([]string, len(entry.Proof))
+		for j, node := range entry.Proof {
+			proof[j] = hex.EncodeToString(node)
+		}
+		entries[i] = &StorageEntryRes{
+			Key:   hex.EncodeToString(entry.Key),
+			Value: hex.EncodeToString(entry.Value),
+			Proof: proof,
+		}
+	}
+
+	return &StorageRes{
+		Address:      addr.String(),
+		StateRoot:    q.StateRoot.String(),
+		AccountsRoot: q.AccountsRoot.String(),
+		ContractRoot: q.ContractRoot.String(),
+		NFTsRoot:     q.NFTsRoot.String(),
+		Entries:      entries,
+		Next:         hex.EncodeToString(q.Next),
+	}
+}
+
+type NFTRes struct {
+	Hash       string `json:"hash"`
+	Collection string `json:"collection"`
+	MetaData   []byte `json:"meta_data"`
+	// Owner is empty if the NFT is burned
+	Owner  string `json:"owner,omitempty"`
+	Burned bool   `json:"burned"`
+}
+
+func ToNFTRes(nft *core.NFT) *NFTRes {
+	res := &NFTRes{
+		Hash:       nft.Hash.String(),
+		Collection: nft.Collection.String(),
+		MetaData:   nft.MetaData,
+		Burned:     nft.Burned,
+	}
+	if !nft.Burned {
+		res.Owner = nft.Owner.String()
+	}
+	return res
+}
+
+func ToNFTsRes(nfts []*core.NFT) []*NFTRes {
+	res := make([]*NFTRes, len(nfts))
+	for i, nft := range nfts {
+		res[i] = ToNFTRes(nft)
+	}
+	return res
+}
+
+type NFTOwnershipRes struct {
+	// Owner is empty for the burn
+	Owner           string `json:"owner,omitempty"`
+	TransactionHash string `json:"transaction_hash"`
+	BlockHeight     uint32 `json:"block_height"`
+}
+
+type NFTHistoryRes struct {
+	*NFTRes
+	History []*NFTOwnershipRes `json:"history"`
+}
+
+func ToNFTHistoryRes(nft *core.NFT) *NFTHistoryRes {
+	history := make([]*NFTOwnershipRes, len(nft.History))
+	for i, o := range nft.History {
+		history[i] = &NFTOwnershipRes{
+			TransactionHash: o.TransactionHash.String(),
+			BlockHeight:     o.BlockHeight,
+		}
+		if !o.Owner.IsZero() {
+			history[i].Owner = o.Owner.String()
+		}
+	}
+	return &NFTHistoryRes{
+		NFTRes:  ToNFTRes(nft),
+		History: history,
+	}
+}
+
+type NonceRes struct {
+	Address string `json:"address"`
+	Nonce   uint64 `json:"nonce"`
 }
 
 type SignatureRes string
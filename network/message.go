@@ -2,13 +2,13 @@ package network
 
 import (
 	"blockchain/core"
-	"encoding/gob"
 	"io"
 )
 
 type Status struct {
 	// listen address of the server
 	Addr    string
+	ChainID uint64
 	Version uint32
 	Height  uint32
 }
@@ -21,32 +21,46 @@ func (s *Status) Decode(dec core.Decoder[*Status]) error {
 	return dec.Decode(s)
 }
 
-type GobStatusEncoder struct {
+type BinaryStatusEncoder struct {
 	w io.Writer
 }
 
-func NewGobStatusEncoder(w io.Writer) *GobStatusEncoder {
-	return &GobStatusEncoder{
+func NewBinaryStatusEncoder(w io.Writer) *BinaryStatusEncoder {
+	return &BinaryStatusEncoder{
 		w: w,
 	}
 }
 
-func (e *GobStatusEncoder) Encode(s *Status) error {
-	return gob.NewEncoder(e.w).Encode(s)
+func (e *BinaryStatusEncoder) Encode(s *Status) error {
+	w := core.NewBinaryWriter()
+	w.WriteVersion()
+	w.WriteString(s.Addr)
+	w.WriteUint64(s.ChainID)
+	w.WriteUint32(s.Version)
+	w.WriteUint32(s.Height)
+	_, err := e.w.Write(w.Bytes())
+	return err
 }
 
-type GobStatusDecoder struct {
+type BinaryStatusDecoder struct {
 	r io.Reader
 }
 
-func NewGobStatusDecoder(r io.Reader) *GobStatusDecoder {
-	return &GobStatusDecoder{
+func NewBinaryStatusDecoder(r io.Reader) *BinaryStatusDecoder {
+	return &BinaryStatusDecoder{
 		r: r,
 	}
 }
 
-func (d *GobStatusDecoder) Decode(s *Status) error {
-	return gob.NewDecoder(d.r).Decode(s)
+func (d *BinaryStatusDecoder) Decode(s *Status) error {
+	r := core.NewBinaryReader(d.r)
+	r.ReadVersion()
+	s.Addr = r.ReadString()
+	s.ChainID = r.ReadUint64()
+	s.Version = r.ReadUint32()
+	s.Height = r.ReadUint32()
+	r.ReadEOF()
+	return r.Err()
 }
 
 type EmptyMessage struct {
@@ -61,32 +75,40 @@ func (m *EmptyMessage) Decode(dec core.Decoder[*EmptyMessage]) error {
 	return dec.Decode(m)
 }
 
-type GobEmptyMessageEncoder struct {
+type BinaryEmptyMessageEncoder struct {
 	w io.Writer
 }
 
-func NewGobEmptyMessageEncoder(w io.Writer) *GobEmptyMessageEncoder {
-	return &GobEmptyMessageEncoder{
+func NewBinaryEmptyMessageEncoder(w io.Writer) *BinaryEmptyMessageEncoder {
+	return &BinaryEmptyMessageEncoder{
 		w: w,
 	}
 }
 
-func (e *GobEmptyMessageEncoder) Encode(m *EmptyMessage) error {
-	return gob.NewEncoder(e.w).Encode(m)
+func (e *BinaryEmptyMessageEncoder) Encode(m *EmptyMessage) error {
+	w := core.NewBinaryWriter()
+	w.WriteVersion()
+	w.WriteUint8(byte(m.Type))
+	_, err := e.w.Write(w.Bytes())
+	return err
 }
 
-type GobEmptyMessageDecoder struct {
+type BinaryEmptyMessageDecoder struct {
 	r io.Reader
 }
 
-func NewGobEmptyMessageDecoder(r io.Reader) *GobEmptyMessageDecoder {
-	return &GobEmptyMessageDecoder{
+func NewBinaryEmptyMessageDecoder(r io.Reader) *BinaryEmptyMessageDecoder {
+	return &BinaryEmptyMessageDecoder{
 		r: r,
 	}
 }
 
-func (d *GobEmptyMessageDecoder) Decode(m *EmptyMessage) error {
-	return gob.NewDecoder(d.r).Decode(m)
+func (d *BinaryEmptyMessageDecoder) Decode(m *EmptyMessage) error {
+	r := core.NewBinaryReader(d.r)
+	r.ReadVersion()
+	m.Type = MessageType(r.ReadUint8())
+	r.ReadEOF()
+	return r.Err()
 }
 
 type SyncBlocksRequest struct {
@@ -102,32 +124,42 @@ func (r *SyncBlocksRequest) Decode(dec core.Decoder[*SyncBlocksRequest]) error {
 	return dec.Decode(r)
 }
 
-type GobSyncBlocksRequestEncoder struct {
+type BinarySyncBlocksRequestEncoder struct {
 	w io.Writer
 }
 
-func NewGobSyncBlocksRequestEncoder(w io.Writer) *GobSyncBlocksRequestEncoder {
-	return &GobSyncBlocksRequestEncoder{
+func NewBinarySyncBlocksRequestEncoder(w io.Writer) *BinarySyncBlocksRequestEncoder {
+	return &BinarySyncBlocksRequestEncoder{
 		w: w,
 	}
 }
 
-func (e *GobSyncBlocksRequestEncoder) Encode(r *SyncBlocksRequest) error {
-	return gob.NewEncoder(e.w).Encode(r)
+func (e *BinarySyncBlocksRequestEncoder) Encode(r *SyncBlocksRequest) error {
+	w := core.NewBinaryWriter()
+	w.WriteVersion()
+	w.WriteUint32(r.FromHeight)
+	w.WriteUint32(r.ToHeight)
+	_, err := e.w.Write(w.Bytes())
+	return err
 }
 
-type GobSyncBlocksRequestDecoder struct {
+type BinarySyncBlocksRequestDecoder struct {
 	r io.Reader
 }
 
-func NewGobSyncBlocksRequestDecoder(r io.Reader) *GobSyncBlocksRequestDecoder {
-	return &GobSyncBlocksRequestDecoder{
+func NewBinarySyncBlocksRequestDecoder(r io.Reader) *BinarySyncBlocksRequestDecoder {
+	return &BinarySyncBlocksRequestDecoder{
 		r: r,
 	}
 }
 
-func (d *GobSyncBlocksRequestDecoder) Decode(r *SyncBlocksRequest) error {
-	return gob.NewDecoder(d.r).Decode(r)
+func (d *BinarySyncBlocksRequestDecoder) Decode(req *SyncBlocksRequest) error {
+	r := core.NewBinaryReader(d.r)
+	r.ReadVersion()
+	req.FromHeight = r.ReadUint32()
+	req.ToHeight = r.ReadUint32()
+	r.ReadEOF()
+	return r.Err()
 }
 
 type Blocks []*core.Block
@@ -140,30 +172,57 @@ func (b *Blocks) Decode(dec core.Decoder[*Blocks]) error {
 	return dec.Decode(b)
 }
 
-type GobBlocksEncoder struct {
+// BinaryBlocksEncoder writes the number of blocks followed by the encoded blocks
+type BinaryBlocksEncoder struct {
 	w io.Writer
 }
 
-func NewGobBlocksEncoder(w io.Writer) *GobBlocksEncoder {
-	return &GobBlocksEncoder{
+func NewBinaryBlocksEncoder(w io.Writer) *BinaryBlocksEncoder {
+	return &BinaryBlocksEncoder{
 		w: w,
 	}
 }
 
-func (e *GobBlocksEncoder) Encode(b *Blocks) error {
-	return gob.NewEncoder(e.w).Encode(b)
+func (e *BinaryBlocksEncoder) Encode(b *Blocks) error {
+	w := core.NewBinaryWriter()
+	w.WriteVersion()
+	w.WriteUint32(uint32(len(*b)))
+	if _, err := e.w.Write(w.Bytes()); err != nil {
+		return err
+	}
+	for _, block := range *b {
+		if err := block.Encode(core.NewBinaryBlockEncoder(e.w)); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-type GobBlocksDecoder struct {
+type BinaryBlocksDecoder struct {
 	r io.Reader
 }
 
-func NewGobBlocksDecoder(r io.Reader) *GobBlocksDecoder {
-	return &GobBlocksDecoder{
+func NewBinaryBlocksDecoder(r io.Reader) *BinaryBlocksDecoder {
+	return &BinaryBlocksDecoder{
 		r: r,
 	}
 }
 
-func (d *GobBlocksDecoder) Decode(b *Blocks) error {
-	return gob.NewDecoder(d.r).Decode(b)
+func (d *BinaryBlocksDecoder) Decode(b *Blocks) error {
+	r := core.NewBinaryReader(d.r)
+	r.ReadVersion()
+	count := r.ReadUint32()
+
+	*b = nil
+	for i := uint32(0); i < count && r.Err() == nil; i++ {
+		block := new(core.Block)
+		r.ReadBlock(block)
+		*b = append(*b, block)
+	}
+	r.ReadEOF()
+	if err := r.Err(); err != nil {
+		*b = nil
+		return err
+	}
+	return nil
 }
@@ -1,7 +1,9 @@
 package network
 
 import (
+	"blockchain/core"
 	"bytes"
+	"encoding/hex"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
@@ -11,9 +13,64 @@ func TestMessage_EmptyMessageEncodeDecode(t *testing.T) {
 		Type: MessageTypeStatusRequest,
 	}
 	buf := new(bytes.Buffer)
-	assert.Nil(t, msg.Encode(NewGobEmptyMessageEncoder(buf)))
+	assert.Nil(t, msg.Encode(NewBinaryEmptyMessageEncoder(buf)))
 
 	msgDecoded := new(EmptyMessage)
-	assert.Nil(t, msgDecoded.Decode(NewGobEmptyMessageDecoder(buf)))
+	assert.Nil(t, msgDecoded.Decode(NewBinaryEmptyMessageDecoder(buf)))
 	assert.Equal(t, msg, msgDecoded)
 }
+
+func TestMessage_StatusEncodeDecode(t *testing.T) {
+	status := &Status{
+		Addr:    ":3000",
+		ChainID: 7,
+		Version: 1,
+		Height:  42,
+	}
+	buf := new(bytes.Buffer)
+	assert.Nil(t, status.Encode(NewBinaryStatusEncoder(buf)))
+	assert.Equal(t, "01"+"000000053a33303030"+"0000000000000007"+"00000001"+"0000002a", hex.EncodeToString(buf.Bytes()))
+
+	statusDecoded := new(Status)
+	assert.Nil(t, statusDecoded.Decode(NewBinaryStatusDecoder(buf)))
+	assert.Equal(t, status, statusDecoded)
+}
+
+func TestMessage_RPCMessage(t *testing.T) {
+	req := &SyncBlocksRequest{FromHeight: 1, ToHeight: 2}
+	buf := new(bytes.Buffer)
+	assert.Nil(t, req.Encode(NewBinarySyncBlocksRequestEncoder(buf)))
+
+	msg := NewRPCMessage(MessageTypeSyncBlocksRequest, buf.Bytes())
+	assert.Equal(t, "01"+"04"+"00000009"+"01"+"00000001"+"00000002", hex.EncodeToString(msg.Bytes()))
+
+	decoded, err := DefaultDecodeRPCFunc(RPC{Payload: bytes.NewReader(msg.Bytes())})
+	assert.Nil(t, err)
+	assert.Equal(t, req, decoded.Payload)
+
+	_, err = DefaultDecodeRPCFunc(RPC{Payload: bytes.NewReader([]byte{2, 4})})
+	assert.ErrorIs(t, err, core.ErrCodecVersion)
+
+	_, err = DefaultDecodeRPCFunc(RPC{Payload: bytes.NewReader(NewRPCMessage(MessageTypeSyncBlocksRequest, append(buf.Bytes(), 0)).Bytes())})
+	assert.ErrorIs(t, err, core.ErrNonCanonical)
+
+	_, err = DefaultDecodeRPCFunc(RPC{Payload: bytes.NewReader(append(msg.Bytes(), 0))})
+	assert.ErrorIs(t, err, core.ErrNonCanonical)
+}
+
+func TestMessage_BlocksEncodeDecode(t *testing.T) {
+	tx := core.NewTransaction(&core.Deploy{Code: []byte("hey")})
+	blocks := Blocks{
+		core.NewBlock(&core.Header{Version: 1}, nil),
+		core.NewBlock(&core.Header{Version: 1, Height: 1}, []*core.Transaction{tx}),
+	}
+	buf := new(bytes.Buffer)
+	assert.Nil(t, blocks.Encode(NewBinaryBlocksEncoder(buf)))
+
+	blocksDecoded := new(Blocks)
+	assert.Nil(t, blocksDecoded.Decode(NewBinaryBlocksDecoder(bytes.NewReader(buf.Bytes()))))
+	assert.Equal(t, blocks, *blocksDecoded)
+
+	trailing := append(buf.Bytes(), 0)
+	assert.ErrorIs(t, new(Blocks).Decode(NewBinaryBlocksDecoder(bytes.NewReader(trailing))), core.ErrNonCanonical)
+}
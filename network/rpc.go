@@ -3,7 +3,6 @@ package network
 import (
 	"blockchain/core"
 	"bytes"
-	"encoding/gob"
 	"fmt"
 	"io"
 	"log/slog"
@@ -39,10 +38,13 @@ func NewRPCMessage(t MessageType, body []byte) *RPCMessage {
 	}
 }
 
+// Bytes returns the codec version, the message type and the length prefixed body
 func (m *RPCMessage) Bytes() []byte {
-	buf := new(bytes.Buffer)
-	gob.NewEncoder(buf).Encode(m)
-	return buf.Bytes()
+	w := core.NewBinaryWriter()
+	w.WriteVersion()
+	w.WriteUint8(byte(m.Header))
+	w.WriteBytes(m.Body)
+	return w.Bytes()
 }
 
 type RPC struct {
@@ -58,15 +60,18 @@ type DecodedRPCMessage struct {
 type DecodeRPCFunc func(RPC) (*DecodedRPCMessage, error)
 
 func DefaultDecodeRPCFunc(rpc RPC) (*DecodedRPCMessage, error) {
-	msg := new(RPCMessage)
-	if err := gob.NewDecoder(rpc.Payload).Decode(msg); err != nil {
-		return nil, fmt.Errorf("failed to decode message from %s: %s", rpc.From, err)
+	r := core.NewBinaryReader(rpc.Payload)
+	r.ReadVersion()
+	msg := NewRPCMessage(MessageType(r.ReadUint8()), r.ReadBytes())
+	r.ReadEOF()
+	if err := r.Err(); err != nil {
+		return nil, fmt.Errorf("failed to decode message from %s: %w", rpc.From, err)
 	}
 
 	switch msg.Header {
 	case MessageTypeTransaction:
 		tx := new(core.Transaction)
-		if err := tx.Decode(core.NewGobTransactionDecoder(bytes.NewReader(msg.Body))); err != nil {
+		if err := tx.Decode(core.NewBinaryTransactionDecoder(bytes.NewReader(msg.Body))); err != nil {
 			return nil, err
 		}
 		return &DecodedRPCMessage{
@@ -75,7 +80,7 @@ func DefaultDecodeRPCFunc(rpc RPC) (*DecodedRPCMessage, error) {
 		}, nil
 	case MessageTypeBlock:
 		block := new(core.Block)
-		if err := block.Decode(core.NewGobBlockDecoder(bytes.NewReader(msg.Body))); err != nil {
+		if err := block.Decode(core.NewBinaryBlockDecoder(bytes.NewReader(msg.Body))); err != nil {
 			return nil, err
 		}
 		return &DecodedRPCMessage{
@@ -84,7 +89,7 @@ func DefaultDecodeRPCFunc(rpc RPC) (*DecodedRPCMessage, error) {
 		}, nil
 	case MessageTypeStatusRequest:
 		emptyMessage := new(EmptyMessage)
-		if err := emptyMessage.Decode(NewGobEmptyMessageDecoder(bytes.NewReader(msg.Body))); err != nil {
+		if err := emptyMessage.Decode(NewBinaryEmptyMessageDecoder(bytes.NewReader(msg.Body))); err != nil {
 			return nil, err
 		}
 		return &DecodedRPCMessage{
@@ -93,7 +98,7 @@ func DefaultDecodeRPCFunc(rpc RPC) (*DecodedRPCMessage, error) {
 		}, nil
 	case MessageTypeStatus:
 		status := new(Status)
-		if err := status.Decode(NewGobStatusDecoder(bytes.NewReader(msg.Body))); err != nil {
+		if err := status.Decode(NewBinaryStatusDecoder(bytes.NewReader(msg.Body))); err != nil {
 			return nil, err
 		}
 		return &DecodedRPCMessage{
@@ -102,7 +107,7 @@ func DefaultDecodeRPCFunc(rpc RPC) (*DecodedRPCMessage, error) {
 		}, nil
 	case MessageTypeSyncBlocksRequest:
 		req := new(SyncBlocksRequest)
-		if err := req.Decode(NewGobSyncBlocksRequestDecoder(bytes.NewReader(msg.Body))); err != nil {
+		if err := req.Decode(NewBinarySyncBlocksRequestDecoder(bytes.NewReader(msg.Body))); err != nil {
 			return nil, err
 		}
 		return &DecodedRPCMessage{
@@ -111,7 +116,7 @@ func DefaultDecodeRPCFunc(rpc RPC) (*DecodedRPCMessage, error) {
 		}, nil
 	case MessageTypeMissingBlocks:
 		blocks := new(Blocks)
-		if err := blocks.Decode(NewGobBlocksDecoder(bytes.NewReader(msg.Body))); err != nil {
+		if err := blocks.Decode(NewBinaryBlocksDecoder(bytes.NewReader(msg.Body))); err != nil {
 			return nil, err
 		}
 		return &DecodedRPCMessage{
@@ -4,6 +4,7 @@ import (
 	"blockchain/core"
 	"blockchain/crypto"
 	"bytes"
+	"fmt"
 	"log/slog"
 	"net"
 	"os"
@@ -25,6 +26,10 @@ type ServerOpts struct {
 	RPCProcessor      RPCProcessor
 	TransactionHasher core.Hasher[*core.Transaction]
 	Transport         *TCPTransport
+	// Storage persists blocks, defaults to in-memory storage
+	Storage core.Storage
+	// Genesis configures the chain, defaults to the development network
+	Genesis *core.GenesisConfig
 }
 
 type Server struct {
@@ -69,12 +74,21 @@ func NewServer(opts ServerOpts) (*Server, error) {
 		s.TransactionHasher = core.TransactionHasher{}
 	}
 
+	if s.Storage == nil {
+		s.Storage = core.NewMemoryStore()
+	}
+
+	if s.Genesis == nil {
+		s.Genesis = core.DefaultGenesisConfig()
+	}
+
 	s.Transport = NewTCPTransport(s.Addr, s.rpcCh)
 
-	blockchain, err := core.NewBlockchain(core.CreateGenesisBlock())
+	blockchain, err := core.NewBlockchain(s.Storage, core.CreateGenesisBlock(s.Genesis))
 	if err != nil {
 		return nil, err
 	}
+	blockchain.SetMinGasPrice(s.Genesis.MinGasPrice)
 	s.blockchain = blockchain
 
 	api := NewAPI(APIConfig{
@@ -87,7 +101,7 @@ func NewServer(opts ServerOpts) (*Server, error) {
 		go s.validatorLoop()
 	}
 
-	s.memPool = NewTransactionPool(10, s.TransactionHasher)
+	s.memPool = NewTransactionPool(10, s.TransactionHasher, blockchain, s.Genesis.MinGasPrice)
 
 	return s, nil
 }
@@ -154,14 +168,20 @@ func (s *Server) createNewBlock() error {
 		return err
 	}
 
-	txs := s.memPool.Pending()
+	txs := s.memPool.PendingWithinGas(core.BlockGasLimit)
+	// transactions which would fail the block are dropped, others stay pending
+	txs, rejected := s.blockchain.SelectTransactions(currentBlock.Header, s.PrivateKey.PublicKey(), txs)
+	if len(rejected) > 0 {
+		s.Logger.Info("evicting transactions from mempool", "count", len(rejected))
+		s.memPool.Evict(rejected)
+	}
 
 	block, err := core.NewBlockFromPrevHeader(currentBlock.Header, txs)
 	if err != nil {
 		return err
 	}
 
-	if err = block.Sign(s.PrivateKey); err != nil {
+	if err = s.blockchain.FinalizeBlock(block, s.PrivateKey); err != nil {
 		return err
 	}
 
@@ -169,7 +189,7 @@ func (s *Server) createNewBlock() error {
 		return err
 	}
 
-	s.memPool.ClearPending()
+	s.memPool.RemovePending(txs)
 
 	go func() {
 		err = s.broadcastBlock(block)
@@ -188,7 +208,7 @@ func (s *Server) SyncBlocksLoop(addr net.Addr) error {
 			FromHeight: s.blockchain.Height(),
 		}
 		buf := new(bytes.Buffer)
-		if err := req.Encode(NewGobSyncBlocksRequestEncoder(buf)); err != nil {
+		if err := req.Encode(NewBinarySyncBlocksRequestEncoder(buf)); err != nil {
 			return err
 		}
 		rpcMessage := NewRPCMessage(MessageTypeSyncBlocksRequest, buf.Bytes())
@@ -226,7 +246,7 @@ func (s *Server) ProcessRPCMessage(msg *DecodedRPCMessage) error {
 
 func (s *Server) broadcastTransaction(tx *core.Transaction) error {
 	buf := new(bytes.Buffer)
-	if err := tx.Encode(core.NewGobTransactionEncoder(buf)); err != nil {
+	if err := tx.Encode(core.NewBinaryTransactionEncoder(buf)); err != nil {
 		return err
 	}
 	rpcMessage := NewRPCMessage(MessageTypeTransaction, buf.Bytes())
@@ -239,10 +259,21 @@ func (s *Server) receiveTransaction(tx *core.Transaction) error {
 		return nil
 	}
 
+	if tx.ChainID != s.blockchain.ChainID() {
+		return fmt.Errorf("%w: transaction (%s) has chain id (%d) => expected (%d)",
+			core.ErrInvalidChainID, hash, tx.ChainID, s.blockchain.ChainID())
+	}
+
 	if err := tx.Verify(); err != nil {
 		return err
 	}
 
+	s.Logger.Info("adding new transaction to mempool",
+		"hash", hash, "mempool length", s.memPool.PendingCount())
+	if err := s.memPool.Add(tx); err != nil {
+		return err
+	}
+
 	go func() {
 		err := s.broadcastTransaction(tx)
 		if err != nil {
@@ -250,14 +281,12 @@ func (s *Server) receiveTransaction(tx *core.Transaction) error {
 		}
 	}()
 
-	s.Logger.Info("adding new transaction to mempool",
-		"hash", hash, "mempool length", s.memPool.PendingCount())
-	return s.memPool.Add(tx)
+	return nil
 }
 
 func (s *Server) broadcastBlock(block *core.Block) error {
 	buf := new(bytes.Buffer)
-	if err := block.Encode(core.NewGobBlockEncoder(buf)); err != nil {
+	if err := block.Encode(core.NewBinaryBlockEncoder(buf)); err != nil {
 		return err
 	}
 	rpcMessage := NewRPCMessage(MessageTypeBlock, buf.Bytes())
@@ -282,7 +311,7 @@ func (s *Server) sendStatusRequest(addr net.Addr) error {
 		Type: MessageTypeStatusRequest,
 	}
 	buf := new(bytes.Buffer)
-	if err := emptyMessage.Encode(NewGobEmptyMessageEncoder(buf)); err != nil {
+	if err := emptyMessage.Encode(NewBinaryEmptyMessageEncoder(buf)); err != nil {
 		return err
 	}
 	rpcMessage := NewRPCMessage(MessageTypeStatusRequest, buf.Bytes())
@@ -292,18 +321,27 @@ func (s *Server) sendStatusRequest(addr net.Addr) error {
 func (s *Server) receiveStatusRequest(addr net.Addr) error {
 	s.Logger.Info("received status request", "server address", s.Addr, "from", addr)
 	status := &Status{
-		Addr:   s.Addr,
-		Height: s.blockchain.Height(),
+		Addr:    s.Addr,
+		ChainID: s.blockchain.ChainID(),
+		Height:  s.blockchain.Height(),
 	}
 	buf := new(bytes.Buffer)
-	if err := status.Encode(NewGobStatusEncoder(buf)); err != nil {
+	if err := status.Encode(NewBinaryStatusEncoder(buf)); err != nil {
 		return err
 	}
 	rpcMessage := NewRPCMessage(MessageTypeStatus, buf.Bytes())
 	return s.Transport.SendMessage(addr, rpcMessage.Bytes())
 }
 
+// receiveStatus disconnects peers of other chains and syncs blocks with peers ahead of this node
 func (s *Server) receiveStatus(addr net.Addr, status *Status) error {
+	if status.ChainID != s.blockchain.ChainID() {
+		if err := s.Transport.RemovePeer(addr); err != nil {
+			s.Logger.Error(err.Error(), "server address", s.Addr)
+		}
+		return fmt.Errorf("%w: peer (%s) has chain id (%d) => expected (%d)",
+			core.ErrInvalidChainID, addr, status.ChainID, s.blockchain.ChainID())
+	}
 	if status.Height <= s.blockchain.Height() {
 		s.Logger.Info("no need to sync blocks with this node", "server address", s.Addr, "from", addr)
 		return nil
@@ -312,7 +350,7 @@ func (s *Server) receiveStatus(addr net.Addr, status *Status) error {
 		FromHeight: s.blockchain.Height(),
 	}
 	buf := new(bytes.Buffer)
-	if err := req.Encode(NewGobSyncBlocksRequestEncoder(buf)); err != nil {
+	if err := req.Encode(NewBinarySyncBlocksRequestEncoder(buf)); err != nil {
 		return err
 	}
 	rpcMessage := NewRPCMessage(MessageTypeSyncBlocksRequest, buf.Bytes())
@@ -340,7 +378,7 @@ func (s *Server) receiveSyncBlocksRequest(addr net.Addr, req *SyncBlocksRequest)
 	}
 
 	buf := new(bytes.Buffer)
-	if err := blocks.Encode(NewGobBlocksEncoder(buf)); err != nil {
+	if err := blocks.Encode(NewBinaryBlocksEncoder(buf)); err != nil {
 		return err
 	}
 	rpcMessage := NewRPCMessage(MessageTypeMissingBlocks, buf.Bytes())
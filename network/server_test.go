@@ -0,0 +1,58 @@
+package network
+
+import (
+	"blockchain/core"
+	"blockchain/crypto"
+	"blockchain/types"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"net"
+	"testing"
+)
+
+func TestServer_RejectsOtherChains(t *testing.T) {
+	s, err := NewServer(ServerOpts{Addr: ":4000"})
+	assert.Nil(t, err)
+
+	tx := core.NewTransaction(&core.Deploy{Code: []byte("hey")})
+	tx.ChainID = core.DefaultChainID + 1
+	assert.Nil(t, tx.Sign(crypto.GeneratePrivateKey()))
+	assert.ErrorIs(t, s.receiveTransaction(tx), core.ErrInvalidChainID)
+	assert.Equal(t, 0, s.memPool.PendingCount())
+
+	peer := &net.TCPAddr{Port: 4001}
+	status := &Status{ChainID: core.DefaultChainID + 1, Height: 10}
+	assert.ErrorIs(t, s.receiveStatus(peer, status), core.ErrInvalidChainID)
+}
+
+func TestServer_RejectsUnfundedTransactions(t *testing.T) {
+	s, err := NewServer(ServerOpts{Addr: ":4000"})
+	assert.Nil(t, err)
+
+	tx := core.NewTransaction(&core.Deploy{Code: []byte("hey")})
+	tx.ChainID = core.DefaultChainID
+	tx.GasPrice = big.NewInt(core.DefaultMinGasPrice)
+	assert.Nil(t, tx.Sign(crypto.GeneratePrivateKey()))
+	assert.ErrorIs(t, s.receiveTransaction(tx), core.ErrInsufficientFunds)
+	assert.Equal(t, 0, s.memPool.PendingCount())
+}
+
+func TestServer_CreateNewBlock(t *testing.T) {
+	validator := crypto.GeneratePrivateKey()
+	genesis := core.DefaultGenesisConfig()
+	genesis.Alloc = []core.GenesisAccount{{Address: validator.PublicKey().Address(), Balance: big.NewInt(1_000_000)}}
+	s, err := NewServer(ServerOpts{Addr: ":4000", PrivateKey: validator, Genesis: genesis})
+	assert.Nil(t, err)
+
+	tx := core.NewTransaction(&core.Transfer{To: types.Address{1}, Value: big.NewInt(1)})
+	tx.ChainID = core.DefaultChainID
+	tx.GasPrice = big.NewInt(core.DefaultMinGasPrice)
+	assert.Nil(t, tx.Sign(validator))
+	assert.Nil(t, s.receiveTransaction(tx))
+
+	assert.Nil(t, s.createNewBlock())
+	assert.Equal(t, uint32(1), s.blockchain.Height())
+	assert.Equal(t, 0, s.memPool.PendingCount())
+	_, err = s.blockchain.GetReceipt(tx.Hash(core.TransactionHasher{}))
+	assert.Nil(t, err)
+}
@@ -2,6 +2,7 @@ package network
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -62,7 +63,9 @@ func (t *TCPTransport) AddPeer(conn net.Conn, incoming bool) {
 		conn:     conn,
 		incoming: incoming,
 	}
+	t.peersMu.Lock()
 	t.peers[peer.conn.RemoteAddr()] = peer
+	t.peersMu.Unlock()
 	go func() {
 		t.addPeerCh <- peer
 	}()
@@ -79,7 +82,12 @@ func (t *TCPTransport) readLoop(peer *Peer) {
 				fmt.Printf("error closing (%s) connection: %s", peer.conn.RemoteAddr(), err)
 			}
 			fmt.Println("break!")
+			t.peersMu.Lock()
 			delete(t.peers, peer.conn.RemoteAddr())
+			t.peersMu.Unlock()
+			break
+		}
+		if errors.Is(err, net.ErrClosed) {
 			break
 		}
 		if err != nil {
@@ -94,8 +102,23 @@ func (t *TCPTransport) readLoop(peer *Peer) {
 	}
 }
 
+// RemovePeer closes connection to the peer
+func (t *TCPTransport) RemovePeer(addr net.Addr) error {
+	t.peersMu.Lock()
+	defer t.peersMu.Unlock()
+
+	peer, ok := t.peers[addr]
+	if !ok {
+		return fmt.Errorf("%s: peer %s not found", t.listenAddr, addr)
+	}
+	delete(t.peers, addr)
+	return peer.conn.Close()
+}
+
 func (t *TCPTransport) SendMessage(to net.Addr, payload []byte) error {
+	t.peersMu.RLock()
 	peer, ok := t.peers[to]
+	t.peersMu.RUnlock()
 	if !ok {
 		return fmt.Errorf("%s: could not send message to %s", t.listenAddr, to)
 	}
@@ -107,7 +130,14 @@ func (t *TCPTransport) SendMessage(to net.Addr, payload []byte) error {
 }
 
 func (t *TCPTransport) Broadcast(payload []byte) error {
+	t.peersMu.RLock()
+	addrs := make([]net.Addr, 0, len(t.peers))
 	for addr := range t.peers {
+		addrs = append(addrs, addr)
+	}
+	t.peersMu.RUnlock()
+
+	for _, addr := range addrs {
 		if err := t.SendMessage(addr, payload); err != nil {
 			fmt.Printf("error sending message to peer (%s): %s\n", addr, err)
 		}
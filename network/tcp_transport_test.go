@@ -0,0 +1,46 @@
+package network
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net"
+	"sync"
+	"testing"
+)
+
+func TestTCPTransport_RemovePeer(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer ln.Close()
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	assert.Nil(t, err)
+	remote, err := ln.Accept()
+	assert.Nil(t, err)
+	defer remote.Close()
+
+	tr := NewTCPTransport(":0", make(chan RPC, 1))
+	tr.AddPeer(conn, false)
+
+	// concurrent removals of the same peer, only one of them finds it
+	var (
+		wg   sync.WaitGroup
+		errs = make(chan error, 2)
+	)
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- tr.RemovePeer(conn.RemoteAddr())
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	var failed int
+	for err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+	assert.Equal(t, 1, failed)
+	assert.NotNil(t, tr.SendMessage(conn.RemoteAddr(), []byte{1}))
+}
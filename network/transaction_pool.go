@@ -4,10 +4,18 @@ import (
 	"blockchain/core"
 	"blockchain/types"
 	"fmt"
+	"math/big"
 	"slices"
 	"sync"
 )
 
+// AccountGetter returns the nonce the next transaction of the account must have
+// and the balance of the account
+type AccountGetter interface {
+	GetNonce(types.Address) uint64
+	GetBalance(types.Address) *big.Int
+}
+
 type TransactionPool struct {
 	all     *TransactionList
 	pending *TransactionList
@@ -15,40 +23,96 @@ type TransactionPool struct {
 	// when the pool is full oldest transactions are pruned
 	maxLength int
 	hasher    core.Hasher[*core.Transaction]
+	// accounts provide nonces and balances, they aren't checked if nil
+	accounts AccountGetter
+	// minGasPrice of accepted transactions, any price is accepted if nil
+	minGasPrice *big.Int
 }
 
-func NewTransactionPool(maxLength int, hasher core.Hasher[*core.Transaction]) *TransactionPool {
+func NewTransactionPool(maxLength int, hasher core.Hasher[*core.Transaction], accounts AccountGetter, minGasPrice *big.Int) *TransactionPool {
 	return &TransactionPool{
-		all:       NewTransactionList(),
-		pending:   NewTransactionList(),
-		maxLength: maxLength,
-		hasher:    hasher,
+		all:         NewTransactionList(),
+		pending:     NewTransactionList(),
+		maxLength:   maxLength,
+		hasher:      hasher,
+		accounts:    accounts,
+		minGasPrice: minGasPrice,
 	}
 }
 
 func (p *TransactionPool) Add(tx *core.Transaction) error {
-	if p.all.Count() == p.maxLength {
-		oldest, err := p.all.First()
-		if err != nil {
+	if p.all.Contains(tx.Hash(p.hasher)) {
+		return nil
+	}
+
+	if err := tx.Validate(); err != nil {
+		return err
+	}
+
+	if err := core.CheckGasPrice(tx, p.minGasPrice); err != nil {
+		return err
+	}
+
+	if intrinsicGas := core.IntrinsicGas(tx); tx.GasLimit < intrinsicGas {
+		return fmt.Errorf("%w: transaction (%s) has gas limit (%d), intrinsic gas (%d)",
+			core.ErrIntrinsicGas, tx.Hash(p.hasher), tx.GasLimit, intrinsicGas)
+	}
+
+	if p.accounts != nil {
+		if err := p.checkNonce(tx); err != nil {
 			return err
 		}
-		err = p.all.Delete(oldest.Hash(p.hasher))
-		if err != nil {
+		if err := p.checkBalance(tx); err != nil {
 			return err
 		}
 	}
 
-	if !p.all.Contains(tx.Hash(p.hasher)) {
-		err := p.all.Add(tx, p.hasher)
+	if p.all.Count() == p.maxLength {
+		oldest, err := p.all.First()
 		if err != nil {
 			return err
 		}
-		err = p.pending.Add(tx, p.hasher)
+		err = p.all.Delete(oldest.Hash(p.hasher))
 		if err != nil {
 			return err
 		}
 	}
 
+	err := p.all.Add(tx, p.hasher)
+	if err != nil {
+		return err
+	}
+
+	return p.pending.Add(tx, p.hasher)
+}
+
+// checkNonce accepts only the next nonce of the sender
+// taking into account pending transactions of the sender
+func (p *TransactionPool) checkNonce(tx *core.Transaction) error {
+	from := tx.From.Address()
+	stateNonce := p.accounts.GetNonce(from)
+	nonce := p.pending.NextNonce(from, stateNonce)
+
+	if tx.Nonce < nonce {
+		return fmt.Errorf("%w: transaction (%s) has nonce (%d), next nonce of account (%s) is (%d)",
+			core.ErrNonceTooLow, tx.Hash(p.hasher), tx.Nonce, from, nonce)
+	}
+	if tx.Nonce > nonce {
+		return fmt.Errorf("%w: transaction (%s) has nonce (%d), next nonce of account (%s) is (%d)",
+			core.ErrNonceTooHigh, tx.Hash(p.hasher), tx.Nonce, from, nonce)
+	}
+
+	return nil
+}
+
+// checkBalance accepts only transactions which sender can pay for the gas limit and the sent value
+func (p *TransactionPool) checkBalance(tx *core.Transaction) error {
+	from := tx.From.Address()
+	balance := p.accounts.GetBalance(from)
+	if cost := core.MaxCost(tx); balance.Cmp(cost) < 0 {
+		return fmt.Errorf("%w: transaction (%s) costs (%s), balance of account (%s) is (%s)",
+			core.ErrInsufficientFunds, tx.Hash(p.hasher), cost, from, balance)
+	}
 	return nil
 }
 
@@ -60,6 +124,41 @@ func (p *TransactionPool) Pending() []*core.Transaction {
 	return p.pending.transactions
 }
 
+// PendingWithinGas returns the longest prefix of pending transactions
+// which sum of gas limits doesn't exceed the gas limit
+func (p *TransactionPool) PendingWithinGas(gasLimit uint64) []*core.Transaction {
+	p.pending.mu.RLock()
+	defer p.pending.mu.RUnlock()
+
+	n := len(p.pending.transactions)
+	var gas uint64
+	for i, tx := range p.pending.transactions {
+		if tx.GasLimit > gasLimit-gas {
+			n = i
+			break
+		}
+		gas += tx.GasLimit
+	}
+	return slices.Clone(p.pending.transactions[:n])
+}
+
+// RemovePending removes the transactions included in a block from pending transactions
+func (p *TransactionPool) RemovePending(txs []*core.Transaction) {
+	for _, tx := range txs {
+		_ = p.pending.Delete(tx.Hash(p.hasher))
+	}
+}
+
+// Evict removes the transactions which can't be included in a block from the pool,
+// so they are accepted again once they become valid
+func (p *TransactionPool) Evict(txs []*core.Transaction) {
+	for _, tx := range txs {
+		hash := tx.Hash(p.hasher)
+		_ = p.pending.Delete(hash)
+		_ = p.all.Delete(hash)
+	}
+}
+
 func (p *TransactionPool) PendingCount() int {
 	return p.pending.Count()
 }
@@ -125,6 +224,21 @@ func (l *TransactionList) Contains(h types.Hash) bool {
 	return ok
 }
 
+// NextNonce returns the nonce following the nonces of transactions sent from the address,
+// nonce is returned if there are no such transactions with equal or greater nonce
+func (l *TransactionList) NextNonce(from types.Address, nonce uint64) uint64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	for _, tx := range l.transactions {
+		if tx.Nonce >= nonce && tx.From.Address() == from {
+			nonce = tx.Nonce + 1
+		}
+	}
+
+	return nonce
+}
+
 func (l *TransactionList) Count() int {
 	l.mu.RLock()
 	defer l.mu.RUnlock()
@@ -2,13 +2,16 @@ package network
 
 import (
 	"blockchain/core"
+	"blockchain/crypto"
+	"blockchain/types"
 	"blockchain/utils"
 	"github.com/stretchr/testify/assert"
+	"math/big"
 	"testing"
 )
 
 func TestTransactionPool_MaxLength(t *testing.T) {
-	pool := NewTransactionPool(1, core.TransactionHasher{})
+	pool := NewTransactionPool(1, core.TransactionHasher{}, nil, nil)
 	err := pool.Add(utils.NewRandomTransaction(10))
 	assert.Nil(t, err)
 	assert.Equal(t, 1, pool.all.Count())
@@ -26,12 +29,53 @@ func TestTransactionPool_MaxLength(t *testing.T) {
 	assert.True(t, pool.Contains(tx.Hash(core.TransactionHasher{})))
 }
 
+func TestTransactionPool_RejectsInvalid(t *testing.T) {
+	pool := NewTransactionPool(10, core.TransactionHasher{}, nil, nil)
+
+	tx := utils.NewRandomTransaction(10)
+	tx.GasPrice = big.NewInt(-1)
+	assert.ErrorIs(t, pool.Add(tx), core.ErrInvalidTransaction)
+
+	assert.ErrorIs(t, pool.Add(core.NewTransaction(nil)), core.ErrInvalidTransaction)
+
+	tx = utils.NewRandomTransaction(10)
+	tx.GasLimit = core.MaxGasLimit + 1
+	assert.ErrorIs(t, pool.Add(tx), core.ErrGasLimitTooHigh)
+	assert.Equal(t, 0, pool.PendingCount())
+}
+
+func TestTransactionPool_MinGasPrice(t *testing.T) {
+	pool := NewTransactionPool(10, core.TransactionHasher{}, nil, big.NewInt(2))
+
+	tx := utils.NewRandomTransaction(10)
+	assert.ErrorIs(t, pool.Add(tx), core.ErrGasPriceTooLow)
+	tx.GasPrice = big.NewInt(1)
+	assert.ErrorIs(t, pool.Add(tx), core.ErrGasPriceTooLow)
+	tx.GasPrice = big.NewInt(2)
+	assert.Nil(t, pool.Add(tx))
+}
+
+func TestTransactionPool_PendingWithinGas(t *testing.T) {
+	pool := NewTransactionPool(10, core.TransactionHasher{}, nil, nil)
+	for i := 0; i < 3; i++ {
+		tx := utils.NewRandomTransaction(10)
+		tx.GasLimit = core.MaxGasLimit
+		assert.Nil(t, pool.Add(tx))
+	}
+
+	txs := pool.PendingWithinGas(2*core.MaxGasLimit + 1)
+	assert.Equal(t, 2, len(txs))
+	pool.RemovePending(txs)
+	assert.Equal(t, 1, pool.PendingCount())
+	assert.Equal(t, 2, len(txs))
+}
+
 func TestTransactionPool_MaxLength_2(t *testing.T) {
 	var txs []*core.Transaction
 	maxLength := 10
 	n := 100
 
-	pool := NewTransactionPool(maxLength, core.TransactionHasher{})
+	pool := NewTransactionPool(maxLength, core.TransactionHasher{}, nil, nil)
 
 	for i := 0; i < n; i++ {
 		tx := utils.NewRandomTransaction(100)
@@ -52,7 +96,7 @@ func TestTransactionPool_MaxLength_2(t *testing.T) {
 }
 
 func TestTransactionPool_Add(t *testing.T) {
-	pool := NewTransactionPool(11, core.TransactionHasher{})
+	pool := NewTransactionPool(11, core.TransactionHasher{}, nil, nil)
 	n := 10
 
 	for i := 1; i <= n; i++ {
@@ -117,3 +161,94 @@ func TestTransactionList_Delete(t *testing.T) {
 	assert.Equal(t, list.Count(), 0)
 	assert.False(t, list.Contains(tx.Hash(core.TransactionHasher{})))
 }
+
+type testAccounts struct {
+	nonces   map[types.Address]uint64
+	balances map[types.Address]*big.Int
+}
+
+func (a *testAccounts) GetNonce(addr types.Address) uint64 {
+	return a.nonces[addr]
+}
+
+func (a *testAccounts) GetBalance(addr types.Address) *big.Int {
+	if balance, ok := a.balances[addr]; ok {
+		return balance
+	}
+	return new(big.Int)
+}
+
+func TestTransactionPool_Nonce(t *testing.T) {
+	privateKey := crypto.GeneratePrivateKey()
+	accounts := &testAccounts{nonces: map[types.Address]uint64{privateKey.PublicKey().Address(): 5}}
+	pool := NewTransactionPool(10, core.TransactionHasher{}, accounts, nil)
+
+	newTx := func(nonce uint64) *core.Transaction {
+		tx := utils.NewRandomTransaction(10)
+		tx.Nonce = nonce
+		assert.Nil(t, tx.Sign(privateKey))
+		return tx
+	}
+
+	assert.ErrorIs(t, pool.Add(newTx(4)), core.ErrNonceTooLow)
+	assert.ErrorIs(t, pool.Add(newTx(6)), core.ErrNonceTooHigh)
+
+	tx := newTx(5)
+	assert.Nil(t, pool.Add(tx))
+	// the same transaction is ignored
+	assert.Nil(t, pool.Add(tx))
+	// pending transaction already has the nonce
+	assert.ErrorIs(t, pool.Add(newTx(5)), core.ErrNonceTooLow)
+	assert.Nil(t, pool.Add(newTx(6)))
+	assert.Equal(t, 2, pool.PendingCount())
+
+	// nonces of other accounts are independent
+	other := utils.NewRandomTransactionWithSignature(t, 10, crypto.GeneratePrivateKey())
+	assert.Nil(t, pool.Add(other))
+}
+
+func TestTransactionPool_IntrinsicGas(t *testing.T) {
+	pool := NewTransactionPool(10, core.TransactionHasher{}, nil, nil)
+	tx := utils.NewRandomTransaction(10)
+	tx.GasLimit = core.IntrinsicGas(tx) - 1
+	assert.ErrorIs(t, pool.Add(tx), core.ErrIntrinsicGas)
+	assert.Equal(t, 0, pool.PendingCount())
+
+	tx.GasLimit = core.IntrinsicGas(tx)
+	assert.Nil(t, pool.Add(tx))
+}
+
+func TestTransactionPool_Balance(t *testing.T) {
+	privateKey := crypto.GeneratePrivateKey()
+	accounts := &testAccounts{balances: map[types.Address]*big.Int{}}
+	pool := NewTransactionPool(10, core.TransactionHasher{}, accounts, nil)
+
+	tx := core.NewTransaction(&core.Transfer{To: types.Address{1}, Value: big.NewInt(10)})
+	tx.GasLimit = 100_000
+	tx.GasPrice = big.NewInt(1)
+	assert.Nil(t, tx.Sign(privateKey))
+	assert.ErrorIs(t, pool.Add(tx), core.ErrInsufficientFunds)
+
+	accounts.balances[privateKey.PublicKey().Address()] = big.NewInt(100_009)
+	assert.ErrorIs(t, pool.Add(tx), core.ErrInsufficientFunds)
+	accounts.balances[privateKey.PublicKey().Address()] = big.NewInt(100_010)
+	assert.Nil(t, pool.Add(tx))
+}
+
+func TestTransactionPool_Evict(t *testing.T) {
+	pool := NewTransactionPool(10, core.TransactionHasher{}, nil, nil)
+	txs := []*core.Transaction{
+		utils.NewRandomTransactionWithSignature(t, 10, crypto.GeneratePrivateKey()),
+		utils.NewRandomTransactionWithSignature(t, 10, crypto.GeneratePrivateKey()),
+	}
+	for _, tx := range txs {
+		assert.Nil(t, pool.Add(tx))
+	}
+
+	pool.Evict(txs[:1])
+	assert.Equal(t, txs[1:], pool.Pending())
+	assert.False(t, pool.Contains(txs[0].Hash(core.TransactionHasher{})))
+	// evicted transaction is queued again once resubmitted
+	assert.Nil(t, pool.Add(txs[0]))
+	assert.Equal(t, 2, pool.PendingCount())
+}
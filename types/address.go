@@ -1,9 +1,25 @@
 package types
 
-import "encoding/hex"
+import (
+	"encoding/hex"
+	"fmt"
+)
 
 type Address [20]uint8
 
 func (a Address) String() string {
 	return hex.EncodeToString(a[:])
 }
+
+func (a Address) IsZero() bool {
+	return a == Address{}
+}
+
+func AddressFromBytes(b []byte) Address {
+	if len(b) != 20 {
+		msg := fmt.Sprintf("given bytes with length %d should be 20", len(b))
+		panic(msg)
+	}
+
+	return [20]uint8(b)
+}
@@ -21,7 +21,7 @@ func RandomHash() types.Hash {
 }
 
 func NewRandomTransaction(size int) *core.Transaction {
-	return core.NewTransaction(RandomBytes(size))
+	return core.NewTransaction(&core.Deploy{Code: RandomBytes(size)})
 }
 
 func NewRandomTransactionWithSignature(t *testing.T, size int, privKey *crypto.PrivateKey) *core.Transaction {